@@ -0,0 +1,221 @@
+// Package cli implements Akumi's non-interactive subcommands.
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/omegaatt36/akumi/config"
+)
+
+// command is a single CLI subcommand.
+type command struct {
+	name    string
+	usage   string
+	summary string
+	run     func(env *env, args []string) error
+	// flags registers the command's flags; used to generate shell completion.
+	flags func(fs *flag.FlagSet)
+	// args names the kind of positional argument completed for the command.
+	args completionKind
+	// hidden commands are omitted from help output.
+	hidden bool
+}
+
+// env carries the output streams shared by every subcommand.
+type env struct {
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// errUsage signals that the command line was invalid and usage has been printed.
+var errUsage = errors.New("invalid usage")
+
+// exitError carries a specific process exit code, e.g. from the ssh process.
+type exitError struct {
+	code int
+}
+
+func (e exitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.code)
+}
+
+// commands lists every subcommand in the order shown by help.
+var commands []command
+
+func init() {
+	commands = []command{
+		{name: "list", usage: "list [flags]", summary: "List configured targets", run: runList,
+			flags: func(fs *flag.FlagSet) { new(listFlags).register(fs) }},
+		{name: "show", usage: "show <nickname|index>", summary: "Show the details of a target", run: runShow,
+			args: completeTargets},
+		{name: "add", usage: "add [flags] [user@]host[:port]", summary: "Add a new target", run: runAdd,
+			flags: func(fs *flag.FlagSet) { new(targetFlags).register(fs) }},
+		{name: "edit", usage: "edit <nickname|index> [flags]", summary: "Change fields of an existing target", run: runEdit,
+			flags: func(fs *flag.FlagSet) { new(targetFlags).register(fs) }, args: completeTargets},
+		{name: "rm", usage: "rm <nickname|index>", summary: "Remove a target", run: runRemove,
+			args: completeTargets},
+		{name: "connect", usage: "connect <nickname|index>", summary: "Connect to a target with ssh", run: runConnect,
+			args: completeTargets},
+		{name: "import", usage: "import [--path file] [--dry-run]", summary: "Import hosts from an OpenSSH client config", run: runImport,
+			flags: func(fs *flag.FlagSet) { new(importFlags).register(fs) }},
+		{name: "export", usage: "export [--path file] [--stdout]", summary: "Export targets as OpenSSH Host blocks", run: runExport,
+			flags: func(fs *flag.FlagSet) { new(exportFlags).register(fs) }},
+		{name: "config", usage: "config backups|restore [index|path]|migrate [--dry-run]|validate", summary: "Manage backups, schema upgrades and validation of the config", run: runConfig,
+			args: completeConfigCommands},
+		{name: "completion", usage: "completion bash|zsh|fish", summary: "Print a shell completion script", run: runCompletion,
+			args: completeShells},
+		{name: "help", usage: "help", summary: "Show this help", run: runHelp},
+		{name: "__complete", usage: "__complete targets|tags|groups|profiles", summary: "Print completion candidates", run: runComplete,
+			hidden: true},
+	}
+}
+
+// IsCommand reports whether name is a known subcommand.
+func IsCommand(name string) bool {
+	_, ok := findCommand(name)
+	return ok
+}
+
+// findCommand returns the subcommand with the given name.
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+// Run executes the subcommand named by args[0] and returns the process exit code.
+func Run(args []string, stdout, stderr io.Writer) int {
+	e := &env{stdout: stdout, stderr: stderr}
+	if len(args) == 0 {
+		PrintUsage(stderr)
+		return 2
+	}
+
+	cmd, ok := findCommand(args[0])
+	if !ok {
+		fmt.Fprintf(stderr, "akumi: unknown command %q\n\n", args[0])
+		PrintUsage(stderr)
+		return 2
+	}
+
+	err := cmd.run(e, args[1:])
+	var exitErr exitError
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, flag.ErrHelp):
+		return 0
+	case errors.Is(err, errUsage):
+		return 2
+	case errors.As(err, &exitErr):
+		return exitErr.code
+	default:
+		fmt.Fprintf(stderr, "akumi %s: %v\n", cmd.name, err)
+		return 1
+	}
+}
+
+// PrintUsage writes the list of subcommands to w.
+func PrintUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage:")
+	tw := tabwriter.NewWriter(w, 0, 4, 3, ' ', 0)
+	fmt.Fprintln(tw, "  akumi [--config file] [--profile name] [--tag selector]\tStart the interactive TUI")
+	for _, cmd := range commands {
+		if cmd.hidden {
+			continue
+		}
+		fmt.Fprintf(tw, "  akumi %s\t%s\n", cmd.usage, cmd.summary)
+	}
+	tw.Flush()
+}
+
+func runHelp(e *env, _ []string) error {
+	PrintUsage(e.stdout)
+	return nil
+}
+
+// newFlagSet creates a flag set for cmd that reports errors to stderr.
+func (e *env) newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet("akumi "+name, flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	return fs
+}
+
+// parseFlags parses args, allowing flags to follow positional arguments.
+func parseFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return nil, err
+			}
+			return nil, errUsage
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// stringsFlag collects the values of a repeatable flag.
+type stringsFlag []string
+
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringsFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// findTarget resolves ref as a nickname, export alias or 1-based index.
+func findTarget(targets []config.SSHTarget, ref string) (int, error) {
+	for i, t := range targets {
+		if t.Nickname != "" && t.Nickname == ref {
+			return i, nil
+		}
+	}
+	for i, t := range targets {
+		if t.Nickname != "" && t.SSHConfigAlias() == ref {
+			return i, nil
+		}
+	}
+	if n, err := strconv.Atoi(ref); err == nil {
+		if n < 1 || n > len(targets) {
+			return -1, fmt.Errorf("index %d out of range (1-%d)", n, len(targets))
+		}
+		return n - 1, nil
+	}
+	return -1, fmt.Errorf("no target named %q", ref)
+}
+
+// requireTargetArg loads the config and resolves the single target argument.
+func requireTargetArg(e *env, name string, args []string) (config.Config, int, error) {
+	if len(args) != 1 {
+		fmt.Fprintf(e.stderr, "usage: akumi %s <nickname|index>\n", name)
+		return config.Config{}, -1, errUsage
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return config.Config{}, -1, err
+	}
+	index, err := findTarget(cfg.Targets, args[0])
+	if err != nil {
+		return config.Config{}, -1, err
+	}
+	return cfg, index, nil
+}
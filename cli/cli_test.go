@@ -0,0 +1,300 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/omegaatt36/akumi/config"
+)
+
+// setupConfig points the config package at a temporary file for the test.
+func setupConfig(t *testing.T) {
+	t.Helper()
+	configPath := filepath.Join(t.TempDir(), "akumi", "config.yaml")
+	restore := config.SetConfigPathProvider(func() (string, error) {
+		return configPath, nil
+	})
+	t.Cleanup(restore)
+}
+
+// run executes a subcommand and returns its exit code and output.
+func run(args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := Run(args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestTargetCommands(t *testing.T) {
+	setupConfig(t)
+
+	if code, _, stderr := run("add", "--nickname", "dev", "--tag", "env:dev", "root@10.0.0.1:2222"); code != 0 {
+		t.Fatalf("add failed with code %d: %s", code, stderr)
+	}
+	if code, _, stderr := run("add", "deploy@web.example.com", "--group", "prod/web", "--jump", "dev"); code != 0 {
+		t.Fatalf("add failed with code %d: %s", code, stderr)
+	}
+	if code, _, _ := run("add", "--nickname", "dev", "other@host"); code != 1 {
+		t.Errorf("Expected duplicate nickname to fail, got code %d", code)
+	}
+	if code, _, _ := run("add", "--nickname", "incomplete"); code != 1 {
+		t.Errorf("Expected missing host to fail, got code %d", code)
+	}
+
+	_, stdout, _ := run("list", "--group", "prod")
+	if !strings.Contains(stdout, "deploy@web.example.com") || strings.Contains(stdout, "10.0.0.1") {
+		t.Errorf("Unexpected group-filtered list:\n%s", stdout)
+	}
+	_, stdout, _ = run("list", "--tag", "env")
+	if !strings.Contains(stdout, "10.0.0.1") || strings.Contains(stdout, "web.example.com") {
+		t.Errorf("Unexpected tag-filtered list:\n%s", stdout)
+	}
+
+	_, stdout, _ = run("show", "2")
+	if !strings.Contains(stdout, "ssh -J root@10.0.0.1:2222 deploy@web.example.com") {
+		t.Errorf("Expected resolved ssh command in show output:\n%s", stdout)
+	}
+
+	if code, _, stderr := run("edit", "dev", "--port", "22", "--user", "admin",
+		"--option", "Ciphers=aes128-ctr,aes256-ctr", "--option", "LocalCommand=echo a, b"); code != 0 {
+		t.Fatalf("edit failed with code %d: %s", code, stderr)
+	}
+	if code, _, stderr := run("rm", "2"); code != 0 {
+		t.Fatalf("rm failed with code %d: %s", code, stderr)
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if len(cfg.Targets) != 1 {
+		t.Fatalf("Expected 1 target, got %d", len(cfg.Targets))
+	}
+	if got := cfg.Targets[0]; got.User != "admin" || got.Port != 22 || got.Tags[0] != "env:dev" {
+		t.Errorf("Unexpected target after edit: %+v", got)
+	}
+	if got := cfg.Targets[0].Options; got["Ciphers"] != "aes128-ctr,aes256-ctr" || got["LocalCommand"] != "echo a, b" {
+		t.Errorf("Expected each --option to be kept whole, got %v", got)
+	}
+
+	if code, _, _ := run("rm", "missing"); code != 1 {
+		t.Errorf("Expected removing an unknown target to fail, got code %d", code)
+	}
+	if code, _, _ := run("show"); code != 2 {
+		t.Errorf("Expected usage error for show without arguments, got code %d", code)
+	}
+}
+
+func TestEditDuplicateNickname(t *testing.T) {
+	setupConfig(t)
+
+	for _, args := range [][]string{{"add", "root@a", "--nickname", "web"}, {"add", "root@b", "--nickname", "db"}} {
+		if code, _, stderr := run(args...); code != 0 {
+			t.Fatalf("add failed with code %d: %s", code, stderr)
+		}
+	}
+	if code, _, stderr := run("edit", "db", "--nickname", "web"); code != 1 || !strings.Contains(stderr, `"web" already exists`) {
+		t.Errorf("Expected renaming to a taken nickname to fail, got code %d: %s", code, stderr)
+	}
+	if code, _, stderr := run("edit", "db", "--nickname", "db", "--port", "2222"); code != 0 {
+		t.Errorf("Expected a target to keep its own nickname, got code %d: %s", code, stderr)
+	}
+	if code, _, stderr := run("list"); code != 0 {
+		t.Errorf("Expected the config to still load, got code %d: %s", code, stderr)
+	}
+}
+
+func TestListOutputFormats(t *testing.T) {
+	setupConfig(t)
+
+	run("add", "--nickname", "bastion", "jump@bastion.example.com")
+	run("add", "--nickname", "db", "--group", "prod/db", "--tag", "env:prod,role:db", "--jump", "bastion", "root@10.0.0.5:2222")
+
+	code, stdout, stderr := run("list", "--output", "json", "--tag", "role:db")
+	if code != 0 {
+		t.Fatalf("list failed with code %d: %s", code, stderr)
+	}
+
+	var entries []listEntry
+	if err := json.Unmarshal([]byte(stdout), &entries); err != nil {
+		t.Fatalf("Failed to decode JSON output: %v\n%s", err, stdout)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 entry, got %d", len(entries))
+	}
+	entry := entries[0]
+	if entry.Index != 2 || entry.Nickname != "db" || entry.Port != 2222 {
+		t.Errorf("Unexpected entry: %+v", entry)
+	}
+	if !slices.Equal(entry.Groups, []string{"prod", "prod/db"}) {
+		t.Errorf("Unexpected groups: %v", entry.Groups)
+	}
+	expectedArgv := []string{"ssh", "-p", "2222", "-J", "jump@bastion.example.com", "root@10.0.0.5"}
+	if !slices.Equal(entry.Argv, expectedArgv) {
+		t.Errorf("Expected argv %v, got %v", expectedArgv, entry.Argv)
+	}
+
+	// Empty lists keep their JSON array type for jq
+	_, stdout, _ = run("list", "--output", "json", "--group", "staging")
+	if strings.TrimSpace(stdout) != "[]" {
+		t.Errorf("Expected empty JSON array, got %q", stdout)
+	}
+
+	_, stdout, _ = run("list", "--format", `{{.Nickname}}\t{{join .Tags ","}}`)
+	if stdout != "bastion\t\ndb\tenv:prod,role:db\n" {
+		t.Errorf("Unexpected template output: %q", stdout)
+	}
+
+	_, stdout, _ = run("list", "--output", "tsv", "--tag", "env:prod")
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[1], "2\tdb\troot\t10.0.0.5\t2222\tprod/db\t") {
+		t.Errorf("Unexpected TSV output:\n%s", stdout)
+	}
+
+	if code, _, _ := run("list", "--output", "xml"); code != 1 {
+		t.Errorf("Expected unknown output format to fail, got code %d", code)
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	setupConfig(t)
+
+	run("add", "--nickname", "dev box", "--tag", "env:dev", "--group", "lab/vm", "root@10.0.0.1")
+	run("add", "--nickname", "db", "--tag", "env:prod,role:db", "root@10.0.0.5")
+	run("add", "--tag", "env:prod", "anon@10.0.0.9")
+
+	_, stdout, _ := run("__complete", "targets")
+	if stdout != "db\ndev-box\n" {
+		t.Errorf("Unexpected target candidates: %q", stdout)
+	}
+	_, stdout, _ = run("__complete", "tags")
+	if stdout != "env:dev\nenv:prod\nrole:db\n" {
+		t.Errorf("Unexpected tag candidates: %q", stdout)
+	}
+	_, stdout, _ = run("__complete", "groups")
+	if stdout != "lab\nlab/vm\n" {
+		t.Errorf("Unexpected group candidates: %q", stdout)
+	}
+	_, stdout, _ = run("__complete", "profiles")
+	if stdout != "default\n" {
+		t.Errorf("Unexpected profile candidates: %q", stdout)
+	}
+
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		code, stdout, stderr := run("completion", shell)
+		if code != 0 {
+			t.Fatalf("completion %s failed with code %d: %s", shell, code, stderr)
+		}
+		if !strings.Contains(stdout, " __complete ") || !strings.Contains(stdout, "connect") {
+			t.Errorf("%s completion script is missing dynamic target completion", shell)
+		}
+		if !strings.Contains(stdout, "--profile") || !strings.Contains(stdout, "profiles") {
+			t.Errorf("%s completion script does not complete --profile", shell)
+		}
+	}
+
+	if _, stdout, _ := run("help"); strings.Contains(stdout, "__complete") {
+		t.Error("Hidden command must not appear in help")
+	}
+}
+
+func TestBashCompletionWords(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash is not installed")
+	}
+
+	// Bash splits words at ':', so env:p arrives as env, :, p
+	script := bashCompletion() + `
+akumi() {
+    local kind=${@: -1} profile=
+    [[ $1 == --profile ]] && profile=$2-
+    case $kind in
+        tags) printf '%s\n' env:dev env:prod ;;
+        targets) printf '%s\n' ${profile}db ${profile}dev-box ;;
+        profiles) printf '%s\n' default work ;;
+    esac
+}
+COMP_WORDBREAKS=$' \t\n"\'><=;|&(:'
+complete_words() { COMP_WORDS=("$@"); COMP_CWORD=$((${#COMP_WORDS[@]} - 1)); COMPREPLY=(); _akumi; echo "${COMPREPLY[*]}"; }
+complete_words akumi --tag env : p
+complete_words akumi list --tag env :
+complete_words akumi --config x.yaml connect d
+complete_words akumi --profile work --tag env : prod con
+complete_words akumi --profile work connect w
+complete_words akumi --profile w
+`
+	out, err := exec.Command(bash, "-c", script).CombinedOutput()
+	if err != nil {
+		t.Fatalf("bash failed: %v\n%s", err, out)
+	}
+	want := "prod\ndev prod\ndb dev-box\nconnect config\nwork-db work-dev-box\nwork\n"
+	if string(out) != want {
+		t.Errorf("Unexpected completions:\n%s\nwant:\n%s", out, want)
+	}
+}
+
+func TestConfigMigrate(t *testing.T) {
+	setupConfig(t)
+
+	configPath, _ := config.GetConfigPath()
+	if err := os.MkdirAll(filepath.Dir(configPath), 0750); err != nil {
+		t.Fatalf("Failed to create config directory: %v", err)
+	}
+	legacy := "targets:\n    - user: root\n      host: 10.0.0.1\n      tags: [dev]\n"
+	if err := os.WriteFile(configPath, []byte(legacy), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	code, stdout, stderr := run("config", "migrate", "--dry-run")
+	if code != 0 {
+		t.Fatalf("migrate --dry-run failed with code %d: %s", code, stderr)
+	}
+	for _, want := range []string{"v0 → v1: add version key", "@@ -1,3 +1,4 @@", "+version: 1"} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("Expected dry-run output to contain %q:\n%s", want, stdout)
+		}
+	}
+	for _, line := range strings.Split(stdout, "\n") {
+		if strings.HasPrefix(line, "-") && !strings.HasPrefix(line, "---") {
+			t.Errorf("Expected the upgrade to only add lines, got %q:\n%s", line, stdout)
+		}
+	}
+	if data, _ := os.ReadFile(configPath); string(data) != legacy {
+		t.Errorf("Dry run modified the config:\n%s", data)
+	}
+
+	if code, _, stderr := run("config", "migrate"); code != 0 {
+		t.Fatalf("migrate failed with code %d: %s", code, stderr)
+	}
+	if _, stdout, _ := run("config", "migrate", "--dry-run"); !strings.Contains(stdout, "up to date") {
+		t.Errorf("Expected the config to be up to date, got %q", stdout)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	setupConfig(t)
+
+	run("add", "--nickname", "dev", "root@10.0.0.1")
+	if code, stdout, _ := run("config", "validate"); code != 0 || !strings.HasSuffix(stdout, ": OK\n") {
+		t.Errorf("Expected a valid config, got code %d: %s", code, stdout)
+	}
+
+	configPath, _ := config.GetConfigPath()
+	broken := "targets:\n    - user: root\n      host: 10.0.0.1\n      port: ssh\n"
+	if err := os.WriteFile(configPath, []byte(broken), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	code, _, stderr := run("config", "validate")
+	if code != 1 {
+		t.Errorf("Expected exit code 1, got %d", code)
+	}
+	if !strings.Contains(stderr, configPath+":4:13: targets[0].port:") {
+		t.Errorf("Expected the problem with its location, got %q", stderr)
+	}
+}
@@ -0,0 +1,494 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/omegaatt36/akumi/config"
+)
+
+// completionKind names a set of completion candidates.
+type completionKind string
+
+const (
+	completeNone    completionKind = ""
+	completeTargets completionKind = "targets"
+	completeTags    completionKind = "tags"
+	completeGroups  completionKind = "groups"
+	completeFiles   completionKind = "files"
+	completeOutputs completionKind = "outputs"
+	completeShells  completionKind = "shells"
+	// completeProfiles completes the names of the profiles.
+	completeProfiles completionKind = "profiles"
+	// completeConfigCommands completes the subcommands of "akumi config".
+	completeConfigCommands completionKind = "config-commands"
+)
+
+// staticCandidates lists the fixed candidates of non-dynamic kinds.
+var staticCandidates = map[completionKind][]string{
+	completeOutputs:        {"table", "json", "yaml", "tsv"},
+	completeShells:         {"bash", "zsh", "fish"},
+	completeConfigCommands: {"backups", "restore", "migrate", "validate"},
+}
+
+// flagValueKinds maps flags that take a value to the candidates completed for it.
+var flagValueKinds = map[string]completionKind{
+	"tag":      completeTags,
+	"group":    completeGroups,
+	"jump":     completeTargets,
+	"output":   completeOutputs,
+	"identity": completeFiles,
+	"path":     completeFiles,
+	"config":   completeFiles,
+	"profile":  completeProfiles,
+}
+
+// globalFlags are the flags of akumi itself, which take a value and may come
+// before the subcommand.
+var globalFlags = []string{"tag", "config", "profile"}
+
+// forwardedFlags are the global flags passed on to "akumi __complete", since
+// they select the config file the candidates are read from.
+var forwardedFlags = []string{"config", "profile"}
+
+// flagPatterns returns names as a shell case pattern matching -name and
+// --name followed by suffix
+func flagPatterns(names []string, suffix string) string {
+	var patterns []string
+	for _, name := range names {
+		patterns = append(patterns, "-"+name+suffix, "--"+name+suffix)
+	}
+	return strings.Join(patterns, "|")
+}
+
+// skippedFlags returns the global flags that are not forwarded
+func skippedFlags() []string {
+	return slices.DeleteFunc(slices.Clone(globalFlags), func(name string) bool {
+		return slices.Contains(forwardedFlags, name)
+	})
+}
+
+// globalFlagNames returns the global flags as --name words
+func globalFlagNames() string {
+	var names []string
+	for _, name := range globalFlags {
+		names = append(names, "--"+name)
+	}
+	return strings.Join(names, " ")
+}
+
+// completionFlag describes a flag for completion script generation.
+type completionFlag struct {
+	name   string
+	usage  string
+	isBool bool
+}
+
+// commandFlags returns the flags of cmd in definition order.
+func commandFlags(cmd command) []completionFlag {
+	if cmd.flags == nil {
+		return nil
+	}
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	cmd.flags(fs)
+
+	var flags []completionFlag
+	fs.VisitAll(func(f *flag.Flag) {
+		boolFlag, ok := f.Value.(interface{ IsBoolFlag() bool })
+		flags = append(flags, completionFlag{
+			name:   f.Name,
+			usage:  f.Usage,
+			isBool: ok && boolFlag.IsBoolFlag(),
+		})
+	})
+	return flags
+}
+
+// visibleCommands returns the commands offered for completion.
+func visibleCommands() []command {
+	var visible []command
+	for _, cmd := range commands {
+		if !cmd.hidden {
+			visible = append(visible, cmd)
+		}
+	}
+	return visible
+}
+
+func runCompletion(e *env, args []string) error {
+	if len(args) != 1 {
+		fmt.Fprintln(e.stderr, "usage: akumi completion bash|zsh|fish")
+		return errUsage
+	}
+
+	switch args[0] {
+	case "bash":
+		fmt.Fprint(e.stdout, bashCompletion())
+	case "zsh":
+		fmt.Fprint(e.stdout, zshCompletion())
+	case "fish":
+		fmt.Fprint(e.stdout, fishCompletion())
+	default:
+		return fmt.Errorf("unsupported shell %q (expected bash, zsh or fish)", args[0])
+	}
+	return nil
+}
+
+func runComplete(e *env, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+
+	if completionKind(args[0]) == completeProfiles {
+		profiles, err := config.ListProfiles()
+		if err != nil {
+			return err
+		}
+		for _, profile := range profiles {
+			fmt.Fprintln(e.stdout, profile)
+		}
+		return nil
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+	for _, candidate := range completionCandidates(completionKind(args[0]), cfg.Targets) {
+		fmt.Fprintln(e.stdout, candidate)
+	}
+	return nil
+}
+
+// completionCandidates returns the dynamic candidates of kind read from targets.
+func completionCandidates(kind completionKind, targets []config.SSHTarget) []string {
+	var candidates []string
+	switch kind {
+	case completeTargets:
+		for _, t := range targets {
+			if t.Nickname != "" {
+				candidates = append(candidates, t.SSHConfigAlias())
+			}
+		}
+	case completeTags:
+		for _, t := range targets {
+			candidates = append(candidates, t.Tags...)
+		}
+	case completeGroups:
+		for _, t := range targets {
+			path := t.GroupPath()
+			for i := range path {
+				candidates = append(candidates, strings.Join(path[:i+1], "/"))
+			}
+		}
+	default:
+		return staticCandidates[kind]
+	}
+
+	slices.Sort(candidates)
+	return slices.Compact(candidates)
+}
+
+// bashCompletion generates the bash completion script.
+func bashCompletion() string {
+	var b strings.Builder
+	b.WriteString(`# bash completion for akumi
+# Load with: source <(akumi completion bash)
+
+# _akumi_candidates uses the config selected by the global flags in globals
+_akumi_candidates() {
+    case "$1" in
+        targets|tags|groups|profiles) akumi "${globals[@]}" __complete "$1" 2>/dev/null ;;
+`)
+	for _, kind := range []completionKind{completeOutputs, completeShells, completeConfigCommands} {
+		fmt.Fprintf(&b, "        %s) printf '%%s\\n' %s ;;\n", kind, strings.Join(staticCandidates[kind], " "))
+	}
+	b.WriteString(`    esac
+}
+
+_akumi_reply() {
+    local IFS=$'\n'
+    if [[ "$1" == files ]]; then
+        COMPREPLY=($(compgen -f -- "$2"))
+    else
+        COMPREPLY=($(compgen -W "$(_akumi_candidates "$1")" -- "$2"))
+    fi
+    # Bash replaces only the text after the last ':' of a word such as
+    # env:prod, so the candidates must not repeat what precedes it
+    if [[ "$2" == *:* && "$COMP_WORDBREAKS" == *:* ]]; then
+        local prefix="${2%"${2##*:}"}"
+        COMPREPLY=("${COMPREPLY[@]#"$prefix"}")
+    fi
+}
+
+# _akumi_words joins the words bash split at ':' back together into words
+# and sets cword to the index of the word being completed
+_akumi_words() {
+    words=()
+    cword=0
+    local i
+    for ((i = 0; i < ${#COMP_WORDS[@]}; i++)); do
+        if ((i > 0 && ${#words[@]} > 0)) && [[ "${COMP_WORDS[i]}" == : || "${COMP_WORDS[i-1]}" == : ]]; then
+            words[${#words[@]}-1]+="${COMP_WORDS[i]}"
+        else
+            words+=("${COMP_WORDS[i]}")
+        fi
+        ((i == COMP_CWORD)) && cword=$((${#words[@]} - 1))
+    done
+}
+
+_akumi() {
+    local words cword
+    _akumi_words
+    local cur="${words[cword]}"
+    local prev="${words[cword-1]}"
+
+    # The subcommand is the first word that is neither a global flag nor its
+    # value. The flags selecting the config are kept for _akumi_candidates.
+    local i cmd= globals=()
+    for ((i = 1; i < cword; i++)); do
+        case "${words[i]}" in
+            ` + flagPatterns(forwardedFlags, "") + `)
+                ((i + 1 < cword)) && globals+=("${words[i]}" "${words[i+1]/#\~/$HOME}")
+                ((i++)) ;;
+            ` + flagPatterns(forwardedFlags, "=*") + `) globals+=("${words[i]}") ;;
+            ` + flagPatterns(skippedFlags(), "") + `) ((i++)) ;;
+            -*) ;;
+            *) cmd="${words[i]}"; break ;;
+        esac
+    done
+
+    case "$prev" in
+`)
+	for _, name := range sortedFlagNames() {
+		fmt.Fprintf(&b, "        -%s|--%s) _akumi_reply %s \"$cur\"; return ;;\n", name, name, flagValueKinds[name])
+	}
+	b.WriteString(`    esac
+
+    if [[ -z "$cmd" ]]; then
+`)
+	names := strings.Fields(globalFlagNames())
+	for _, cmd := range visibleCommands() {
+		names = append(names, cmd.name)
+	}
+	fmt.Fprintf(&b, "        COMPREPLY=($(compgen -W \"%s\" -- \"$cur\"))\n", strings.Join(names, " "))
+	b.WriteString(`        return
+    fi
+
+    if [[ "$cur" == -* ]]; then
+        case "$cmd" in
+`)
+	for _, cmd := range visibleCommands() {
+		flags := commandFlags(cmd)
+		if len(flags) == 0 {
+			continue
+		}
+		var names []string
+		for _, f := range flags {
+			names = append(names, "--"+f.name)
+		}
+		fmt.Fprintf(&b, "            %s) COMPREPLY=($(compgen -W \"%s\" -- \"$cur\")) ;;\n", cmd.name, strings.Join(names, " "))
+	}
+	b.WriteString(`        esac
+        return
+    fi
+
+    case "$cmd" in
+`)
+	for _, cmd := range visibleCommands() {
+		if cmd.args != completeNone {
+			fmt.Fprintf(&b, "        %s) _akumi_reply %s \"$cur\" ;;\n", cmd.name, cmd.args)
+		}
+	}
+	b.WriteString(`    esac
+}
+
+complete -F _akumi akumi
+`)
+	return b.String()
+}
+
+// zshCompletion generates the zsh completion script.
+func zshCompletion() string {
+	var b strings.Builder
+	b.WriteString(`#compdef akumi
+# zsh completion for akumi
+# Load with: source <(akumi completion zsh)
+
+# _akumi_candidates uses the config selected by the global flags in globals
+_akumi_candidates() {
+    case $1 in
+        targets|tags|groups|profiles) compadd -- ${(f)"$(akumi $globals __complete $1 2>/dev/null)"} ;;
+        files) _files ;;
+`)
+	for _, kind := range []completionKind{completeOutputs, completeShells, completeConfigCommands} {
+		fmt.Fprintf(&b, "        %s) compadd -- %s ;;\n", kind, strings.Join(staticCandidates[kind], " "))
+	}
+	b.WriteString(`    esac
+}
+
+_akumi() {
+    local cur=${words[CURRENT]} prev=${words[CURRENT-1]} cmd= i
+    local -a globals
+
+    # The subcommand is the first word that is neither a global flag nor its
+    # value. The flags selecting the config are kept for _akumi_candidates.
+    for ((i = 2; i < CURRENT; i++)); do
+        case ${words[i]} in
+            ` + flagPatterns(forwardedFlags, "") + `)
+                ((i + 1 < CURRENT)) && globals+=(${words[i]} ${words[i+1]/#\~/$HOME})
+                ((i++)) ;;
+            ` + flagPatterns(forwardedFlags, "=*") + `) globals+=(${words[i]}) ;;
+            ` + flagPatterns(skippedFlags(), "") + `) ((i++)) ;;
+            -*) ;;
+            *) cmd=${words[i]}; break ;;
+        esac
+    done
+
+    case $prev in
+`)
+	for _, name := range sortedFlagNames() {
+		fmt.Fprintf(&b, "        -%s|--%s) _akumi_candidates %s; return ;;\n", name, name, flagValueKinds[name])
+	}
+	b.WriteString(`    esac
+
+    if [[ -z $cmd ]]; then
+        local -a subcommands
+        subcommands=(
+`)
+	for _, cmd := range visibleCommands() {
+		fmt.Fprintf(&b, "            '%s:%s'\n", cmd.name, cmd.summary)
+	}
+	b.WriteString(`        )
+        _describe 'command' subcommands
+        compadd -- ` + globalFlagNames() + `
+        return
+    fi
+
+    if [[ $cur == -* ]]; then
+        case $cmd in
+`)
+	for _, cmd := range visibleCommands() {
+		flags := commandFlags(cmd)
+		if len(flags) == 0 {
+			continue
+		}
+		var names []string
+		for _, f := range flags {
+			names = append(names, "--"+f.name)
+		}
+		fmt.Fprintf(&b, "            %s) compadd -- %s ;;\n", cmd.name, strings.Join(names, " "))
+	}
+	b.WriteString(`        esac
+        return
+    fi
+
+    case $cmd in
+`)
+	for _, cmd := range visibleCommands() {
+		if cmd.args != completeNone {
+			fmt.Fprintf(&b, "        %s) _akumi_candidates %s ;;\n", cmd.name, cmd.args)
+		}
+	}
+	b.WriteString(`    esac
+}
+
+compdef _akumi akumi
+`)
+	return b.String()
+}
+
+// fishCompletion generates the fish completion script.
+func fishCompletion() string {
+	var b strings.Builder
+	b.WriteString(`# fish completion for akumi
+# Load with: akumi completion fish | source
+
+# __akumi_complete prints the candidates of a kind from the config selected
+# by the global flags on the command line
+function __akumi_complete
+    set -l tokens (commandline -opc)
+    set -l globals
+    set -l i 2
+    while test $i -le (count $tokens)
+        switch $tokens[$i]
+            case ` + fishFlagPatterns(forwardedFlags, "") + `
+                set i (math $i + 1)
+                test $i -le (count $tokens); and set -a globals $tokens[(math $i - 1)] (string replace -r '^~' $HOME -- $tokens[$i])
+            case ` + fishFlagPatterns(forwardedFlags, "=*") + `
+                set -a globals $tokens[$i]
+            case ` + fishFlagPatterns(skippedFlags(), "") + `
+                set i (math $i + 1)
+            case '-*'
+            case '*'
+                break
+        end
+        set i (math $i + 1)
+    end
+    akumi $globals __complete $argv 2>/dev/null
+end
+
+complete -c akumi -f
+complete -c akumi -n '__fish_use_subcommand' -l tag -x -a '(__akumi_complete tags)' -d 'Only show targets matching the tag selector'
+complete -c akumi -n '__fish_use_subcommand' -l config -r -F -d 'Use this config file instead of the default location'
+complete -c akumi -n '__fish_use_subcommand' -l profile -x -a '(__akumi_complete profiles)' -d 'Use the named profile, created on first use'
+`)
+	for _, cmd := range visibleCommands() {
+		fmt.Fprintf(&b, "complete -c akumi -n '__fish_use_subcommand' -a %s -d %s\n", cmd.name, fishQuote(cmd.summary))
+	}
+	for _, cmd := range visibleCommands() {
+		condition := fmt.Sprintf("'__fish_seen_subcommand_from %s'", cmd.name)
+		if cmd.args != completeNone {
+			fmt.Fprintf(&b, "complete -c akumi -n %s -a %s\n", condition, fishCandidates(cmd.args))
+		}
+		for _, f := range commandFlags(cmd) {
+			line := fmt.Sprintf("complete -c akumi -n %s -l %s", condition, f.name)
+			switch kind := flagValueKinds[f.name]; {
+			case f.isBool:
+			case kind == completeFiles:
+				line += " -r -F"
+			case kind != completeNone:
+				line += " -x -a " + fishCandidates(kind)
+			default:
+				line += " -x"
+			}
+			b.WriteString(line + " -d " + fishQuote(f.usage) + "\n")
+		}
+	}
+	return b.String()
+}
+
+// fishCandidates returns the fish argument list expression for kind.
+func fishCandidates(kind completionKind) string {
+	if static, ok := staticCandidates[kind]; ok {
+		return fishQuote(strings.Join(static, " "))
+	}
+	return fmt.Sprintf("'(__akumi_complete %s)'", kind)
+}
+
+// fishFlagPatterns returns names as quoted fish switch patterns matching
+// -name and --name followed by suffix
+func fishFlagPatterns(names []string, suffix string) string {
+	var patterns []string
+	for _, name := range names {
+		patterns = append(patterns, fishQuote("-"+name+suffix), fishQuote("--"+name+suffix))
+	}
+	return strings.Join(patterns, " ")
+}
+
+// fishQuote wraps s in single quotes for fish.
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
+
+// sortedFlagNames returns the value flags with completions in a stable order.
+func sortedFlagNames() []string {
+	names := make([]string, 0, len(flagValueKinds))
+	for name := range flagValueKinds {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
@@ -0,0 +1,145 @@
+package cli
+
+import (
+	"fmt"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/omegaatt36/akumi/config"
+)
+
+// configCommands lists the subcommands of "akumi config".
+var configCommands = map[string]func(e *env, args []string) error{
+	"backups":  runConfigBackups,
+	"restore":  runConfigRestore,
+	"migrate":  runConfigMigrate,
+	"validate": runConfigValidate,
+}
+
+func runConfig(e *env, args []string) error {
+	if len(args) == 0 {
+		fmt.Fprintln(e.stderr, "usage: akumi config backups|restore|migrate|validate [args]")
+		return errUsage
+	}
+
+	run, ok := configCommands[args[0]]
+	if !ok {
+		fmt.Fprintf(e.stderr, "akumi config: unknown command %q\n", args[0])
+		return errUsage
+	}
+	return run(e, args[1:])
+}
+
+func runConfigBackups(e *env, _ []string) error {
+	backups, err := config.ListBackups()
+	if err != nil {
+		return err
+	}
+	if len(backups) == 0 {
+		fmt.Fprintln(e.stdout, "No backups found")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(e.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "#\tTAKEN\tPATH")
+	for i, backup := range backups {
+		fmt.Fprintf(w, "%d\t%s\t%s\n", i+1, backup.Time.Format(time.DateTime), backup.Path)
+	}
+	return w.Flush()
+}
+
+func runConfigRestore(e *env, args []string) error {
+	if len(args) == 0 {
+		// Without an argument, show what can be restored
+		return runConfigBackups(e, nil)
+	}
+	if len(args) != 1 {
+		fmt.Fprintln(e.stderr, "usage: akumi config restore [index|path]")
+		return errUsage
+	}
+
+	path := args[0]
+	if n, err := strconv.Atoi(args[0]); err == nil {
+		backups, err := config.ListBackups()
+		if err != nil {
+			return err
+		}
+		if n < 1 || n > len(backups) {
+			return fmt.Errorf("backup %d out of range (1-%d)", n, len(backups))
+		}
+		path = backups[n-1].Path
+	}
+
+	if err := config.RestoreBackup(path); err != nil {
+		return err
+	}
+	fmt.Fprintf(e.stdout, "Restored configuration from %s\n", path)
+	return nil
+}
+
+func runConfigMigrate(e *env, args []string) error {
+	fs := e.newFlagSet("config migrate")
+	dryRun := fs.Bool("dry-run", false, "Print the changes as a diff without writing them")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 0 {
+		fmt.Fprintln(e.stderr, "usage: akumi config migrate [--dry-run]")
+		return errUsage
+	}
+
+	plan, err := config.PlanMigration()
+	if err != nil {
+		return err
+	}
+	if !plan.NeedsMigration() {
+		fmt.Fprintf(e.stdout, "Configuration is up to date (version %d)\n", config.CurrentVersion)
+		return nil
+	}
+
+	for _, step := range plan.Steps {
+		fmt.Fprintf(e.stdout, "%s\n", step)
+	}
+	if *dryRun {
+		writeUnifiedDiff(e.stdout, plan.Path, plan.Path+" (migrated)", string(plan.Before), string(plan.After))
+		return nil
+	}
+
+	if err := plan.Apply(); err != nil {
+		return err
+	}
+	fmt.Fprintf(e.stdout, "Migrated %s from version %d to %d (previous version backed up)\n", plan.Path, plan.From, config.CurrentVersion)
+	return nil
+}
+
+func runConfigValidate(e *env, args []string) error {
+	if len(args) != 0 {
+		fmt.Fprintln(e.stderr, "usage: akumi config validate")
+		return errUsage
+	}
+
+	path, problems, err := config.ValidateConfigFile()
+	if err != nil {
+		return err
+	}
+	if len(problems) == 0 {
+		fmt.Fprintf(e.stdout, "%s: OK\n", path)
+		return nil
+	}
+
+	failures := 0
+	for _, problem := range problems {
+		fmt.Fprintf(e.stderr, "%s:%v\n", path, problem)
+		if !problem.Warning {
+			failures++
+		}
+	}
+	if failures == 0 {
+		fmt.Fprintf(e.stdout, "%s: OK with %d warning(s)\n", path, len(problems))
+		return nil
+	}
+	fmt.Fprintf(e.stderr, "%d problem(s) found\n", failures)
+	return exitError{code: 1}
+}
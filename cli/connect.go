@@ -0,0 +1,60 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/omegaatt36/akumi/config"
+	"github.com/omegaatt36/akumi/connector"
+)
+
+func runConnect(e *env, args []string) error {
+	cfg, index, err := requireTargetArg(e, "connect", args)
+	if err != nil {
+		return err
+	}
+
+	target := cfg.Targets[index]
+	resolved := cfg.Defaults.Resolve(target, cfg.Targets)
+	c, err := connector.New(cfg.Connection, resolved)
+	if err != nil {
+		return err
+	}
+	session, err := c.Connect(resolved)
+	if err != nil {
+		return err
+	}
+	for _, warning := range cfg.Defaults.JumpWarnings(target, cfg.Targets) {
+		fmt.Fprintf(e.stderr, "akumi: warning: %s\n", warning)
+	}
+	fmt.Fprintf(e.stderr, "Connecting to %s...\n", target)
+
+	session.SetStdin(os.Stdin)
+	session.SetStdout(os.Stdout)
+	session.SetStderr(os.Stderr)
+
+	err = session.Run()
+	if code, ok := connector.ExitCode(err); ok {
+		return exitError{code: code}
+	}
+	return err
+}
+
+// commandLine returns the command line the connector of t runs, or nil when
+// the connector does not start a program or cannot be set up
+func commandLine(cfg config.Config, t config.SSHTarget) []string {
+	resolved := cfg.Defaults.Resolve(t, cfg.Targets)
+	c, err := connector.New(cfg.Connection, resolved)
+	if err != nil {
+		return nil
+	}
+	commander, ok := c.(connector.Commander)
+	if !ok {
+		return nil
+	}
+	argv, err := commander.Command(resolved)
+	if err != nil {
+		return nil
+	}
+	return argv
+}
@@ -0,0 +1,123 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around each change.
+const diffContext = 3
+
+// diffOp is one line of a line-based diff.
+type diffOp struct {
+	kind byte // ' ', '-' or '+'
+	line string
+}
+
+// diffLines computes a line diff of a and b from their longest common subsequence.
+func diffLines(a, b []string) []diffOp {
+	// lcs[i][j] is the LCS length of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var ops []diffOp
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, diffOp{' ', a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, diffOp{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		ops = append(ops, diffOp{'-', a[i]})
+	}
+	for ; j < len(b); j++ {
+		ops = append(ops, diffOp{'+', b[j]})
+	}
+	return ops
+}
+
+// splitLines splits text into lines without their trailing newlines.
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// writeUnifiedDiff writes a unified diff between before and after to w.
+func writeUnifiedDiff(w io.Writer, oldName, newName, before, after string) {
+	ops := diffLines(splitLines(before), splitLines(after))
+
+	fmt.Fprintf(w, "--- %s\n+++ %s\n", oldName, newName)
+	for start := 0; start < len(ops); {
+		// Find the next change
+		for start < len(ops) && ops[start].kind == ' ' {
+			start++
+		}
+		if start == len(ops) {
+			break
+		}
+
+		// Extend the hunk while changes are within two contexts of each other
+		first := max(start-diffContext, 0)
+		end := start
+		for unchanged := 0; end < len(ops) && unchanged <= 2*diffContext; end++ {
+			if ops[end].kind == ' ' {
+				unchanged++
+			} else {
+				unchanged = 0
+			}
+		}
+		for end > start && ops[end-1].kind == ' ' {
+			end--
+		}
+		last := min(end+diffContext, len(ops))
+
+		// Line numbers of the hunk in both files
+		oldLine, newLine := 1, 1
+		for _, op := range ops[:first] {
+			if op.kind != '+' {
+				oldLine++
+			}
+			if op.kind != '-' {
+				newLine++
+			}
+		}
+		oldCount, newCount := 0, 0
+		for _, op := range ops[first:last] {
+			if op.kind != '+' {
+				oldCount++
+			}
+			if op.kind != '-' {
+				newCount++
+			}
+		}
+
+		fmt.Fprintf(w, "@@ -%d,%d +%d,%d @@\n", oldLine, oldCount, newLine, newCount)
+		for _, op := range ops[first:last] {
+			fmt.Fprintf(w, "%c%s\n", op.kind, op.line)
+		}
+		start = last
+	}
+}
@@ -0,0 +1,145 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/omegaatt36/akumi/config"
+)
+
+// listEntry is the stable machine-readable representation of a target.
+type listEntry struct {
+	// Index is the 1-based position accepted by show, edit, rm and connect.
+	Index    int    `json:"index" yaml:"index"`
+	Nickname string `json:"nickname" yaml:"nickname"`
+	User     string `json:"user" yaml:"user"`
+	Host     string `json:"host" yaml:"host"`
+	Port     int    `json:"port" yaml:"port"`
+	// Group is the full group path, e.g. "prod/db".
+	Group string `json:"group" yaml:"group"`
+	// Groups lists the group and each of its ancestors, e.g. ["prod", "prod/db"].
+	Groups []string `json:"groups" yaml:"groups"`
+	Tags   []string `json:"tags" yaml:"tags"`
+	// Argv is the exact command line used to connect, e.g. starting with
+	// "ssh", or empty when the connector does not run a program.
+	Argv []string `json:"argv" yaml:"argv"`
+	// Origin is the shared config file defining the target, empty for the
+	// user's own targets.
+	Origin string `json:"origin,omitempty" yaml:"origin,omitempty"`
+}
+
+// newListEntry builds the list entry for the target at index of cfg.
+func newListEntry(index int, cfg config.Config) listEntry {
+	t := cfg.Targets[index]
+
+	groups := []string{}
+	path := t.GroupPath()
+	for i := range path {
+		groups = append(groups, strings.Join(path[:i+1], "/"))
+	}
+
+	tags := t.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+
+	return listEntry{
+		Index:    index + 1,
+		Nickname: t.Nickname,
+		User:     t.User,
+		Host:     t.Host,
+		Port:     t.Port,
+		Group:    t.Group,
+		Groups:   groups,
+		Tags:     tags,
+		Argv:     commandLine(cfg, t),
+		Origin:   t.Origin,
+	}
+}
+
+// writeEntries renders entries in the named output format.
+func writeEntries(w io.Writer, output string, entries []listEntry) error {
+	switch output {
+	case "table":
+		return writeTable(w, entries)
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(entries)
+	case "yaml":
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(entries); err != nil {
+			return err
+		}
+		return enc.Close()
+	case "tsv":
+		return writeTSV(w, entries)
+	default:
+		return fmt.Errorf("unknown output format %q (expected table, json, yaml or tsv)", output)
+	}
+}
+
+// writeTable renders entries as an aligned, human-readable table.
+func writeTable(w io.Writer, entries []listEntry) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "#\tNICKNAME\tTARGET\tGROUP\tTAGS")
+	for _, entry := range entries {
+		address := config.SSHTarget{User: entry.User, Host: entry.Host, Port: entry.Port}.JumpAddress()
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\n", entry.Index, entry.Nickname, address, entry.Group, strings.Join(entry.Tags, ","))
+	}
+	return tw.Flush()
+}
+
+// writeTSV renders entries as tab-separated values with a header row.
+// Tags are comma-separated and the argv is space-separated.
+func writeTSV(w io.Writer, entries []listEntry) error {
+	fmt.Fprintln(w, "index\tnickname\tuser\thost\tport\tgroup\ttags\targv")
+	for _, entry := range entries {
+		fields := []string{
+			fmt.Sprint(entry.Index),
+			entry.Nickname,
+			entry.User,
+			entry.Host,
+			fmt.Sprint(entry.Port),
+			entry.Group,
+			strings.Join(entry.Tags, ","),
+			strings.Join(entry.Argv, " "),
+		}
+		for i, field := range fields {
+			fields[i] = strings.NewReplacer("\t", " ", "\n", " ").Replace(field)
+		}
+		if _, err := fmt.Fprintln(w, strings.Join(fields, "\t")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeTemplate executes a text/template once per entry, each followed by a newline.
+// Escape sequences such as \t and \n in the format are interpreted.
+func writeTemplate(w io.Writer, format string, entries []listEntry) error {
+	format = strings.NewReplacer(`\t`, "\t", `\n`, "\n").Replace(format)
+	tmpl, err := template.New("format").Funcs(template.FuncMap{
+		"join": strings.Join,
+	}).Parse(format)
+	if err != nil {
+		return fmt.Errorf("invalid format template: %w", err)
+	}
+
+	for _, entry := range entries {
+		if err := tmpl.Execute(w, entry); err != nil {
+			return fmt.Errorf("failed to execute format template: %w", err)
+		}
+		if _, err := fmt.Fprintln(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/omegaatt36/akumi/config"
+)
+
+// importFlags holds the flags of the import command.
+type importFlags struct {
+	path   string
+	dryRun bool
+}
+
+// register adds the import flags to fs.
+func (f *importFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.path, "path", "", "OpenSSH client config to import (default ~/.ssh/config)")
+	fs.BoolVar(&f.dryRun, "dry-run", false, "Only show what would be imported")
+}
+
+// exportFlags holds the flags of the export command.
+type exportFlags struct {
+	path     string
+	toStdout bool
+}
+
+// register adds the export flags to fs.
+func (f *exportFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.path, "path", "", "Managed include file to write (default ~/.ssh/config.d/akumi)")
+	fs.BoolVar(&f.toStdout, "stdout", false, "Print the Host blocks instead of writing a file")
+}
+
+func runImport(e *env, args []string) error {
+	fs := e.newFlagSet("import")
+	var f importFlags
+	f.register(fs)
+	if _, err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	path := f.path
+	if path == "" {
+		defaultPath, err := config.DefaultSSHConfigPath()
+		if err != nil {
+			return err
+		}
+		path = defaultPath
+	}
+
+	imported, err := config.ImportSSHConfig(path)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+
+	added := 0
+	for _, candidate := range config.ClassifyImport(cfg.Targets, imported) {
+		if candidate.Duplicate {
+			fmt.Fprintf(e.stdout, "[dup] %s\n", candidate.Target)
+			continue
+		}
+		fmt.Fprintf(e.stdout, "[new] %s\n", candidate.Target)
+		cfg.Targets = append(cfg.Targets, candidate.Target)
+		added++
+	}
+
+	if f.dryRun || added == 0 {
+		fmt.Fprintf(e.stdout, "%d new connection(s) found\n", added)
+		return nil
+	}
+	if err := config.SaveConfig(cfg); err != nil {
+		return err
+	}
+	fmt.Fprintf(e.stdout, "Imported %d connection(s)\n", added)
+	return nil
+}
+
+func runExport(e *env, args []string) error {
+	fs := e.newFlagSet("export")
+	var f exportFlags
+	f.register(fs)
+	if _, err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+
+	if f.toStdout {
+		fmt.Fprint(e.stdout, config.RenderSSHConfig(cfg.Defaults.ApplyAll(cfg.Targets)))
+		return nil
+	}
+
+	path := f.path
+	if path == "" {
+		defaultPath, err := config.DefaultSSHExportPath()
+		if err != nil {
+			return err
+		}
+		path = defaultPath
+	}
+	if err := config.WriteManagedSSHConfig(path, cfg.Defaults.ApplyAll(cfg.Targets)); err != nil {
+		return err
+	}
+	fmt.Fprintf(e.stdout, "Exported %d connection(s) to %s\n", len(cfg.Targets), path)
+	return nil
+}
@@ -0,0 +1,304 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/omegaatt36/akumi/config"
+)
+
+// targetFlags binds the editable SSHTarget fields to command line flags.
+type targetFlags struct {
+	user         string
+	host         string
+	port         int
+	nickname     string
+	group        string
+	tags         stringsFlag
+	identityFile string
+	proxyJump    string
+	forwardAgent bool
+	options      stringsFlag
+	connector    string
+}
+
+// register adds the target field flags to fs.
+func (f *targetFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.user, "user", "", "SSH username")
+	fs.StringVar(&f.host, "host", "", "SSH server hostname or IP address")
+	fs.IntVar(&f.port, "port", 22, "SSH server port")
+	fs.StringVar(&f.nickname, "nickname", "", "Display name for the target")
+	fs.StringVar(&f.group, "group", "", "Group path such as prod/db")
+	fs.Var(&f.tags, "tag", "Tag such as env:prod (repeatable, comma-separated)")
+	fs.StringVar(&f.identityFile, "identity", "", "Private key passed to ssh with -i")
+	fs.StringVar(&f.proxyJump, "jump", "", "Jump host: nickname of another target or user@host:port")
+	fs.BoolVar(&f.forwardAgent, "forward-agent", false, "Forward the authentication agent")
+	fs.Var(&f.options, "option", "ssh option Key=Value (repeatable)")
+	fs.StringVar(&f.connector, "connector", "", "Connector: openssh, mosh, builtin or a name from connection.templates")
+}
+
+// apply copies every flag that was set on the command line into target.
+func (f *targetFlags) apply(fs *flag.FlagSet, target *config.SSHTarget) error {
+	var err error
+	fs.Visit(func(fl *flag.Flag) {
+		switch fl.Name {
+		case "user":
+			target.User = strings.TrimSpace(f.user)
+		case "host":
+			target.Host = strings.TrimSpace(f.host)
+		case "port":
+			target.Port = f.port
+		case "nickname":
+			target.Nickname = strings.TrimSpace(f.nickname)
+		case "group":
+			target.Group = config.NormalizeGroup(f.group)
+		case "tag":
+			target.Tags = config.ParseTags(strings.Join(f.tags, ","))
+		case "identity":
+			target.IdentityFile = strings.TrimSpace(f.identityFile)
+		case "jump":
+			target.ProxyJump = strings.TrimSpace(f.proxyJump)
+		case "forward-agent":
+			target.ForwardAgent = f.forwardAgent
+		case "connector":
+			target.Connector = strings.TrimSpace(f.connector)
+		case "option":
+			// Each flag holds one option, so its value may contain commas
+			var options map[string]string
+			for _, option := range f.options {
+				var parsed map[string]string
+				if parsed, err = config.ParseOptions(option); err != nil {
+					return
+				}
+				if options == nil {
+					options = make(map[string]string)
+				}
+				maps.Copy(options, parsed)
+			}
+			target.Options = options
+		}
+	})
+	return err
+}
+
+// validateTarget checks the fields required to connect to a target. The
+// username may be left empty when the profile has a default user.
+func validateTarget(target config.SSHTarget, defaults config.TargetDefaults) error {
+	if (target.User == "" && defaults.User == "") || target.Host == "" {
+		return fmt.Errorf("username and host cannot be empty")
+	}
+	if target.Port <= 0 || target.Port > 65535 {
+		return fmt.Errorf("port must be a valid number between 1-65535")
+	}
+	return nil
+}
+
+// parseDestination parses a [user@]host[:port] argument into target.
+func parseDestination(dest string, target *config.SSHTarget) error {
+	if user, host, found := strings.Cut(dest, "@"); found {
+		target.User = user
+		dest = host
+	}
+	if host, portStr, found := strings.Cut(dest, ":"); found {
+		port, err := strconv.Atoi(portStr)
+		if err != nil {
+			return fmt.Errorf("invalid port in %q", dest)
+		}
+		target.Port = port
+		dest = host
+	}
+	target.Host = dest
+	return nil
+}
+
+// filterTargets returns the indexes of targets matching every tag selector and group.
+func filterTargets(targets []config.SSHTarget, tags []string, group string) []int {
+	group = config.NormalizeGroup(group)
+	var indexes []int
+	for i, t := range targets {
+		if !t.HasTags(tags...) {
+			continue
+		}
+		if group != "" && t.Group != group && !strings.HasPrefix(t.Group, group+"/") {
+			continue
+		}
+		indexes = append(indexes, i)
+	}
+	return indexes
+}
+
+// listFlags holds the flags of the list command.
+type listFlags struct {
+	tags   stringsFlag
+	group  string
+	output string
+	format string
+}
+
+// register adds the list flags to fs.
+func (f *listFlags) register(fs *flag.FlagSet) {
+	fs.Var(&f.tags, "tag", "Only list targets matching the tag selector (repeatable)")
+	fs.StringVar(&f.group, "group", "", "Only list targets in the group or its subgroups")
+	fs.StringVar(&f.output, "output", "table", "Output format: table, json, yaml or tsv")
+	fs.StringVar(&f.format, "format", "", "Go text/template applied to each target, e.g. '{{.Nickname}}\\t{{.Host}}'")
+}
+
+func runList(e *env, args []string) error {
+	fs := e.newFlagSet("list")
+	var f listFlags
+	f.register(fs)
+	if _, err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+
+	indexes := filterTargets(cfg.Targets, config.ParseTags(strings.Join(f.tags, ",")), f.group)
+	entries := make([]listEntry, 0, len(indexes))
+	for _, i := range indexes {
+		entries = append(entries, newListEntry(i, cfg))
+	}
+
+	if f.format != "" {
+		return writeTemplate(e.stdout, f.format, entries)
+	}
+	return writeEntries(e.stdout, f.output, entries)
+}
+
+func runShow(e *env, args []string) error {
+	cfg, index, err := requireTargetArg(e, "show", args)
+	if err != nil {
+		return err
+	}
+	t := cfg.Targets[index]
+
+	w := tabwriter.NewWriter(e.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "Index:\t%d\n", index+1)
+	fmt.Fprintf(w, "Nickname:\t%s\n", t.Nickname)
+	fmt.Fprintf(w, "User:\t%s\n", t.User)
+	fmt.Fprintf(w, "Host:\t%s\n", t.Host)
+	fmt.Fprintf(w, "Port:\t%d\n", t.Port)
+	fmt.Fprintf(w, "Group:\t%s\n", t.Group)
+	fmt.Fprintf(w, "Tags:\t%s\n", strings.Join(t.Tags, ", "))
+	fmt.Fprintf(w, "Identity file:\t%s\n", t.IdentityFile)
+	fmt.Fprintf(w, "Jump host:\t%s\n", t.ProxyJump)
+	fmt.Fprintf(w, "Forward agent:\t%t\n", t.ForwardAgent)
+	fmt.Fprintf(w, "Options:\t%s\n", config.FormatOptions(t.Options))
+	if t.Shared() {
+		fmt.Fprintf(w, "Origin:\t%s (read-only)\n", t.Origin)
+	}
+	fmt.Fprintf(w, "Connector:\t%s\n", cfg.Connection.ConnectorFor(t))
+	if argv := commandLine(cfg, t); argv != nil {
+		fmt.Fprintf(w, "Command:\t%s\n", strings.Join(argv, " "))
+	}
+	return w.Flush()
+}
+
+func runAdd(e *env, args []string) error {
+	fs := e.newFlagSet("add")
+	var f targetFlags
+	f.register(fs)
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 1 {
+		fmt.Fprintln(e.stderr, "usage: akumi add [flags] [user@]host[:port]")
+		return errUsage
+	}
+
+	target := config.SSHTarget{Port: 22}
+	if len(positional) == 1 {
+		if err := parseDestination(positional[0], &target); err != nil {
+			return err
+		}
+	}
+	if err := f.apply(fs, &target); err != nil {
+		return err
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+	if err := validateTarget(target, cfg.Defaults); err != nil {
+		return err
+	}
+	if target.Nickname != "" {
+		if _, exists := config.FindTargetByNickname(cfg.Targets, target.Nickname); exists {
+			return fmt.Errorf("a target named %q already exists", target.Nickname)
+		}
+	}
+
+	cfg.Targets = append(cfg.Targets, target)
+	if err := config.SaveConfig(cfg); err != nil {
+		return err
+	}
+	fmt.Fprintf(e.stdout, "Added %s\n", target)
+	return nil
+}
+
+func runEdit(e *env, args []string) error {
+	fs := e.newFlagSet("edit")
+	var f targetFlags
+	f.register(fs)
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+
+	cfg, index, err := requireTargetArg(e, "edit", positional)
+	if err != nil {
+		return err
+	}
+
+	target := cfg.Targets[index]
+	if target.Shared() {
+		return &config.ReadOnlyError{Target: target}
+	}
+	if err := f.apply(fs, &target); err != nil {
+		return err
+	}
+	if err := validateTarget(target, cfg.Defaults); err != nil {
+		return err
+	}
+	if target.Nickname != "" {
+		others := slices.Delete(slices.Clone(cfg.Targets), index, index+1)
+		if _, exists := config.FindTargetByNickname(others, target.Nickname); exists {
+			return fmt.Errorf("a target named %q already exists", target.Nickname)
+		}
+	}
+
+	cfg.Targets[index] = target
+	if err := config.SaveConfig(cfg); err != nil {
+		return err
+	}
+	fmt.Fprintf(e.stdout, "Updated %s\n", target)
+	return nil
+}
+
+func runRemove(e *env, args []string) error {
+	cfg, index, err := requireTargetArg(e, "rm", args)
+	if err != nil {
+		return err
+	}
+
+	removed := cfg.Targets[index]
+	if removed.Shared() {
+		return &config.ReadOnlyError{Target: removed}
+	}
+	cfg.Targets = slices.Delete(cfg.Targets, index, index+1)
+	if err := config.SaveConfig(cfg); err != nil {
+		return err
+	}
+	fmt.Fprintf(e.stdout, "Removed %s\n", removed)
+	return nil
+}
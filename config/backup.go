@@ -0,0 +1,191 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// MaxBackups is the number of timestamped backups kept next to the config file.
+	MaxBackups = 10
+	// backupSuffix is appended to the timestamp of every backup file name.
+	backupSuffix = ".bak"
+	// backupTimeFormat is the timestamp layout used in backup file names.
+	backupTimeFormat = "20060102-150405.000000"
+)
+
+// Backup describes a timestamped copy of the configuration file.
+type Backup struct {
+	// Path is the location of the backup file.
+	Path string
+	// Time is when the backup was taken.
+	Time time.Time
+}
+
+// writeConfigFile backs up the existing config and atomically replaces it with data.
+func writeConfigFile(configPath string, data []byte) error {
+	if err := backupConfigFile(configPath); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(configPath, data, 0640); err != nil {
+		return fmt.Errorf("failed to write config file %s: %w", configPath, err)
+	}
+	return nil
+}
+
+// writeFileAtomic writes data to a temporary file in the same directory, syncs
+// it to disk and renames it over path, so readers never see a partial file.
+// If path is a symlink, as in dotfiles setups, the file it points to is
+// replaced and the link is kept. An existing file keeps its mode; perm is
+// used when the file is created.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	// Clean up the temporary file unless the rename succeeded
+	defer os.Remove(tmpPath)
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+
+	// Persist the rename itself; not every platform supports syncing directories
+	if d, err := os.Open(dir); err == nil {
+		_ = d.Sync()
+		d.Close()
+	}
+	return nil
+}
+
+// backupConfigFile copies the current config file to a timestamped backup and
+// removes the oldest backups beyond MaxBackups. A missing config is not an error.
+func backupConfigFile(configPath string) error {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read config file for backup %s: %w", configPath, err)
+	}
+
+	backupPath := configPath + "." + time.Now().Format(backupTimeFormat) + backupSuffix
+	if err := writeFileAtomic(backupPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write backup %s: %w", backupPath, err)
+	}
+
+	return pruneBackups(configPath)
+}
+
+// pruneBackups removes all but the newest MaxBackups backups of configPath.
+func pruneBackups(configPath string) error {
+	backups, err := listBackups(configPath)
+	if err != nil {
+		return err
+	}
+	for _, backup := range backups[min(len(backups), MaxBackups):] {
+		if err := os.Remove(backup.Path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove old backup %s: %w", backup.Path, err)
+		}
+	}
+	return nil
+}
+
+// ListBackups returns the backups of the configuration file, newest first.
+func ListBackups() ([]Backup, error) {
+	configPath, err := GetConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	return listBackups(configPath)
+}
+
+// listBackups finds the backups of configPath, newest first.
+func listBackups(configPath string) ([]Backup, error) {
+	entries, err := os.ReadDir(filepath.Dir(configPath))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to list backups: %w", err)
+	}
+
+	prefix := filepath.Base(configPath) + "."
+	var backups []Backup
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, backupSuffix) {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, prefix), backupSuffix)
+		t, err := time.ParseInLocation(backupTimeFormat, stamp, time.Local)
+		if err != nil {
+			continue
+		}
+		backups = append(backups, Backup{
+			Path: filepath.Join(filepath.Dir(configPath), name),
+			Time: t,
+		})
+	}
+
+	slices.SortFunc(backups, func(a, b Backup) int {
+		return b.Time.Compare(a.Time)
+	})
+	return backups, nil
+}
+
+// RestoreBackup replaces the configuration file with the backup at path.
+// The current configuration is backed up first so the restore can be undone.
+func RestoreBackup(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read backup %s: %w", path, err)
+	}
+
+	// Refuse to restore a file that would fail to load
+	if _, err := parseDocument(data); err != nil {
+		return fmt.Errorf("backup %s is not a valid config: %w", path, err)
+	}
+
+	configPath, err := GetConfigPath()
+	if err != nil {
+		return err
+	}
+	if err := ensureConfigDirExists(configPath); err != nil {
+		return err
+	}
+
+	unlock, err := lockConfig(configPath, true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return writeConfigFile(configPath, data)
+}
@@ -0,0 +1,130 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSaveConfigBackups(t *testing.T) {
+	testDir := t.TempDir()
+	configPath := filepath.Join(testDir, "akumi", "config.yaml")
+	restoreConfigPath := SetConfigPathProvider(func() (string, error) {
+		return configPath, nil
+	})
+	defer restoreConfigPath()
+
+	// The first save has nothing to back up
+	first := Config{Targets: []SSHTarget{{User: "root", Host: "first.example.com", Port: 22}}}
+	if err := SaveConfig(first); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	if backups, _ := ListBackups(); len(backups) != 0 {
+		t.Fatalf("Expected no backups after the first save, got %d", len(backups))
+	}
+
+	for i := 0; i < MaxBackups+2; i++ {
+		cfg := Config{Targets: []SSHTarget{{User: "root", Host: "second.example.com", Port: 22 + i}}}
+		if err := SaveConfig(cfg); err != nil {
+			t.Fatalf("Failed to save config: %v", err)
+		}
+	}
+
+	backups, err := ListBackups()
+	if err != nil {
+		t.Fatalf("Failed to list backups: %v", err)
+	}
+	if len(backups) != MaxBackups {
+		t.Fatalf("Expected %d backups, got %d", MaxBackups, len(backups))
+	}
+	for i := 1; i < len(backups); i++ {
+		if backups[i].Time.After(backups[i-1].Time) {
+			t.Fatal("Expected backups sorted newest first")
+		}
+	}
+
+	// No temporary files may be left behind
+	entries, _ := os.ReadDir(filepath.Dir(configPath))
+	for _, entry := range entries {
+		if strings.Contains(entry.Name(), ".tmp-") {
+			t.Errorf("Temporary file left behind: %s", entry.Name())
+		}
+	}
+
+	// Restoring the newest backup brings back the previous save
+	if err := RestoreBackup(backups[0].Path); err != nil {
+		t.Fatalf("Failed to restore backup: %v", err)
+	}
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("Failed to load restored config: %v", err)
+	}
+	if cfg.Targets[0].Port != 22+MaxBackups {
+		t.Errorf("Expected port %d after restore, got %d", 22+MaxBackups, cfg.Targets[0].Port)
+	}
+
+	// Invalid backups are rejected
+	invalid := filepath.Join(testDir, "invalid.bak")
+	if err := os.WriteFile(invalid, []byte("targets: ["), 0600); err != nil {
+		t.Fatalf("Failed to write invalid backup: %v", err)
+	}
+	if err := RestoreBackup(invalid); err == nil {
+		t.Error("Expected restoring an invalid backup to fail")
+	}
+}
+
+func TestSaveConfigKeepsSymlink(t *testing.T) {
+	dotfiles := filepath.Join(t.TempDir(), "dotfiles", "akumi.yaml")
+	if err := os.MkdirAll(filepath.Dir(dotfiles), 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dotfiles, []byte("targets: []\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.Symlink(dotfiles, configPath); err != nil {
+		t.Skipf("Symlinks are not supported: %v", err)
+	}
+	restoreConfigPath := SetConfigPathProvider(func() (string, error) {
+		return configPath, nil
+	})
+	defer restoreConfigPath()
+
+	if err := SaveConfig(Config{Targets: []SSHTarget{{User: "root", Host: "linked.example.com", Port: 22}}}); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	if info, err := os.Lstat(configPath); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Fatalf("Expected the config to stay a symlink, got %v (%v)", info, err)
+	}
+	if data, _ := os.ReadFile(dotfiles); !strings.Contains(string(data), "linked.example.com") {
+		t.Errorf("Expected the linked file to be written, got:\n%s", data)
+	}
+}
+
+func TestSaveConfigKeepsMode(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	restoreConfigPath := SetConfigPathProvider(func() (string, error) {
+		return configPath, nil
+	})
+	defer restoreConfigPath()
+
+	cfg := Config{Targets: []SSHTarget{{User: "root", Host: "a.example.com", Port: 22}}}
+	if err := SaveConfig(cfg); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	if info, err := os.Stat(configPath); err != nil || info.Mode().Perm() != 0640 {
+		t.Fatalf("Expected a new config to be created with mode 0640, got %v (%v)", info, err)
+	}
+
+	// A mode chosen by the user survives the next save
+	if err := os.Chmod(configPath, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := OverwriteConfig(cfg); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	if info, err := os.Stat(configPath); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected the config to keep mode 0600, got %v (%v)", info, err)
+	}
+}
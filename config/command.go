@@ -0,0 +1,237 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// GetSSHCommand returns the command line arguments for the ssh command.
+// Options come first and the destination is always the last argument.
+// Templates and environment variables are expanded here, at connect time;
+// they are checked when the config is loaded, so a value that still fails to
+// expand is passed to ssh as written.
+func (t SSHTarget) GetSSHCommand() []string {
+	if expanded, err := t.Expand(); err == nil {
+		t = expanded
+	}
+
+	var args []string
+	if t.Port != 0 && t.Port != 22 {
+		args = append(args, "-p", strconv.Itoa(t.Port))
+	}
+	if t.IdentityFile != "" {
+		args = append(args, "-i", t.IdentityFile)
+	}
+	if t.ProxyJump != "" {
+		args = append(args, "-J", t.ProxyJump)
+	}
+	if t.ForwardAgent {
+		args = append(args, "-A")
+	}
+	for _, key := range sortedOptionKeys(t.Options) {
+		args = append(args, "-o", fmt.Sprintf("%s=%s", key, t.Options[key]))
+	}
+	return append(args, t.Destination())
+}
+
+// Destination returns the user@host argument passed to ssh, or just the host
+// when the target has no user so that ssh picks its own default.
+func (t SSHTarget) Destination() string {
+	if t.User == "" {
+		return t.Host
+	}
+	return t.User + "@" + t.Host
+}
+
+// Apply returns a copy of the target with its empty user, identity file and
+// jump host taken from the defaults. The default jump host is not applied to
+// a target that is itself one of its hops.
+func (d TargetDefaults) Apply(t SSHTarget) SSHTarget {
+	if t.User == "" {
+		t.User = d.User
+	}
+	if t.IdentityFile == "" {
+		t.IdentityFile = d.IdentityFile
+	}
+	if t.ProxyJump == "" && !t.isJumpHop(d.ProxyJump) {
+		t.ProxyJump = d.ProxyJump
+	}
+	return t
+}
+
+// isJumpHop reports whether any hop of the ProxyJump list names the target
+func (t SSHTarget) isJumpHop(proxyJump string) bool {
+	for _, hop := range strings.Split(proxyJump, ",") {
+		hop = strings.TrimSpace(hop)
+		if hop == "" {
+			continue
+		}
+		if (t.Nickname != "" && (hop == t.Nickname || hop == t.SSHConfigAlias())) || hop == t.Host || hop == t.JumpAddress() {
+			return true
+		}
+	}
+	return false
+}
+
+// ApplyAll applies the defaults to every target.
+func (d TargetDefaults) ApplyAll(targets []SSHTarget) []SSHTarget {
+	applied := make([]SSHTarget, len(targets))
+	for i, t := range targets {
+		applied[i] = d.Apply(t)
+	}
+	return applied
+}
+
+// Resolve returns the target as it is connected to: the defaults are applied
+// and jump hosts naming other targets are expanded to their addresses.
+func (d TargetDefaults) Resolve(t SSHTarget, targets []SSHTarget) SSHTarget {
+	return d.Apply(t).ResolveProxyJump(d.ApplyAll(targets))
+}
+
+// ResolveProxyJump returns a copy of the target whose ProxyJump hops that name
+// other targets by nickname are replaced with their user@host:port address.
+// Jump hosts of referenced targets are prepended so chains resolve fully.
+func (t SSHTarget) ResolveProxyJump(targets []SSHTarget) SSHTarget {
+	if t.ProxyJump == "" {
+		return t
+	}
+	visited := map[string]bool{t.Nickname: true}
+	t.ProxyJump = strings.Join(resolveJumpHops(t.ProxyJump, targets, visited), ",")
+	return t
+}
+
+// resolveJumpHops expands every hop of a ProxyJump list, guarding against cycles.
+func resolveJumpHops(proxyJump string, targets []SSHTarget, visited map[string]bool) []string {
+	var hops []string
+	for _, hop := range strings.Split(proxyJump, ",") {
+		hop = strings.TrimSpace(hop)
+		if hop == "" {
+			continue
+		}
+
+		jump, ok := FindTargetByNickname(targets, hop)
+		if !ok || visited[hop] {
+			hops = append(hops, hop)
+			continue
+		}
+
+		visited[hop] = true
+		if expanded, err := jump.Expand(); err == nil {
+			jump = expanded
+		}
+		if jump.ProxyJump != "" {
+			hops = append(hops, resolveJumpHops(jump.ProxyJump, targets, visited)...)
+		}
+		hops = append(hops, jump.JumpAddress())
+	}
+	return hops
+}
+
+// JumpWarnings lists the jump targets of t, named by nickname, whose own
+// identity file or options are not used. Hops are handed to the connector as
+// plain addresses, so only the ssh_config of the client applies to them.
+func (d TargetDefaults) JumpWarnings(t SSHTarget, targets []SSHTarget) []string {
+	var warnings []string
+	visited := map[string]bool{t.Nickname: true}
+	var walk func(proxyJump string)
+	walk = func(proxyJump string) {
+		for _, hop := range strings.Split(proxyJump, ",") {
+			hop = strings.TrimSpace(hop)
+			jump, ok := FindTargetByNickname(targets, hop)
+			if hop == "" || !ok || visited[hop] {
+				continue
+			}
+			visited[hop] = true
+			walk(jump.ProxyJump)
+			if jump.IdentityFile != "" || len(jump.Options) > 0 {
+				warnings = append(warnings, fmt.Sprintf("the identity file and options of jump host %q are not used for the hop; set them for %s in ~/.ssh/config", hop, jump.Host))
+			}
+		}
+	}
+	walk(d.Apply(t).ProxyJump)
+	return warnings
+}
+
+// JumpAddress returns the target in the [user@]host[:port] form used by ssh -J.
+func (t SSHTarget) JumpAddress() string {
+	address := t.Host
+	if t.User != "" {
+		address = t.User + "@" + address
+	}
+	if t.Port != 0 && t.Port != 22 {
+		address = fmt.Sprintf("%s:%d", address, t.Port)
+	}
+	return address
+}
+
+// FindTargetByNickname returns the target with the given nickname or export alias.
+func FindTargetByNickname(targets []SSHTarget, nickname string) (SSHTarget, bool) {
+	for _, t := range targets {
+		if t.Nickname != "" && (t.Nickname == nickname || t.SSHConfigAlias() == nickname) {
+			return t, true
+		}
+	}
+	return SSHTarget{}, false
+}
+
+// ParseOptions parses a comma-separated list of Key=Value ssh options. A new
+// option starts only where the text after a comma reads Key=, so values that
+// are lists themselves, such as Ciphers=aes128-ctr,aes256-ctr, stay whole.
+func ParseOptions(s string) (map[string]string, error) {
+	options := make(map[string]string)
+	var key string
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		name, value, found := strings.Cut(pair, "=")
+		name = strings.TrimSpace(name)
+		if found && isOptionName(name) {
+			key = name
+			options[key] = strings.TrimSpace(value)
+			continue
+		}
+		if key == "" {
+			return nil, fmt.Errorf("invalid option %q: expected Key=Value", strings.TrimSpace(pair))
+		}
+		options[key] = strings.TrimSpace(options[key] + "," + pair)
+	}
+	if len(options) == 0 {
+		return nil, nil
+	}
+	return options, nil
+}
+
+// isOptionName reports whether s can be an ssh_config keyword
+func isOptionName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// FormatOptions renders options as a comma-separated Key=Value list sorted by key.
+func FormatOptions(options map[string]string) string {
+	pairs := make([]string, 0, len(options))
+	for _, key := range sortedOptionKeys(options) {
+		pairs = append(pairs, key+"="+options[key])
+	}
+	return strings.Join(pairs, ", ")
+}
+
+// sortedOptionKeys returns option keys in a stable order.
+func sortedOptionKeys(options map[string]string) []string {
+	keys := make([]string, 0, len(options))
+	for key := range options {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
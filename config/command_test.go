@@ -0,0 +1,151 @@
+package config
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestGetSSHCommand(t *testing.T) {
+	t.Setenv("HOME", "/home/deploy")
+	t.Setenv("CORP_USER", "alice")
+
+	tests := []struct {
+		name     string
+		target   SSHTarget
+		expected []string
+	}{
+		{
+			name:     "default port",
+			target:   SSHTarget{User: "root", Host: "example.com", Port: 22},
+			expected: []string{"root@example.com"},
+		},
+		{
+			name:     "custom port",
+			target:   SSHTarget{User: "admin", Host: "example.com", Port: 2222},
+			expected: []string{"-p", "2222", "admin@example.com"},
+		},
+		{
+			name: "all options",
+			target: SSHTarget{
+				User:         "deploy",
+				Host:         "10.0.0.5",
+				Port:         2200,
+				IdentityFile: "~/.ssh/id_deploy",
+				ProxyJump:    "bastion.example.com",
+				ForwardAgent: true,
+				Options: map[string]string{
+					"StrictHostKeyChecking": "no",
+					"RequestTTY":            "force",
+				},
+			},
+			expected: []string{
+				"-p", "2200",
+				"-i", "/home/deploy/.ssh/id_deploy",
+				"-J", "bastion.example.com",
+				"-A",
+				"-o", "RequestTTY=force",
+				"-o", "StrictHostKeyChecking=no",
+				"deploy@10.0.0.5",
+			},
+		},
+		{
+			name: "expanded fields",
+			target: SSHTarget{
+				Nickname:     "Build-01",
+				User:         "${CORP_USER}",
+				Host:         "{{lower .Nickname}}.corp.internal",
+				IdentityFile: "~/.ssh/id_{{env \"CORP_USER\"}}",
+			},
+			expected: []string{"-i", "/home/deploy/.ssh/id_alice", "alice@build-01.corp.internal"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.target.GetSSHCommand(); !slices.Equal(got, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestResolveProxyJump(t *testing.T) {
+	targets := []SSHTarget{
+		{Nickname: "edge", User: "ops", Host: "edge.example.com", Port: 22},
+		{Nickname: "bastion", User: "jump", Host: "bastion.example.com", Port: 2222, ProxyJump: "edge"},
+		{Nickname: "loop", User: "a", Host: "loop.example.com", Port: 22, ProxyJump: "loop"},
+		{Nickname: "db", User: "root", Host: "10.0.0.5", Port: 22, ProxyJump: "bastion, other@gw:2022"},
+	}
+
+	resolved := targets[3].ResolveProxyJump(targets)
+	expected := "ops@edge.example.com,jump@bastion.example.com:2222,other@gw:2022"
+	if resolved.ProxyJump != expected {
+		t.Errorf("Expected ProxyJump %q, got %q", expected, resolved.ProxyJump)
+	}
+	if targets[3].ProxyJump != "bastion, other@gw:2022" {
+		t.Error("ResolveProxyJump must not modify the original target")
+	}
+
+	// Self-referencing jumps are left untouched instead of recursing forever
+	if loop := targets[2].ResolveProxyJump(targets); loop.ProxyJump != "loop" {
+		t.Errorf("Expected cyclic ProxyJump to stay unresolved, got %q", loop.ProxyJump)
+	}
+}
+
+func TestJumpWarnings(t *testing.T) {
+	targets := []SSHTarget{
+		{Nickname: "edge", User: "ops", Host: "edge.example.com", IdentityFile: "~/.ssh/id_edge"},
+		{Nickname: "bastion", User: "jump", Host: "bastion.example.com", ProxyJump: "edge"},
+		{Nickname: "db", User: "root", Host: "10.0.0.5", ProxyJump: "bastion"},
+		{Nickname: "web", User: "root", Host: "10.0.0.6"},
+	}
+
+	// The key of a hop further up the chain is reported too
+	warnings := TargetDefaults{}.JumpWarnings(targets[2], targets)
+	if len(warnings) != 1 || !strings.Contains(warnings[0], `"edge"`) || !strings.Contains(warnings[0], "edge.example.com") {
+		t.Errorf("Expected a warning for the key of edge, got %v", warnings)
+	}
+	if warnings := (TargetDefaults{}).JumpWarnings(targets[3], targets); len(warnings) != 0 {
+		t.Errorf("Expected no warnings without a jump host, got %v", warnings)
+	}
+	if warnings := (TargetDefaults{ProxyJump: "edge"}).JumpWarnings(targets[3], targets); len(warnings) != 1 {
+		t.Errorf("Expected the default jump host to be checked, got %v", warnings)
+	}
+}
+
+func TestParseOptions(t *testing.T) {
+	options, err := ParseOptions("StrictHostKeyChecking=no, ServerAliveInterval = 30,")
+	if err != nil {
+		t.Fatalf("Failed to parse options: %v", err)
+	}
+	if options["StrictHostKeyChecking"] != "no" || options["ServerAliveInterval"] != "30" {
+		t.Errorf("Unexpected options: %v", options)
+	}
+	if formatted := FormatOptions(options); formatted != "ServerAliveInterval=30, StrictHostKeyChecking=no" {
+		t.Errorf("Unexpected formatted options: %s", formatted)
+	}
+
+	// Values that are lists keep their commas through a round trip
+	options = map[string]string{
+		"Ciphers":       "aes128-ctr,aes256-ctr",
+		"KexAlgorithms": "curve25519-sha256, ecdh-sha2-nistp256",
+		"LocalCommand":  "echo a=b,c",
+		"Port":          "2222",
+	}
+	parsed, err := ParseOptions(FormatOptions(options))
+	if err != nil {
+		t.Fatalf("Failed to parse formatted options: %v", err)
+	}
+	if !maps.Equal(parsed, options) {
+		t.Errorf("Expected %v after a round trip, got %v", options, parsed)
+	}
+
+	if _, err := ParseOptions("NoValue"); err == nil {
+		t.Error("Expected error for option without value")
+	}
+	if options, err := ParseOptions("  "); err != nil || options != nil {
+		t.Errorf("Expected nil options for empty input, got %v, %v", options, err)
+	}
+}
@@ -1,10 +1,13 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
-	"strconv"
+	"reflect"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -13,12 +16,111 @@ import (
 type SSHTarget struct {
 	// Nickname is an optional display name for the SSH target.
 	Nickname string `yaml:"nickname,omitempty"`
-	// User is the SSH username.
-	User string `yaml:"user"`
+	// User is the SSH username. It may be omitted when a default user is set.
+	User string `yaml:"user,omitempty"`
 	// Host is the SSH server hostname or IP address.
 	Host string `yaml:"host"`
 	// Port is the SSH server port. Defaults to 22 if omitted.
 	Port int `yaml:"port,omitempty"`
+	// Group is an optional slash-separated group path such as "prod/db".
+	Group string `yaml:"group,omitempty"`
+	// Tags are free-form labels such as "env:prod" or "role:db".
+	Tags []string `yaml:"tags,omitempty"`
+	// IdentityFile is an optional private key passed to ssh with -i.
+	IdentityFile string `yaml:"identity_file,omitempty"`
+	// ProxyJump is an optional comma-separated list of jump hosts. Each hop may
+	// be the nickname of another target or a plain [user@]host[:port] spec.
+	ProxyJump string `yaml:"proxy_jump,omitempty"`
+	// ForwardAgent enables forwarding of the authentication agent (-A).
+	ForwardAgent bool `yaml:"forward_agent,omitempty"`
+	// Options holds arbitrary ssh_config options passed with -o Key=Value.
+	Options map[string]string `yaml:"options,omitempty"`
+	// Connector names the backend used to connect to the target, overriding
+	// connection.connector.
+	Connector string `yaml:"connector,omitempty"`
+
+	// Origin is the shared config file the target was loaded from. It is
+	// empty for targets of the user's own config file.
+	Origin string `yaml:"-"`
+}
+
+// Shared reports whether the target comes from a read-only shared layer.
+func (t SSHTarget) Shared() bool {
+	return t.Origin != ""
+}
+
+// GroupPath returns the target's group split into its path segments.
+func (t SSHTarget) GroupPath() []string {
+	group := NormalizeGroup(t.Group)
+	if group == "" {
+		return nil
+	}
+	return strings.Split(group, "/")
+}
+
+// HasTags reports whether the target matches every tag selector.
+// A selector matches a tag exactly, or matches its key when the selector has
+// no value (e.g. "env" matches "env:prod"). Matching is case-insensitive.
+func (t SSHTarget) HasTags(selectors ...string) bool {
+	for _, selector := range selectors {
+		if !t.hasTag(selector) {
+			return false
+		}
+	}
+	return true
+}
+
+// hasTag reports whether any of the target's tags matches selector.
+func (t SSHTarget) hasTag(selector string) bool {
+	selector = strings.ToLower(strings.TrimSpace(selector))
+	if selector == "" {
+		return true
+	}
+	for _, tag := range t.Tags {
+		tag = strings.ToLower(tag)
+		if tag == selector {
+			return true
+		}
+		if !strings.Contains(selector, ":") {
+			if key, _, found := strings.Cut(tag, ":"); found && key == selector {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ParseTags splits a comma or whitespace separated list into normalized tags.
+func ParseTags(s string) []string {
+	return NormalizeTags(strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t'
+	}))
+}
+
+// NormalizeTags trims tags and removes empty and duplicate entries, keeping order.
+func NormalizeTags(tags []string) []string {
+	var normalized []string
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[strings.ToLower(tag)] {
+			continue
+		}
+		seen[strings.ToLower(tag)] = true
+		normalized = append(normalized, tag)
+	}
+	return normalized
+}
+
+// NormalizeGroup trims whitespace and removes empty segments from a group path.
+func NormalizeGroup(group string) string {
+	var segments []string
+	for _, segment := range strings.Split(group, "/") {
+		if segment = strings.TrimSpace(segment); segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	return strings.Join(segments, "/")
 }
 
 // String returns a formatted string representation of the SSH target.
@@ -27,22 +129,13 @@ func (t SSHTarget) String() string {
 	if t.Port != 0 && t.Port != 22 {
 		portStr = fmt.Sprintf(":%d", t.Port)
 	}
-	base := fmt.Sprintf("%s@%s%s", t.User, t.Host, portStr)
+	base := t.Destination() + portStr
 	if t.Nickname != "" {
 		return fmt.Sprintf("[%s] %s", t.Nickname, base)
 	}
 	return base
 }
 
-// GetSSHCommand returns the command line arguments for the ssh command.
-func (t SSHTarget) GetSSHCommand() []string {
-	args := []string{fmt.Sprintf("%s@%s", t.User, t.Host)}
-	if t.Port != 0 && t.Port != 22 {
-		args = append(args, "-p", strconv.Itoa(t.Port))
-	}
-	return args
-}
-
 // ThemeColors holds color scheme settings for the application's UI.
 type ThemeColors struct {
 	// Primary colors
@@ -72,19 +165,115 @@ func DefaultTheme() ThemeColors {
 	}
 }
 
+// TargetDefaults holds the settings used for targets that leave them empty.
+type TargetDefaults struct {
+	// User is the SSH username of targets without one.
+	User string `yaml:"user,omitempty"`
+	// IdentityFile is the private key of targets without one.
+	IdentityFile string `yaml:"identity_file,omitempty"`
+	// ProxyJump is the jump host of targets without one.
+	ProxyJump string `yaml:"proxy_jump,omitempty"`
+}
+
+// ProbeSettings controls the background reachability checks of the TUI.
+// Zero values select the defaults.
+type ProbeSettings struct {
+	// Disabled turns probing off.
+	Disabled bool `yaml:"disabled,omitempty"`
+	// Concurrency is the maximum number of hosts probed at once.
+	Concurrency int `yaml:"concurrency,omitempty"`
+	// Timeout bounds the connection and banner read of each probe.
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+	// Interval is the pause between two rounds of probes.
+	Interval time.Duration `yaml:"interval,omitempty"`
+}
+
+// Default probe settings.
+const (
+	DefaultProbeConcurrency = 8
+	DefaultProbeTimeout     = 3 * time.Second
+	DefaultProbeInterval    = 30 * time.Second
+)
+
+// WithDefaults returns the settings with unset values replaced by defaults.
+func (p ProbeSettings) WithDefaults() ProbeSettings {
+	if p.Concurrency <= 0 {
+		p.Concurrency = DefaultProbeConcurrency
+	}
+	if p.Timeout <= 0 {
+		p.Timeout = DefaultProbeTimeout
+	}
+	if p.Interval <= 0 {
+		p.Interval = DefaultProbeInterval
+	}
+	return p
+}
+
+// Names of the built-in connectors.
+const (
+	ConnectorOpenSSH = "openssh"
+	ConnectorMosh    = "mosh"
+	ConnectorBuiltin = "builtin"
+)
+
+// ConnectionSettings selects the program used to connect to targets.
+type ConnectionSettings struct {
+	// Connector is used by targets that do not name one: "openssh" (the
+	// default), "mosh", "builtin" or the name of one of the templates.
+	Connector string `yaml:"connector,omitempty"`
+	// SSH is the OpenSSH client binary. Defaults to "ssh".
+	SSH string `yaml:"ssh,omitempty"`
+	// Mosh is the mosh binary. Defaults to "mosh".
+	Mosh string `yaml:"mosh,omitempty"`
+	// Templates defines custom connectors as command line templates, such as
+	// tsh: "tsh ssh {{.Destination}}".
+	Templates map[string]string `yaml:"templates,omitempty"`
+}
+
+// ConnectorFor returns the name of the connector used for target.
+func (c ConnectionSettings) ConnectorFor(t SSHTarget) string {
+	switch {
+	case t.Connector != "":
+		return t.Connector
+	case c.Connector != "":
+		return c.Connector
+	default:
+		return ConnectorOpenSSH
+	}
+}
+
 // Config represents the application's configuration structure.
 type Config struct {
+	// Version is the schema version of the file, see CurrentVersion.
+	Version int `yaml:"version,omitempty"`
+	// Defaults holds the settings shared by every target of the profile.
+	Defaults TargetDefaults `yaml:"defaults,omitempty"`
 	// Targets is a list of configured SSH targets.
 	Targets []SSHTarget `yaml:"targets"`
 	// Theme contains the UI color scheme configuration.
 	Theme ThemeColors `yaml:"theme,omitempty"`
+	// Probe configures the reachability checks shown in the target list.
+	Probe ProbeSettings `yaml:"probe,omitempty"`
+	// Connection selects how targets are connected to.
+	Connection ConnectionSettings `yaml:"connection,omitempty"`
+
+	// doc is the YAML the config was loaded from, used to preserve comments
+	// and unknown keys on save.
+	doc *document
 }
 
+// ConfigPathEnv is the environment variable that overrides the config path.
+const ConfigPathEnv = "AKUMI_CONFIG"
+
 // Variable to allow tests to override the config path
 var configPathProvider = defaultConfigPath
 
 // defaultConfigPath is the default implementation for getting config path
 func defaultConfigPath() (string, error) {
+	if path := os.Getenv(ConfigPathEnv); path != "" {
+		return absConfigPath(path)
+	}
+
 	configDir, err := os.UserConfigDir()
 	if err != nil {
 		return "", fmt.Errorf("failed to get user config directory: %w", err)
@@ -92,9 +281,37 @@ func defaultConfigPath() (string, error) {
 	return filepath.Join(configDir, "akumi", "config.yaml"), nil
 }
 
-// GetConfigPath returns the full path to the configuration file.
+// GetConfigPath returns the full path to the configuration file of the
+// active profile.
 func GetConfigPath() (string, error) {
-	return configPathProvider()
+	configPath, err := configPathProvider()
+	if err != nil {
+		return "", err
+	}
+	return profileConfigPath(configPath, activeProfile), nil
+}
+
+// SetConfigPath makes GetConfigPath return path, e.g. from a --config flag.
+// It takes precedence over ConfigPathEnv and returns a function restoring the
+// previous location.
+func SetConfigPath(path string) (func(), error) {
+	configPath, err := absConfigPath(path)
+	if err != nil {
+		return nil, err
+	}
+	return SetConfigPathProvider(func() (string, error) {
+		return configPath, nil
+	}), nil
+}
+
+// absConfigPath resolves a user supplied config path against the working
+// directory so that it stays valid if the directory changes
+func absConfigPath(path string) (string, error) {
+	configPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve config path %s: %w", path, err)
+	}
+	return configPath, nil
 }
 
 // SetConfigPathProvider allows tests to override the config path provider
@@ -118,18 +335,57 @@ func LoadConfig() (Config, error) {
 		return Config{}, err
 	}
 
+	unlock, err := lockConfig(configPath, false)
+	if err != nil {
+		return Config{}, err
+	}
+	defer unlock()
+
+	return loadConfig(configPath)
+}
+
+// loadConfig reads configPath on top of the shared layers and normalizes the
+// result. The caller must hold the lock.
+func loadConfig(configPath string) (Config, error) {
 	// Read configuration file
 	cfg, err := readConfigFile(configPath)
 	if err != nil {
 		return Config{}, err
 	}
 
+	// Merge the read-only shared layers below the user's file
+	shared, layers, err := loadSharedLayers(configPath)
+	if err != nil {
+		return Config{}, err
+	}
+	cfg.Targets = combineTargets(shared.Targets, cfg.Targets)
+	cfg.Theme = overlay(shared.Theme, cfg.Theme)
+	cfg.Defaults = overlay(shared.Defaults, cfg.Defaults)
+	cfg.Probe = overlay(shared.Probe, cfg.Probe)
+	cfg.Connection = overlay(shared.Connection, cfg.Connection)
+	if err := requireUsers(configPath, cfg, layers); err != nil {
+		return Config{}, err
+	}
+	if err := requireConnector(configPath, cfg, layers); err != nil {
+		return Config{}, err
+	}
+
 	// Apply default port to targets
 	applyDefaultPorts(&cfg)
 
+	// Normalize group paths and tags
+	normalizeGroups(&cfg)
+	normalizeTags(&cfg)
+
 	// Apply default theme values
 	applyDefaultTheme(&cfg)
 
+	cfg.doc.base = userTargets(cfg.Targets)
+	cfg.doc.inherited = overlay(DefaultTheme(), shared.Theme)
+	cfg.doc.inheritedDefaults = shared.Defaults
+	cfg.doc.inheritedProbe = shared.Probe
+	cfg.doc.inheritedConnection = shared.Connection
+	cfg.doc.layers = layers
 	return cfg, nil
 }
 
@@ -149,20 +405,25 @@ func readConfigFile(configPath string) (Config, error) {
 	data, err := os.ReadFile(configPath)
 	if err != nil {
 		if os.IsNotExist(err) {
-			// Return a default config with empty targets and default theme
+			// Return an empty config; defaults are applied by loadConfig
 			return Config{
 				Targets: []SSHTarget{},
-				Theme:   DefaultTheme(),
+				doc:     &document{indent: 4},
 			}, nil
 		}
 		return Config{}, fmt.Errorf("failed to read config file %s: %w", configPath, err)
 	}
 
-	var cfg Config
-	err = yaml.Unmarshal(data, &cfg)
+	cfg, err := parseDocument(data)
 	if err != nil {
+		var validationErr *ValidationError
+		if errors.As(err, &validationErr) {
+			validationErr.Path = configPath
+			return Config{}, validationErr
+		}
 		return Config{}, fmt.Errorf("failed to parse config file %s: %w", configPath, err)
 	}
+	cfg.doc.stamp = newFileStamp(configPath, data)
 
 	return cfg, nil
 }
@@ -176,6 +437,20 @@ func applyDefaultPorts(cfg *Config) {
 	}
 }
 
+// normalizeGroups cleans up group paths so equivalent spellings share a group
+func normalizeGroups(cfg *Config) {
+	for i := range cfg.Targets {
+		cfg.Targets[i].Group = NormalizeGroup(cfg.Targets[i].Group)
+	}
+}
+
+// normalizeTags removes empty and duplicate tags from every target
+func normalizeTags(cfg *Config) {
+	for i := range cfg.Targets {
+		cfg.Targets[i].Tags = NormalizeTags(cfg.Targets[i].Tags)
+	}
+}
+
 // applyDefaultTheme applies default theme colors for any unset values
 func applyDefaultTheme(cfg *Config) {
 	defaultTheme := DefaultTheme()
@@ -206,35 +481,120 @@ func applyDefaultTheme(cfg *Config) {
 	}
 }
 
-// SaveConfig writes the configuration to disk.
+// customized clears the fields of a settings section that match the
+// inherited values so that only customized values are written back to disk.
+// Map entries are compared one by one.
+func customized[T layeredSettings](values, inherited T) T {
+	defaults := reflect.ValueOf(inherited)
+	fields := reflect.ValueOf(&values).Elem()
+	for i := 0; i < fields.NumField(); i++ {
+		field, inheritedField := fields.Field(i), defaults.Field(i)
+		if field.Kind() != reflect.Map {
+			if field.Equal(inheritedField) {
+				field.SetZero()
+			}
+			continue
+		}
+		own := reflect.MakeMap(field.Type())
+		for iter := field.MapRange(); iter.Next(); {
+			if value := inheritedField.MapIndex(iter.Key()); !value.IsValid() || !value.Equal(iter.Value()) {
+				own.SetMapIndex(iter.Key(), iter.Value())
+			}
+		}
+		if own.Len() == 0 {
+			own = reflect.Zero(field.Type())
+		}
+		field.Set(own)
+	}
+	return values
+}
+
+// checkSaved validates the YAML about to be written, so that a save never
+// leaves a file the next load would reject
+func checkSaved(data []byte, defaults TargetDefaults) error {
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return fmt.Errorf("failed to check config before saving: %w", err)
+	}
+	problems := errorProblems(validateDocument(&node))
+	if defaults.User == "" {
+		problems = append(problems, missingUsers(&node)...)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("refusing to save: %w", &ValidationError{Problems: problems})
+	}
+	return nil
+}
+
+// SaveConfig writes the configuration to disk. A config returned by LoadConfig
+// keeps the comments, key order and unknown keys of the file it was read from,
+// and ErrConfigChanged is returned if that file was modified in the meantime.
 func SaveConfig(cfg Config) error {
+	return saveConfig(cfg, true)
+}
+
+// saveConfig writes cfg while holding the lock, optionally refusing to
+// overwrite changes made by another process
+func saveConfig(cfg Config, checkChanged bool) error {
 	configPath, err := GetConfigPath()
 	if err != nil {
 		return err
 	}
+	if err := ensureConfigDirExists(configPath); err != nil {
+		return err
+	}
 
+	unlock, err := lockConfig(configPath, true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if checkChanged && cfg.doc != nil {
+		if err := cfg.doc.stamp.checkUnchanged(configPath); err != nil {
+			return err
+		}
+	}
+	return writeConfig(configPath, cfg)
+}
+
+// writeConfig marshals the user layer of cfg and writes it to configPath.
+// Targets from shared layers are left out. The caller must hold the lock.
+func writeConfig(configPath string, cfg Config) error {
 	// Ensure Port default is handled for saving (omitempty works best with 0)
 	// Create a copy to modify for saving
-	saveTargets := make([]SSHTarget, len(cfg.Targets))
-	copy(saveTargets, cfg.Targets)
+	saveTargets := userTargets(cfg.Targets)
 	for i := range saveTargets {
 		if saveTargets[i].Port == 22 {
 			saveTargets[i].Port = 0 // Use 0 for omitempty default
 		}
 	}
+	inherited := document{inherited: DefaultTheme()}
+	if cfg.doc != nil && cfg.doc.inherited != (ThemeColors{}) {
+		inherited = *cfg.doc
+	}
 	saveCfg := Config{
-		Targets: saveTargets,
-		Theme:   cfg.Theme,
+		Version:    CurrentVersion,
+		Defaults:   customized(cfg.Defaults, inherited.inheritedDefaults),
+		Targets:    saveTargets,
+		Theme:      customized(cfg.Theme, inherited.inherited),
+		Probe:      customized(cfg.Probe, inherited.inheritedProbe),
+		Connection: customized(cfg.Connection, inherited.inheritedConnection),
 	}
 
-	data, err := yaml.Marshal(saveCfg)
+	data, err := marshalConfig(saveCfg, cfg.doc)
 	if err != nil {
-		return fmt.Errorf("failed to marshal config to YAML: %w", err)
+		return err
+	}
+	if err := checkSaved(data, cfg.Defaults); err != nil {
+		return err
+	}
+	if err := writeConfigFile(configPath, data); err != nil {
+		return err
 	}
 
-	err = os.WriteFile(configPath, data, 0640) // Changed permissions for security
-	if err != nil {
-		return fmt.Errorf("failed to write config file %s: %w", configPath, err)
+	if cfg.doc != nil {
+		cfg.doc.refresh(configPath, data, userTargets(cfg.Targets))
 	}
 	return nil
 }
@@ -0,0 +1,147 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadConfigGroups(t *testing.T) {
+	testDir := t.TempDir()
+	configPath := filepath.Join(testDir, "akumi", "config.yaml")
+
+	restoreConfigPath := SetConfigPathProvider(func() (string, error) {
+		return configPath, nil
+	})
+	defer restoreConfigPath()
+
+	if err := os.MkdirAll(filepath.Dir(configPath), 0750); err != nil {
+		t.Fatalf("Failed to create config directory: %v", err)
+	}
+
+	// Old flat configs without groups must keep loading
+	data := `targets:
+  - user: root
+    host: 192.168.1.99
+    nickname: dev-server
+  - user: admin
+    host: db.example.com
+    group: " prod// db/ "
+`
+	if err := os.WriteFile(configPath, []byte(data), 0640); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	if len(cfg.Targets) != 2 {
+		t.Fatalf("Expected 2 targets, got %d", len(cfg.Targets))
+	}
+	if cfg.Targets[0].Group != "" || cfg.Targets[0].GroupPath() != nil {
+		t.Errorf("Expected ungrouped target, got group %q", cfg.Targets[0].Group)
+	}
+	if cfg.Targets[1].Group != "prod/db" {
+		t.Errorf("Expected normalized group prod/db, got %q", cfg.Targets[1].Group)
+	}
+	if path := cfg.Targets[1].GroupPath(); len(path) != 2 || path[1] != "db" {
+		t.Errorf("Unexpected group path %v", path)
+	}
+}
+
+func TestTargetTags(t *testing.T) {
+	target := SSHTarget{
+		User: "root",
+		Host: "db.example.com",
+		Tags: ParseTags("env:prod, role:db  region:eu,,env:prod"),
+	}
+
+	if len(target.Tags) != 3 {
+		t.Fatalf("Expected 3 normalized tags, got %v", target.Tags)
+	}
+
+	tests := []struct {
+		selectors []string
+		expected  bool
+	}{
+		{nil, true},
+		{[]string{"env:prod"}, true},
+		{[]string{"ENV:Prod"}, true},
+		{[]string{"env"}, true},
+		{[]string{"env:prod", "role:db"}, true},
+		{[]string{"env:staging"}, false},
+		{[]string{"env:prod", "role:web"}, false},
+		{[]string{"prod"}, false},
+	}
+	for _, tt := range tests {
+		if got := target.HasTags(tt.selectors...); got != tt.expected {
+			t.Errorf("HasTags(%v) = %v, expected %v", tt.selectors, got, tt.expected)
+		}
+	}
+}
+
+func TestConfigPathOverrides(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+
+	envPath := filepath.Join(dir, "env.yaml")
+	t.Setenv(ConfigPathEnv, envPath)
+	if path, err := defaultConfigPath(); err != nil || path != envPath {
+		t.Errorf("Expected %s from %s, got %q (%v)", envPath, ConfigPathEnv, path, err)
+	}
+
+	// A --config path is resolved against the working directory
+	restore, err := SetConfigPath("fixture.yaml")
+	if err != nil {
+		t.Fatalf("Failed to set config path: %v", err)
+	}
+	defer restore()
+
+	want, err := filepath.Abs("fixture.yaml")
+	if err != nil {
+		t.Fatalf("Failed to resolve path: %v", err)
+	}
+	if path, err := GetConfigPath(); err != nil || path != want {
+		t.Errorf("Expected %s, got %q (%v)", want, path, err)
+	}
+}
+
+func TestProbeSettings(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	restoreConfigPath := SetConfigPathProvider(func() (string, error) {
+		return configPath, nil
+	})
+	defer restoreConfigPath()
+
+	data := "version: 1\nprobe:\n    concurrency: 4\n    timeout: 1500ms\ntargets: []\n"
+	if err := os.WriteFile(configPath, []byte(data), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	want := ProbeSettings{Concurrency: 4, Timeout: 1500 * time.Millisecond, Interval: DefaultProbeInterval}
+	if got := cfg.Probe.WithDefaults(); got != want {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+
+	cfg.Probe.Interval = time.Minute
+	if err := SaveConfig(cfg); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	saved, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("Failed to read config: %v", err)
+	}
+	for _, line := range []string{"timeout: 1.5s", "interval: 1m0s"} {
+		if !strings.Contains(string(saved), line) {
+			t.Errorf("Expected %q in the saved config:\n%s", line, saved)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package config
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"time"
+)
+
+// ErrConfigChanged is returned by SaveConfig when the configuration file was
+// modified by another process after it was loaded.
+var ErrConfigChanged = errors.New("config file was modified by another process")
+
+// lockConfig takes the advisory lock guarding configPath. Readers share the
+// lock while writers hold it exclusively.
+func lockConfig(configPath string, exclusive bool) (func(), error) {
+	unlock, err := lockFile(configPath+".lock", exclusive)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock config file %s: %w", configPath, err)
+	}
+	return unlock, nil
+}
+
+// fileStamp identifies the version of the config file a Config was read from.
+type fileStamp struct {
+	exists  bool
+	modTime time.Time
+	size    int64
+	sum     [sha256.Size]byte
+}
+
+// newFileStamp records the current state of configPath, whose content is data
+func newFileStamp(configPath string, data []byte) fileStamp {
+	info, err := os.Stat(configPath)
+	if err != nil {
+		return fileStamp{}
+	}
+	return fileStamp{
+		exists:  true,
+		modTime: info.ModTime(),
+		size:    info.Size(),
+		sum:     sha256.Sum256(data),
+	}
+}
+
+// checkUnchanged returns ErrConfigChanged if configPath no longer matches the
+// stamp. The content hash is only compared when the mtime or size differ, so
+// touching the file without editing it is not a conflict.
+func (s fileStamp) checkUnchanged(configPath string) error {
+	info, err := os.Stat(configPath)
+	if os.IsNotExist(err) {
+		if s.exists {
+			return ErrConfigChanged
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to stat config file %s: %w", configPath, err)
+	}
+	if !s.exists {
+		return ErrConfigChanged
+	}
+	if info.ModTime().Equal(s.modTime) && info.Size() == s.size {
+		return nil
+	}
+
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to read config file %s: %w", configPath, err)
+	}
+	if sha256.Sum256(data) != s.sum {
+		return ErrConfigChanged
+	}
+	return nil
+}
+
+// ChangedOnDisk reports whether the file the config was loaded from, or any of
+// its shared layers, has been modified since it was last read or written by
+// this process.
+func (c Config) ChangedOnDisk() bool {
+	if c.doc == nil {
+		return false
+	}
+	configPath, err := GetConfigPath()
+	if err != nil {
+		return false
+	}
+	return errors.Is(c.doc.stamp.checkUnchanged(configPath), ErrConfigChanged) ||
+		layersChanged(configPath, c.doc.layers)
+}
+
+// OverwriteConfig writes the configuration to disk even if the file was
+// modified by another process since cfg was loaded.
+func OverwriteConfig(cfg Config) error {
+	return saveConfig(cfg, false)
+}
+
+// MergeConfig re-reads the configuration file, applies the target changes made
+// in cfg since it was loaded, and saves the result. Targets added or changed
+// on disk by another process are kept; a target changed on both sides is kept
+// in both versions, with a suffix such as "web (2)" added to the nickname of
+// this side. It returns the merged configuration.
+func MergeConfig(cfg Config) (Config, error) {
+	configPath, err := GetConfigPath()
+	if err != nil {
+		return Config{}, err
+	}
+	if err := ensureConfigDirExists(configPath); err != nil {
+		return Config{}, err
+	}
+
+	unlock, err := lockConfig(configPath, true)
+	if err != nil {
+		return Config{}, err
+	}
+	defer unlock()
+
+	current, err := loadConfig(configPath)
+	if err != nil {
+		return Config{}, err
+	}
+
+	var base []SSHTarget
+	if cfg.doc != nil {
+		base = cfg.doc.base
+	}
+	merged := mergeTargetLists(base, userTargets(cfg.Targets), userTargets(current.Targets))
+	current.Targets = combineTargets(sharedTargets(current.Targets), merged)
+
+	if err := writeConfig(configPath, current); err != nil {
+		return Config{}, err
+	}
+	return current, nil
+}
+
+// mergeTargetLists performs a three-way merge of target lists. Targets of
+// theirs are kept unless mine removed or changed them relative to base, and
+// targets that mine added or changed are appended, renamed if theirs uses the
+// same nickname.
+func mergeTargetLists(base, mine, theirs []SSHTarget) []SSHTarget {
+	contains := func(targets []SSHTarget, target SSHTarget) bool {
+		return slices.ContainsFunc(targets, func(t SSHTarget) bool { return sameTarget(t, target) })
+	}
+
+	merged := make([]SSHTarget, 0, len(theirs)+len(mine))
+	for _, target := range theirs {
+		if contains(base, target) && !contains(mine, target) {
+			continue
+		}
+		merged = append(merged, target)
+	}
+	for _, target := range mine {
+		if !contains(base, target) && !contains(merged, target) {
+			target.Nickname = freeNickname(merged, target.Nickname)
+			merged = append(merged, target)
+		}
+	}
+	return merged
+}
+
+// freeNickname returns nickname, or nickname with the first free numeric
+// suffix if a target of targets already uses it
+func freeNickname(targets []SSHTarget, nickname string) string {
+	taken := func(name string) bool {
+		return slices.ContainsFunc(targets, func(t SSHTarget) bool { return t.Nickname == name })
+	}
+	if nickname == "" || !taken(nickname) {
+		return nickname
+	}
+	for n := 2; ; n++ {
+		if name := fmt.Sprintf("%s (%d)", nickname, n); !taken(name) {
+			return name
+		}
+	}
+}
+
+// IndexOfTarget returns the index of target in targets, or -1. An identical
+// target is preferred, followed by one with the same nickname and then one
+// with the same user, host and port, so an edited target is still found.
+func IndexOfTarget(targets []SSHTarget, target SSHTarget) int {
+	for _, same := range []func(a, b SSHTarget) bool{sameTarget, sameNickname, sameAddress} {
+		for i, t := range targets {
+			if same(t, target) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// sameTarget reports whether both targets hold identical settings
+func sameTarget(a, b SSHTarget) bool {
+	return reflect.DeepEqual(comparableTarget(a), comparableTarget(b))
+}
+
+// comparableTarget normalizes the fields that have several equivalent forms
+func comparableTarget(t SSHTarget) SSHTarget {
+	t.Port = portOrDefault(t.Port)
+	if len(t.Tags) == 0 {
+		t.Tags = nil
+	}
+	if len(t.Options) == 0 {
+		t.Options = nil
+	}
+	return t
+}
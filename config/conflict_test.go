@@ -0,0 +1,135 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSaveConfigDetectsExternalChanges(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	restoreConfigPath := SetConfigPathProvider(func() (string, error) {
+		return configPath, nil
+	})
+	defer restoreConfigPath()
+
+	initial := Config{Targets: []SSHTarget{
+		{Nickname: "a", User: "root", Host: "a.example.com", Port: 22},
+		{Nickname: "b", User: "root", Host: "b.example.com", Port: 22},
+	}}
+	if err := SaveConfig(initial); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+
+	mine, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	theirs, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	// Touching the file without changing it is not a conflict
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(configPath, future, future); err != nil {
+		t.Fatalf("Failed to touch config: %v", err)
+	}
+
+	// Another instance adds a target and removes b
+	theirs.Targets = append(theirs.Targets[:1], SSHTarget{Nickname: "c", User: "root", Host: "c.example.com", Port: 22})
+	if err := SaveConfig(theirs); err != nil {
+		t.Fatalf("Failed to save other config: %v", err)
+	}
+
+	// This instance edits a and adds d
+	mine.Targets[0].Host = "a2.example.com"
+	mine.Targets = append(mine.Targets, SSHTarget{Nickname: "d", User: "root", Host: "d.example.com", Port: 22})
+	if err := SaveConfig(mine); !errors.Is(err, ErrConfigChanged) {
+		t.Fatalf("Expected ErrConfigChanged, got %v", err)
+	}
+
+	merged, err := MergeConfig(mine)
+	if err != nil {
+		t.Fatalf("Failed to merge config: %v", err)
+	}
+	var hosts []string
+	for _, target := range merged.Targets {
+		hosts = append(hosts, target.Host)
+	}
+	expected := []string{"c.example.com", "a2.example.com", "d.example.com"}
+	if len(hosts) != len(expected) {
+		t.Fatalf("Expected merged hosts %v, got %v", expected, hosts)
+	}
+	for i := range expected {
+		if hosts[i] != expected[i] {
+			t.Fatalf("Expected merged hosts %v, got %v", expected, hosts)
+		}
+	}
+
+	// The merged config is current, so saving it again succeeds
+	if err := SaveConfig(merged); err != nil {
+		t.Fatalf("Failed to save merged config: %v", err)
+	}
+
+	// The stale instance can still force its version
+	if err := SaveConfig(theirs); !errors.Is(err, ErrConfigChanged) {
+		t.Fatalf("Expected ErrConfigChanged for stale config, got %v", err)
+	}
+	if err := OverwriteConfig(theirs); err != nil {
+		t.Fatalf("Failed to overwrite config: %v", err)
+	}
+	reloaded, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("Failed to reload config: %v", err)
+	}
+	if len(reloaded.Targets) != 2 || reloaded.Targets[1].Host != "c.example.com" {
+		t.Errorf("Unexpected targets after overwrite: %+v", reloaded.Targets)
+	}
+}
+
+func TestMergeConfigTargetChangedOnBothSides(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	restoreConfigPath := SetConfigPathProvider(func() (string, error) {
+		return configPath, nil
+	})
+	defer restoreConfigPath()
+
+	if err := SaveConfig(Config{Targets: []SSHTarget{{Nickname: "web", User: "root", Host: "a.example.com", Port: 22}}}); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	mine, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	theirs, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	theirs.Targets[0].Port = 2222
+	if err := SaveConfig(theirs); err != nil {
+		t.Fatalf("Failed to save other config: %v", err)
+	}
+	mine.Targets[0].Host = "b.example.com"
+
+	// Both versions are kept under distinct nicknames
+	if _, err := MergeConfig(mine); err != nil {
+		t.Fatalf("Failed to merge config: %v", err)
+	}
+	reloaded, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("Failed to reload merged config: %v", err)
+	}
+	if len(reloaded.Targets) != 2 {
+		t.Fatalf("Expected both versions of web, got %+v", reloaded.Targets)
+	}
+	if got := reloaded.Targets[0]; got.Nickname != "web" || got.Port != 2222 {
+		t.Errorf("Expected their version under the original nickname, got %+v", got)
+	}
+	if got := reloaded.Targets[1]; got.Nickname != "web (2)" || got.Host != "b.example.com" {
+		t.Errorf("Expected this version to be renamed, got %+v", got)
+	}
+}
@@ -0,0 +1,302 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// document is the config file as it was last read or written. Saving merges
+// changes into a copy of node so comments, key order and keys unknown to
+// akumi survive, and stamp detects writes by other processes in between.
+type document struct {
+	node   *yaml.Node
+	indent int
+	stamp  fileStamp
+	// base holds the targets as they were on disk, used to merge conflicts
+	base []SSHTarget
+	// inherited is the theme of the shared layers with defaults applied;
+	// only colors that differ from it are saved
+	inherited ThemeColors
+	// inheritedDefaults holds the target defaults of the shared layers
+	inheritedDefaults TargetDefaults
+	// inheritedProbe and inheritedConnection hold the probe and connection
+	// settings of the shared layers
+	inheritedProbe      ProbeSettings
+	inheritedConnection ConnectionSettings
+	// layers records the shared layers merged below the file
+	layers []sharedLayer
+}
+
+// targetFields holds the YAML keys owned by SSHTarget. Other keys in a target
+// mapping are left untouched on save.
+var targetFields = yamlFieldNames(reflect.TypeOf(SSHTarget{}))
+
+// yamlFieldNames returns the YAML key of every field of the struct type t
+func yamlFieldNames(t reflect.Type) map[string]bool {
+	fields := make(map[string]bool)
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		if name != "" && name != "-" {
+			fields[name] = true
+		}
+	}
+	return fields
+}
+
+// parseDocument decodes data into a Config and keeps the YAML tree it came from
+func parseDocument(data []byte) (Config, error) {
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return Config{}, err
+	}
+
+	cfg := Config{doc: &document{indent: detectIndent(data)}}
+	if node.Kind == 0 {
+		return cfg, nil
+	}
+	// Older documents are upgraded in memory; the next save writes them back
+	if _, _, err := migrateDocument(&node); err != nil {
+		return Config{}, err
+	}
+	if problems := errorProblems(validateDocument(&node)); len(problems) > 0 {
+		return Config{}, &ValidationError{Problems: problems}
+	}
+	if err := node.Decode(&cfg); err != nil {
+		return Config{}, err
+	}
+	cfg.doc.node = &node
+	return cfg, nil
+}
+
+// refresh records data, just written to configPath, as the current version
+func (d *document) refresh(configPath string, data []byte, targets []SSHTarget) {
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err == nil && node.Kind != 0 {
+		d.node = &node
+	}
+	d.stamp = newFileStamp(configPath, data)
+	d.base = slices.Clone(targets)
+}
+
+// detectIndent guesses the indentation width of a YAML file, defaulting to
+// the width yaml.Marshal uses
+func detectIndent(data []byte) int {
+	indent := 0
+	for _, line := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		width := len(line) - len(trimmed)
+		if width == 0 || trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if indent == 0 || width < indent {
+			indent = width
+		}
+	}
+	if indent < 2 {
+		return 4
+	}
+	return indent
+}
+
+// marshalConfig renders cfg as YAML. When doc is set the changes are merged
+// into a copy of the original document instead of rendering from scratch.
+func marshalConfig(cfg Config, doc *document) ([]byte, error) {
+	if doc == nil || doc.node == nil {
+		data, err := yaml.Marshal(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal config to YAML: %w", err)
+		}
+		return data, nil
+	}
+
+	var updated yaml.Node
+	if err := updated.Encode(cfg); err != nil {
+		return nil, fmt.Errorf("failed to marshal config to YAML: %w", err)
+	}
+	root := cloneNode(doc.node)
+	mergeDocument(root, &updated)
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(doc.indent)
+	if err := enc.Encode(root); err != nil {
+		return nil, fmt.Errorf("failed to marshal config to YAML: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("failed to marshal config to YAML: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// mergeDocument applies the top-level keys of updated to the document root.
+// Keys that are missing from updated, such as an all-default theme, are kept.
+func mergeDocument(doc, updated *yaml.Node) {
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		head := doc.HeadComment
+		*doc = yaml.Node{Kind: yaml.DocumentNode, HeadComment: head, Content: []*yaml.Node{updated}}
+		return
+	}
+
+	root := doc.Content[0]
+	for i := 0; i+1 < len(updated.Content); i += 2 {
+		key, value := updated.Content[i], updated.Content[i+1]
+		existing := mappingValue(root, key.Value)
+		switch {
+		case existing == nil:
+			root.Content = append(root.Content, key, value)
+		case key.Value == "targets":
+			mergeTargets(existing, value)
+		default:
+			mergeMapping(existing, value, nil)
+		}
+	}
+}
+
+// mergeTargets rebuilds the targets sequence in the order of updated, reusing
+// the original mapping of every target that can still be identified so its
+// comments and unknown keys are kept
+func mergeTargets(dst, updated *yaml.Node) {
+	if dst.Kind != yaml.SequenceNode {
+		replaceNode(dst, updated)
+		return
+	}
+
+	original := make([]SSHTarget, len(dst.Content))
+	for i, item := range dst.Content {
+		_ = item.Decode(&original[i])
+	}
+	targets := make([]SSHTarget, len(updated.Content))
+	for i, item := range updated.Content {
+		_ = item.Decode(&targets[i])
+	}
+
+	used := make([]bool, len(dst.Content))
+	merged := make([]*yaml.Node, len(updated.Content))
+	for _, same := range []func(a, b SSHTarget) bool{sameTarget, sameNickname, sameAddress} {
+		for i, target := range targets {
+			if merged[i] != nil {
+				continue
+			}
+			for j, item := range dst.Content {
+				if used[j] || item.Kind != yaml.MappingNode || !same(target, original[j]) {
+					continue
+				}
+				used[j] = true
+				mergeMapping(item, updated.Content[i], func(key string) bool { return targetFields[key] })
+				merged[i] = item
+				break
+			}
+		}
+	}
+
+	for i := range merged {
+		if merged[i] == nil {
+			merged[i] = updated.Content[i]
+		}
+	}
+	dst.Content = merged
+}
+
+// sameNickname reports whether both targets share a nickname
+func sameNickname(a, b SSHTarget) bool {
+	return a.Nickname != "" && a.Nickname == b.Nickname
+}
+
+// sameAddress reports whether both targets connect to the same user@host:port
+func sameAddress(a, b SSHTarget) bool {
+	return a.User == b.User && a.Host == b.Host && portOrDefault(a.Port) == portOrDefault(b.Port)
+}
+
+func portOrDefault(port int) int {
+	if port == 0 {
+		return 22
+	}
+	return port
+}
+
+// mergeMapping copies every key of src into dst, keeping the position and
+// comments of keys dst already has. Keys for which owned returns true are
+// removed from dst when src no longer has them; a nil owned keeps every key.
+func mergeMapping(dst, src *yaml.Node, owned func(key string) bool) {
+	if dst.Kind != yaml.MappingNode || src.Kind != yaml.MappingNode {
+		replaceNode(dst, src)
+		return
+	}
+
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		key, value := src.Content[i], src.Content[i+1]
+		if existing := mappingValue(dst, key.Value); existing != nil {
+			mergeValue(existing, value)
+		} else {
+			dst.Content = append(dst.Content, key, value)
+		}
+	}
+
+	if owned == nil {
+		return
+	}
+	kept := dst.Content[:0]
+	for i := 0; i+1 < len(dst.Content); i += 2 {
+		key := dst.Content[i].Value
+		if owned(key) && mappingValue(src, key) == nil {
+			continue
+		}
+		kept = append(kept, dst.Content[i], dst.Content[i+1])
+	}
+	dst.Content = kept
+}
+
+// mergeValue updates dst to the value of src, leaving unchanged scalars alone
+// so their original quoting survives
+func mergeValue(dst, src *yaml.Node) {
+	switch {
+	case dst.Kind == yaml.MappingNode && src.Kind == yaml.MappingNode:
+		mergeMapping(dst, src, func(string) bool { return true })
+	case dst.Kind == yaml.ScalarNode && src.Kind == yaml.ScalarNode && dst.Value == src.Value:
+		// Unchanged
+	case dst.Kind == yaml.SequenceNode && src.Kind == yaml.SequenceNode && len(dst.Content) == len(src.Content):
+		for i := range dst.Content {
+			mergeValue(dst.Content[i], src.Content[i])
+		}
+	case dst.Kind == yaml.SequenceNode && src.Kind == yaml.SequenceNode:
+		style := dst.Style
+		replaceNode(dst, src)
+		dst.Style = style
+	default:
+		replaceNode(dst, src)
+	}
+}
+
+// replaceNode overwrites dst with src but keeps the comments and position of dst
+func replaceNode(dst, src *yaml.Node) {
+	head, line, foot := dst.HeadComment, dst.LineComment, dst.FootComment
+	pos := [2]int{dst.Line, dst.Column}
+	*dst = *src
+	dst.HeadComment, dst.LineComment, dst.FootComment = head, line, foot
+	dst.Line, dst.Column = pos[0], pos[1]
+}
+
+// mappingValue returns the value stored under key in a mapping node, or nil
+func mappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// cloneNode returns a deep copy of node
+func cloneNode(node *yaml.Node) *yaml.Node {
+	clone := *node
+	clone.Content = make([]*yaml.Node, len(node.Content))
+	for i, child := range node.Content {
+		clone.Content[i] = cloneNode(child)
+	}
+	return &clone
+}
@@ -0,0 +1,116 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const roundTripConfig = `# Managed by hand, please keep the comments
+version: 1
+editor: vim # unknown top-level key
+targets:
+  # Production web server
+  - nickname: web
+    user: deploy
+    host: web.example.com
+    description: keep me # unknown target key
+  - nickname: db
+    user: admin
+    host: db.example.com
+    port: 2222
+    tags: [prod, db]
+  - nickname: old
+    user: root
+    host: old.example.com
+theme:
+  primary_color: "#FF0000" # custom
+`
+
+func TestSaveConfigRoundTrip(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "akumi", "config.yaml")
+	restoreConfigPath := SetConfigPathProvider(func() (string, error) {
+		return configPath, nil
+	})
+	defer restoreConfigPath()
+
+	if err := os.MkdirAll(filepath.Dir(configPath), 0750); err != nil {
+		t.Fatalf("Failed to create config directory: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte(roundTripConfig), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	// Saving an unchanged config must not alter the file
+	if err := SaveConfig(cfg); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("Failed to read config: %v", err)
+	}
+	if string(data) != roundTripConfig {
+		t.Errorf("Unchanged config was rewritten:\n%s", data)
+	}
+
+	// Reorder, edit, delete and add targets the way the TUI does
+	cfg.Targets = []SSHTarget{
+		cfg.Targets[1],
+		{Nickname: "web", User: "deploy", Host: "www.example.com", Port: 22},
+		{Nickname: "new", User: "root", Host: "new.example.com", Port: 22},
+	}
+	if err := SaveConfig(cfg); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	data, err = os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("Failed to read config: %v", err)
+	}
+	content := string(data)
+
+	for _, want := range []string{
+		"# Managed by hand, please keep the comments",
+		"editor: vim # unknown top-level key",
+		"# Production web server",
+		"description: keep me # unknown target key",
+		"host: www.example.com",
+		"tags: [prod, db]",
+		`primary_color: "#FF0000" # custom`,
+		"nickname: new",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("Expected saved config to contain %q:\n%s", want, content)
+		}
+	}
+	if strings.Contains(content, "old.example.com") {
+		t.Errorf("Deleted target is still present:\n%s", content)
+	}
+	if strings.Contains(content, "secondary_color") {
+		t.Errorf("Default theme colors should not be written:\n%s", content)
+	}
+	if strings.Index(content, "nickname: db") > strings.Index(content, "nickname: web") {
+		t.Errorf("Targets were not saved in the new order:\n%s", content)
+	}
+
+	// The comment stays attached to the edited target
+	if !strings.Contains(content, "# Production web server\n  - nickname: web") {
+		t.Errorf("Target comment did not follow its target:\n%s", content)
+	}
+
+	reloaded, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("Failed to reload config: %v", err)
+	}
+	if len(reloaded.Targets) != 3 || reloaded.Targets[1].Host != "www.example.com" {
+		t.Errorf("Unexpected targets after reload: %+v", reloaded.Targets)
+	}
+	if reloaded.Theme.PrimaryColor != "#FF0000" {
+		t.Errorf("Expected custom primary color to survive, got %s", reloaded.Theme.PrimaryColor)
+	}
+}
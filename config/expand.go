@@ -0,0 +1,107 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// envPattern matches the ${NAME} environment variable references expanded in
+// target fields
+var envPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// templateFuncs are the functions available to target field templates
+var templateFuncs = template.FuncMap{
+	"env":   os.Getenv,
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"quote": ShellQuote,
+}
+
+// Expand returns a copy of the target with templates such as
+// {{.Nickname}}.corp.internal and ${NAME} environment variables expanded in
+// its user, host, identity file and jump host, and a leading ~ in the identity
+// file replaced with the home directory. Templates see the target as written.
+func (t SSHTarget) Expand() (SSHTarget, error) {
+	expanded := t
+	fields := []struct {
+		name  string
+		value *string
+	}{
+		{"user", &expanded.User},
+		{"host", &expanded.Host},
+		{"identity_file", &expanded.IdentityFile},
+		{"proxy_jump", &expanded.ProxyJump},
+	}
+	for _, field := range fields {
+		value, err := expandValue(*field.value, t)
+		if err != nil {
+			return t, fmt.Errorf("failed to expand %s of %s: %w", field.name, t, err)
+		}
+		*field.value = value
+	}
+
+	expanded.IdentityFile = expandHome(expanded.IdentityFile)
+	return expanded, nil
+}
+
+// expandValue executes value as a template of data and then substitutes
+// environment variables. Unset variables expand to an empty string.
+func expandValue(value string, data SSHTarget) (string, error) {
+	if strings.Contains(value, "{{") {
+		tmpl, err := parseTemplate(value)
+		if err != nil {
+			return "", err
+		}
+		var b strings.Builder
+		if err := tmpl.Execute(&b, data); err != nil {
+			return "", err
+		}
+		value = b.String()
+	}
+
+	return envPattern.ReplaceAllStringFunc(value, func(ref string) string {
+		return os.Getenv(envPattern.FindStringSubmatch(ref)[1])
+	}), nil
+}
+
+// parseTemplate parses a target field template
+func parseTemplate(value string) (*template.Template, error) {
+	return template.New("").Funcs(templateFuncs).Option("missingkey=error").Parse(value)
+}
+
+// checkTemplate reports template syntax errors and references to unknown
+// target fields in value
+func checkTemplate(value string) error {
+	if !strings.Contains(value, "{{") {
+		return nil
+	}
+	_, err := expandValue(value, SSHTarget{})
+	return err
+}
+
+// ParseConnectorTemplate parses the command line template of a custom
+// connector. It has the same functions as the templates of target fields.
+func ParseConnectorTemplate(text string) (*template.Template, error) {
+	return template.New("connector").Funcs(templateFuncs).Parse(text)
+}
+
+// ShellQuote quotes s for a POSIX shell, leaving words that need no quoting
+// as they are.
+func ShellQuote(s string) string {
+	if s != "" && strings.IndexFunc(s, needsQuote) < 0 {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// needsQuote reports whether r has a special meaning to the shell
+func needsQuote(r rune) bool {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		return false
+	}
+	return !strings.ContainsRune("@%+=:,./-_~", r)
+}
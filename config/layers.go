@@ -0,0 +1,198 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+// systemConfigDir holds the shared configuration layers installed for every
+// user of the machine.
+var systemConfigDir = "/etc/akumi"
+
+// SetSystemConfigDir allows tests to override the shared configuration directory
+func SetSystemConfigDir(dir string) func() {
+	oldDir := systemConfigDir
+	systemConfigDir = dir
+	return func() {
+		systemConfigDir = oldDir
+	}
+}
+
+// sharedLayer is a read-only config file merged below the user's own file.
+type sharedLayer struct {
+	path  string
+	stamp fileStamp
+	// node is the parsed document of the layer
+	node *yaml.Node
+}
+
+// sharedLayerPaths lists the read-only layers for configPath, lowest
+// precedence first: the system config, the system conf.d directory and the
+// conf.d directory next to the user config.
+func sharedLayerPaths(configPath string) []string {
+	paths := []string{filepath.Join(systemConfigDir, "config.yaml")}
+	paths = append(paths, confDFiles(filepath.Join(systemConfigDir, "conf.d"))...)
+	paths = append(paths, confDFiles(filepath.Join(filepath.Dir(configPath), "conf.d"))...)
+
+	return slices.DeleteFunc(paths, func(path string) bool {
+		return path == configPath
+	})
+}
+
+// confDFiles returns the YAML files in dir in lexical order
+func confDFiles(dir string) []string {
+	var files []string
+	for _, pattern := range []string{"*.yaml", "*.yml"} {
+		matches, _ := filepath.Glob(filepath.Join(dir, pattern))
+		files = append(files, matches...)
+	}
+	slices.Sort(files)
+	return files
+}
+
+// loadSharedLayers reads every existing shared layer and returns their
+// combined targets, tagged with their origin, and settings.
+func loadSharedLayers(configPath string) (Config, []sharedLayer, error) {
+	var (
+		shared Config
+		layers []sharedLayer
+	)
+	for _, path := range sharedLayerPaths(configPath) {
+		layer, err := readConfigFile(path)
+		if err != nil {
+			return Config{}, nil, err
+		}
+		if !layer.doc.stamp.exists {
+			continue
+		}
+
+		for i := range layer.Targets {
+			layer.Targets[i].Origin = path
+		}
+		shared.Targets = combineTargets(shared.Targets, layer.Targets)
+		shared.Theme = overlay(shared.Theme, layer.Theme)
+		shared.Defaults = overlay(shared.Defaults, layer.Defaults)
+		shared.Probe = overlay(shared.Probe, layer.Probe)
+		shared.Connection = overlay(shared.Connection, layer.Connection)
+		layers = append(layers, sharedLayer{path: path, stamp: layer.doc.stamp, node: layer.doc.node})
+	}
+	return shared, layers, nil
+}
+
+// combineTargets appends the targets of a higher layer to those of lower
+// layers. A target whose nickname is redefined by the higher layer is dropped.
+func combineTargets(lower, higher []SSHTarget) []SSHTarget {
+	combined := slices.DeleteFunc(slices.Clone(lower), func(t SSHTarget) bool {
+		return t.Nickname != "" && slices.ContainsFunc(higher, func(h SSHTarget) bool {
+			return h.Nickname == t.Nickname
+		})
+	})
+	return append(combined, higher...)
+}
+
+// userTargets returns the targets that belong to the writable user layer
+func userTargets(targets []SSHTarget) []SSHTarget {
+	return slices.DeleteFunc(slices.Clone(targets), SSHTarget.Shared)
+}
+
+// sharedTargets returns the targets that come from read-only layers
+func sharedTargets(targets []SSHTarget) []SSHTarget {
+	return slices.DeleteFunc(slices.Clone(targets), func(t SSHTarget) bool {
+		return !t.Shared()
+	})
+}
+
+// layeredSettings are the config sections merged field by field across layers
+type layeredSettings interface {
+	ThemeColors | TargetDefaults | ProbeSettings | ConnectionSettings
+}
+
+// overlay returns base with every field set in over replacing it. Maps such
+// as the connection templates are merged entry by entry.
+func overlay[T layeredSettings](base, over T) T {
+	fields := reflect.ValueOf(&base).Elem()
+	overrides := reflect.ValueOf(over)
+	for i := 0; i < fields.NumField(); i++ {
+		field, value := fields.Field(i), overrides.Field(i)
+		switch {
+		case value.IsZero():
+		case value.Kind() == reflect.Map:
+			merged := reflect.MakeMap(value.Type())
+			for _, m := range []reflect.Value{field, value} {
+				for iter := m.MapRange(); iter.Next(); {
+					merged.SetMapIndex(iter.Key(), iter.Value())
+				}
+			}
+			field.Set(merged)
+		default:
+			field.Set(value)
+		}
+	}
+	return base
+}
+
+// requireUsers checks that every target has a user once the layers are
+// merged: a target may leave it out only when some layer sets a default user.
+func requireUsers(configPath string, cfg Config, layers []sharedLayer) error {
+	if cfg.Defaults.User != "" {
+		return nil
+	}
+	for _, layer := range layers {
+		if problems := missingUsers(layer.node); len(problems) > 0 {
+			return &ValidationError{Path: layer.path, Problems: problems}
+		}
+	}
+	if problems := missingUsers(cfg.doc.node); len(problems) > 0 {
+		return &ValidationError{Path: configPath, Problems: problems}
+	}
+	return nil
+}
+
+// requireConnector checks that the connector selected in each layer is built
+// in or defined by a template of any layer.
+func requireConnector(configPath string, cfg Config, layers []sharedLayer) error {
+	for _, layer := range layers {
+		if problems := unknownConnector(layer.node, cfg.Connection.Templates); len(problems) > 0 {
+			return &ValidationError{Path: layer.path, Problems: problems}
+		}
+	}
+	if problems := unknownConnector(cfg.doc.node, cfg.Connection.Templates); len(problems) > 0 {
+		return &ValidationError{Path: configPath, Problems: problems}
+	}
+	return nil
+}
+
+// layersChanged reports whether any shared layer was modified, added or
+// removed since the layers were loaded
+func layersChanged(configPath string, layers []sharedLayer) bool {
+	var current []string
+	for _, path := range sharedLayerPaths(configPath) {
+		if _, err := os.Stat(path); err == nil {
+			current = append(current, path)
+		}
+	}
+	if len(current) != len(layers) {
+		return true
+	}
+	for i, layer := range layers {
+		if current[i] != layer.path || layer.stamp.checkUnchanged(layer.path) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadOnlyError is returned when changing a target from a shared layer.
+type ReadOnlyError struct {
+	// Target is the target that cannot be changed.
+	Target SSHTarget
+}
+
+func (e *ReadOnlyError) Error() string {
+	return fmt.Sprintf("%s is defined in the shared config %s and is read-only", e.Target, e.Target.Origin)
+}
@@ -0,0 +1,310 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeFile writes content to path, creating parent directories
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+func TestLoadConfigMergesLayers(t *testing.T) {
+	systemDir := t.TempDir()
+	restoreSystemDir := SetSystemConfigDir(systemDir)
+	defer restoreSystemDir()
+
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	restoreConfigPath := SetConfigPathProvider(func() (string, error) {
+		return configPath, nil
+	})
+	defer restoreConfigPath()
+
+	systemPath := filepath.Join(systemDir, "config.yaml")
+	writeFile(t, systemPath, `
+targets:
+  - nickname: bastion
+    user: ops
+    host: bastion.example.com
+  - nickname: db
+    user: ops
+    host: db-old.example.com
+theme:
+  primary_color: "#111111"
+  info_color: "#222222"
+`)
+	teamPath := filepath.Join(systemDir, "conf.d", "10-team.yaml")
+	writeFile(t, teamPath, `
+targets:
+  - nickname: db
+    user: ops
+    host: db.example.com
+`)
+	writeFile(t, configPath, `
+version: 1
+targets:
+  - nickname: laptop
+    user: me
+    host: laptop.local
+theme:
+  info_color: "#333333"
+`)
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	want := []SSHTarget{
+		{Nickname: "bastion", User: "ops", Host: "bastion.example.com", Port: 22, Origin: systemPath},
+		{Nickname: "db", User: "ops", Host: "db.example.com", Port: 22, Origin: teamPath},
+		{Nickname: "laptop", User: "me", Host: "laptop.local", Port: 22},
+	}
+	if len(cfg.Targets) != len(want) {
+		t.Fatalf("Expected %d targets, got %+v", len(want), cfg.Targets)
+	}
+	for i := range want {
+		if !sameTarget(cfg.Targets[i], want[i]) {
+			t.Errorf("Target %d: expected %+v, got %+v", i, want[i], cfg.Targets[i])
+		}
+	}
+
+	if cfg.Theme.PrimaryColor != "#111111" || cfg.Theme.InfoColor != "#333333" {
+		t.Errorf("Expected layered theme, got %+v", cfg.Theme)
+	}
+	if cfg.Theme.ErrorColor != DefaultTheme().ErrorColor {
+		t.Errorf("Expected default error color, got %q", cfg.Theme.ErrorColor)
+	}
+
+	// Saving writes only the user layer and its customized colors
+	cfg.Targets = append(cfg.Targets, SSHTarget{Nickname: "new", User: "me", Host: "new.local", Port: 22})
+	if err := SaveConfig(cfg); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("Failed to read config: %v", err)
+	}
+	saved := string(data)
+	for _, host := range []string{"bastion.example.com", "db.example.com", "#111111"} {
+		if strings.Contains(saved, host) {
+			t.Errorf("Shared value %q was written to the user config:\n%s", host, saved)
+		}
+	}
+	for _, value := range []string{"laptop.local", "new.local", "#333333"} {
+		if !strings.Contains(saved, value) {
+			t.Errorf("Expected %q in the user config:\n%s", value, saved)
+		}
+	}
+
+	shared, err := os.ReadFile(systemPath)
+	if err != nil {
+		t.Fatalf("Failed to read system config: %v", err)
+	}
+	if strings.Contains(string(shared), "new.local") {
+		t.Errorf("Shared layer was modified:\n%s", shared)
+	}
+}
+
+func TestUserConfDLayer(t *testing.T) {
+	restoreSystemDir := SetSystemConfigDir(t.TempDir())
+	defer restoreSystemDir()
+
+	configDir := t.TempDir()
+	configPath := filepath.Join(configDir, "config.yaml")
+	restoreConfigPath := SetConfigPathProvider(func() (string, error) {
+		return configPath, nil
+	})
+	defer restoreConfigPath()
+
+	writeFile(t, filepath.Join(configDir, "conf.d", "team.yml"), `
+targets:
+  - nickname: shared
+    user: ops
+    host: shared.example.com
+`)
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if len(cfg.Targets) != 1 || !cfg.Targets[0].Shared() {
+		t.Fatalf("Expected one shared target, got %+v", cfg.Targets)
+	}
+	if cfg.ChangedOnDisk() {
+		t.Error("Expected config to be unchanged after loading")
+	}
+
+	// A new team file counts as a change on disk
+	writeFile(t, filepath.Join(configDir, "conf.d", "extra.yaml"), "targets: []\n")
+	if !cfg.ChangedOnDisk() {
+		t.Error("Expected a new layer to be detected")
+	}
+}
+
+func TestSharedLayerValidationError(t *testing.T) {
+	systemDir := t.TempDir()
+	restoreSystemDir := SetSystemConfigDir(systemDir)
+	defer restoreSystemDir()
+
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	restoreConfigPath := SetConfigPathProvider(func() (string, error) {
+		return configPath, nil
+	})
+	defer restoreConfigPath()
+
+	systemPath := filepath.Join(systemDir, "config.yaml")
+	writeFile(t, systemPath, "targets:\n  - user: ops\n")
+
+	_, err := LoadConfig()
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Expected a validation error, got %v", err)
+	}
+	if validationErr.Path != systemPath {
+		t.Errorf("Expected error for %s, got %s", systemPath, validationErr.Path)
+	}
+}
+
+func TestSharedDefaultUser(t *testing.T) {
+	systemDir := t.TempDir()
+	restoreSystemDir := SetSystemConfigDir(systemDir)
+	defer restoreSystemDir()
+
+	configDir := t.TempDir()
+	configPath := filepath.Join(configDir, "config.yaml")
+	restoreConfigPath := SetConfigPathProvider(func() (string, error) {
+		return configPath, nil
+	})
+	defer restoreConfigPath()
+
+	// The default user comes from the shared layer only
+	writeFile(t, filepath.Join(systemDir, "config.yaml"), "defaults:\n  user: ops\n")
+	writeFile(t, filepath.Join(configDir, "conf.d", "10-team.yaml"), "targets:\n  - nickname: db\n    host: db.example.com\n")
+	writeFile(t, configPath, "version: 1\ntargets:\n  - nickname: web\n    host: web.example.com\n")
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("Expected targets without a user to load, got %v", err)
+	}
+	if cfg.Defaults.User != "ops" || len(cfg.Targets) != 2 {
+		t.Fatalf("Expected both targets with the shared default user, got %+v", cfg)
+	}
+	if _, problems, err := ValidateConfigFile(); err != nil || len(problems) != 0 {
+		t.Errorf("Expected no problems, got %v, %v", problems, err)
+	}
+
+	// A target added without a user loads again after saving
+	cfg.Targets = append(cfg.Targets, SSHTarget{Nickname: "cache", Host: "cache.example.com"})
+	if err := SaveConfig(cfg); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	if _, err := LoadConfig(); err != nil {
+		t.Errorf("Expected the saved config to load, got %v", err)
+	}
+
+	// Without a default user in any layer the target is rejected
+	writeFile(t, filepath.Join(systemDir, "config.yaml"), "theme:\n  primary_color: \"#111111\"\n")
+	_, err = LoadConfig()
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Path != filepath.Join(configDir, "conf.d", "10-team.yaml") {
+		t.Fatalf("Expected the conf.d target to be rejected, got %v", err)
+	}
+	_, problems, err := ValidateConfigFile()
+	if err != nil {
+		t.Fatalf("Failed to validate config: %v", err)
+	}
+	if len(problems) != 2 || problems[0].Field != "targets[0]" || problems[0].Line != 3 {
+		t.Errorf("Expected the targets without a user to be reported, got %v", problems)
+	}
+}
+
+func TestSharedConnectionAndProbe(t *testing.T) {
+	systemDir := t.TempDir()
+	restoreSystemDir := SetSystemConfigDir(systemDir)
+	defer restoreSystemDir()
+
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	restoreConfigPath := SetConfigPathProvider(func() (string, error) {
+		return configPath, nil
+	})
+	defer restoreConfigPath()
+
+	writeFile(t, filepath.Join(systemDir, "config.yaml"), `
+connection:
+  templates:
+    tsh: tsh ssh {{.Destination}}
+probe:
+  interval: 5m
+targets:
+  - nickname: teleport
+    user: ops
+    host: node.example.com
+    connector: tsh
+`)
+	writeFile(t, configPath, `version: 1
+connection:
+  connector: tsh
+  templates:
+    wrap: wrap {{.Destination}}
+probe:
+  concurrency: 2
+targets:
+  - nickname: laptop
+    user: me
+    host: laptop.local
+`)
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if len(cfg.Connection.Templates) != 2 || cfg.Connection.Templates["tsh"] == "" || cfg.Connection.Connector != "tsh" {
+		t.Errorf("Expected the shared and user templates to be merged, got %+v", cfg.Connection)
+	}
+	if cfg.Probe.Interval != 5*time.Minute || cfg.Probe.Concurrency != 2 {
+		t.Errorf("Expected the shared probe interval and the user concurrency, got %+v", cfg.Probe)
+	}
+	if _, problems, err := ValidateConfigFile(); err != nil || len(problems) != 0 {
+		t.Errorf("Expected the shared template to satisfy the connector, got %v, %v", problems, err)
+	}
+
+	// Only the user's own settings are written back
+	cfg.Targets = append(cfg.Targets, SSHTarget{Nickname: "web", User: "me", Host: "web.example.com"})
+	if err := SaveConfig(cfg); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("Failed to read config: %v", err)
+	}
+	for _, shared := range []string{"tsh ssh", "interval"} {
+		if strings.Contains(string(data), shared) {
+			t.Errorf("Expected %q from the shared layer not to be saved:\n%s", shared, data)
+		}
+	}
+	for _, own := range []string{"connector: tsh", "wrap {{.Destination}}", "concurrency: 2"} {
+		if !strings.Contains(string(data), own) {
+			t.Errorf("Expected %q to be kept:\n%s", own, data)
+		}
+	}
+
+	// Without the shared template the connector is unknown
+	writeFile(t, filepath.Join(systemDir, "config.yaml"), "probe:\n  interval: 5m\n")
+	_, err = LoadConfig()
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Path != configPath || validationErr.Problems[0].Field != "connection.connector" {
+		t.Errorf("Expected the unknown connector to be reported, got %v", err)
+	}
+}
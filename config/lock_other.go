@@ -0,0 +1,9 @@
+//go:build !unix
+
+package config
+
+// lockFile is a no-op on platforms without flock; the modification check in
+// SaveConfig still detects concurrent writers.
+func lockFile(path string, exclusive bool) (func(), error) {
+	return func() {}, nil
+}
@@ -0,0 +1,169 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CurrentVersion is the config schema version written by this build.
+const CurrentVersion = 1
+
+// migration upgrades a config document from one schema version to the next.
+type migration struct {
+	// from is the version the migration upgrades; it produces from+1.
+	from int
+	// description summarizes the change for "akumi config migrate".
+	description string
+	// apply rewrites the root mapping of the document in place.
+	apply func(root *yaml.Node) error
+}
+
+// migrations holds every schema upgrade in version order.
+var migrations = []migration{
+	{from: 0, description: "add version key", apply: migrateV0},
+}
+
+// migrateV0 upgrades files written before versioning. Their schema is the
+// same as version 1, so only the version key, added by migrateDocument, is
+// new.
+func migrateV0(*yaml.Node) error {
+	return nil
+}
+
+// migrateDocument upgrades a parsed config document to CurrentVersion in
+// place and returns the version it started from and the steps applied.
+func migrateDocument(doc *yaml.Node) (int, []string, error) {
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return CurrentVersion, nil, nil
+	}
+	root := doc.Content[0]
+
+	version := 0
+	if value := mappingValue(root, "version"); value != nil {
+		v, err := strconv.Atoi(value.Value)
+		if err != nil || v < 0 {
+			return 0, nil, fmt.Errorf("invalid config version %q", value.Value)
+		}
+		version = v
+	}
+	if version > CurrentVersion {
+		return version, nil, fmt.Errorf("config version %d is newer than the supported version %d, please upgrade akumi", version, CurrentVersion)
+	}
+
+	var steps []string
+	for _, m := range migrations {
+		if m.from < version {
+			continue
+		}
+		if err := m.apply(root); err != nil {
+			return version, nil, fmt.Errorf("failed to migrate config from version %d: %w", m.from, err)
+		}
+		steps = append(steps, fmt.Sprintf("v%d → v%d: %s", m.from, m.from+1, m.description))
+	}
+	if len(steps) > 0 {
+		setVersion(root, CurrentVersion)
+	}
+	return version, steps, nil
+}
+
+// setVersion stores version under the "version" key, inserting it as the
+// first key of the document if necessary
+func setVersion(root *yaml.Node, version int) {
+	value := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.Itoa(version)}
+	if existing := mappingValue(root, "version"); existing != nil {
+		replaceNode(existing, value)
+		return
+	}
+
+	key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "version"}
+	if len(root.Content) > 0 {
+		// Keep a file header comment above the new key
+		key.HeadComment, root.Content[0].HeadComment = root.Content[0].HeadComment, ""
+	}
+	root.Content = append([]*yaml.Node{key, value}, root.Content...)
+}
+
+// Migration describes the upgrade of the config file to CurrentVersion.
+type Migration struct {
+	// Path is the config file being migrated.
+	Path string
+	// From is the schema version of the file on disk.
+	From int
+	// Steps describes each migration that was applied.
+	Steps []string
+	// Before and After hold the file content before and after the upgrade.
+	Before, After []byte
+}
+
+// NeedsMigration reports whether the file is older than CurrentVersion.
+func (m Migration) NeedsMigration() bool {
+	return len(m.Steps) > 0
+}
+
+// PlanMigration reads the config file and computes its upgrade to
+// CurrentVersion without writing anything.
+func PlanMigration() (Migration, error) {
+	configPath, err := GetConfigPath()
+	if err != nil {
+		return Migration{}, err
+	}
+
+	plan := Migration{Path: configPath, From: CurrentVersion}
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return plan, nil
+		}
+		return Migration{}, fmt.Errorf("failed to read config file %s: %w", configPath, err)
+	}
+	plan.Before = data
+	plan.After = data
+
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return Migration{}, fmt.Errorf("failed to parse config file %s: %w", configPath, err)
+	}
+	plan.From, plan.Steps, err = migrateDocument(&node)
+	if err != nil || len(plan.Steps) == 0 {
+		return plan, err
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(detectIndent(data))
+	if err := enc.Encode(&node); err != nil {
+		return Migration{}, fmt.Errorf("failed to marshal config to YAML: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return Migration{}, fmt.Errorf("failed to marshal config to YAML: %w", err)
+	}
+	plan.After = buf.Bytes()
+	return plan, nil
+}
+
+// Apply writes the migrated config, backing up the previous version first.
+// It returns ErrConfigChanged if the file changed since the plan was made.
+func (m Migration) Apply() error {
+	if !m.NeedsMigration() {
+		return nil
+	}
+
+	unlock, err := lockConfig(m.Path, true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	current, err := os.ReadFile(m.Path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file %s: %w", m.Path, err)
+	}
+	if !bytes.Equal(current, m.Before) {
+		return ErrConfigChanged
+	}
+	return writeConfigFile(m.Path, m.After)
+}
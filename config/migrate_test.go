@@ -0,0 +1,68 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMigrateUnversionedConfig(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	restoreConfigPath := SetConfigPathProvider(func() (string, error) {
+		return configPath, nil
+	})
+	defer restoreConfigPath()
+
+	legacy := `# My servers
+targets:
+  - user: root
+    host: db.example.com
+    group: prod/db
+    tags:
+      - env:prod
+      - role:db # owner
+`
+	if err := os.WriteFile(configPath, []byte(legacy), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	// Loading upgrades the document in memory without touching the file
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("Failed to load legacy config: %v", err)
+	}
+	if cfg.Version != CurrentVersion || cfg.Targets[0].Group != "prod/db" || len(cfg.Targets[0].Tags) != 2 {
+		t.Errorf("Unexpected migrated config: %+v", cfg)
+	}
+
+	plan, err := PlanMigration()
+	if err != nil {
+		t.Fatalf("Failed to plan migration: %v", err)
+	}
+	if plan.From != 0 || !plan.NeedsMigration() {
+		t.Fatalf("Expected a migration from version 0, got %+v", plan)
+	}
+	// Only the version key is added; the rest of the file is kept as written
+	if after := string(plan.After); after != "# My servers\nversion: 1\n"+strings.TrimPrefix(legacy, "# My servers\n") {
+		t.Errorf("Expected only the version key to be added:\n%s", after)
+	}
+
+	if err := plan.Apply(); err != nil {
+		t.Fatalf("Failed to apply migration: %v", err)
+	}
+	if backups, _ := ListBackups(); len(backups) != 1 {
+		t.Errorf("Expected the legacy file to be backed up, got %d backups", len(backups))
+	}
+	if plan, err = PlanMigration(); err != nil || plan.NeedsMigration() {
+		t.Errorf("Expected no further migration, got %+v, %v", plan, err)
+	}
+
+	// Files from a newer akumi are rejected rather than silently downgraded
+	if err := os.WriteFile(configPath, []byte("version: 99\ntargets: []\n"), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	if _, err := LoadConfig(); err == nil {
+		t.Error("Expected an error for a config from a newer version")
+	}
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+)
+
+// DefaultProfile is the profile stored in the main config file.
+const DefaultProfile = "default"
+
+// activeProfile is the profile whose config file GetConfigPath returns
+var activeProfile = DefaultProfile
+
+// profileNamePattern matches the names accepted for profiles. Names are used
+// as directory names, so path separators and leading dots are rejected.
+var profileNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// ValidateProfileName returns an error if name cannot be used as a profile.
+func ValidateProfileName(name string) error {
+	if !profileNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid profile name %q: use letters, digits, '.', '_' and '-'", name)
+	}
+	return nil
+}
+
+// ActiveProfile returns the name of the profile in use.
+func ActiveProfile() string {
+	return activeProfile
+}
+
+// SetProfile switches GetConfigPath to the config file of the named profile
+// and returns a function restoring the previous profile. The profile is
+// created on first use.
+func SetProfile(name string) (func(), error) {
+	if err := ValidateProfileName(name); err != nil {
+		return nil, err
+	}
+	oldProfile := activeProfile
+	activeProfile = name
+	return func() {
+		activeProfile = oldProfile
+	}, nil
+}
+
+// profileConfigPath returns the config file of the named profile. Profiles
+// other than the default live in their own directory next to the main config
+// file, so each keeps its own backups and conf.d layers.
+func profileConfigPath(mainConfigPath, name string) string {
+	if name == DefaultProfile {
+		return mainConfigPath
+	}
+	return filepath.Join(filepath.Dir(mainConfigPath), "profiles", name, "config.yaml")
+}
+
+// ListProfiles returns the default profile followed by every other profile in
+// alphabetical order.
+func ListProfiles() ([]string, error) {
+	mainConfigPath, err := configPathProvider()
+	if err != nil {
+		return nil, err
+	}
+
+	profilesDir := filepath.Join(filepath.Dir(mainConfigPath), "profiles")
+	entries, err := os.ReadDir(profilesDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read profiles directory %s: %w", profilesDir, err)
+	}
+
+	var profiles []string
+	for _, entry := range entries {
+		if entry.IsDir() && entry.Name() != DefaultProfile && ValidateProfileName(entry.Name()) == nil {
+			profiles = append(profiles, entry.Name())
+		}
+	}
+	slices.Sort(profiles)
+	return append([]string{DefaultProfile}, profiles...), nil
+}
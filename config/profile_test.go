@@ -0,0 +1,102 @@
+package config
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestProfiles(t *testing.T) {
+	restoreSystemDir := SetSystemConfigDir(t.TempDir())
+	defer restoreSystemDir()
+
+	configDir := t.TempDir()
+	mainPath := filepath.Join(configDir, "config.yaml")
+	restoreConfigPath := SetConfigPathProvider(func() (string, error) {
+		return mainPath, nil
+	})
+	defer restoreConfigPath()
+
+	if err := SaveConfig(Config{Targets: []SSHTarget{{User: "me", Host: "home.local"}}}); err != nil {
+		t.Fatalf("Failed to save default profile: %v", err)
+	}
+
+	for _, name := range []string{"", "../acme", ".hidden", "a/b"} {
+		if _, err := SetProfile(name); err == nil {
+			t.Errorf("Expected profile name %q to be rejected", name)
+		}
+	}
+
+	restoreProfile, err := SetProfile("acme")
+	if err != nil {
+		t.Fatalf("Failed to set profile: %v", err)
+	}
+	defer restoreProfile()
+
+	if ActiveProfile() != "acme" {
+		t.Errorf("Expected active profile acme, got %s", ActiveProfile())
+	}
+	wantPath := filepath.Join(configDir, "profiles", "acme", "config.yaml")
+	if path, _ := GetConfigPath(); path != wantPath {
+		t.Errorf("Expected config path %s, got %s", wantPath, path)
+	}
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("Failed to load profile: %v", err)
+	}
+	if len(cfg.Targets) != 0 {
+		t.Errorf("Expected a new profile to start empty, got %+v", cfg.Targets)
+	}
+	cfg.Targets = append(cfg.Targets, SSHTarget{User: "ops", Host: "acme.example.com"})
+	if err := SaveConfig(cfg); err != nil {
+		t.Fatalf("Failed to save profile: %v", err)
+	}
+
+	profiles, err := ListProfiles()
+	if err != nil {
+		t.Fatalf("Failed to list profiles: %v", err)
+	}
+	if want := []string{DefaultProfile, "acme"}; !reflect.DeepEqual(profiles, want) {
+		t.Errorf("Expected profiles %v, got %v", want, profiles)
+	}
+
+	restoreProfile()
+	cfg, err = LoadConfig()
+	if err != nil {
+		t.Fatalf("Failed to load default profile: %v", err)
+	}
+	if len(cfg.Targets) != 1 || cfg.Targets[0].Host != "home.local" {
+		t.Errorf("Expected the default profile to be unchanged, got %+v", cfg.Targets)
+	}
+}
+
+func TestTargetDefaults(t *testing.T) {
+	defaults := TargetDefaults{User: "ops", IdentityFile: "/keys/id_acme", ProxyJump: "bastion"}
+	targets := []SSHTarget{
+		{Nickname: "bastion", Host: "bastion.acme.com", Port: 22},
+		{Nickname: "db", Host: "db.acme.internal", Port: 22},
+		{Nickname: "web", User: "deploy", Host: "web.acme.internal", Port: 22, IdentityFile: "/keys/id_web", ProxyJump: "other.acme.com"},
+	}
+
+	tests := []struct {
+		target SSHTarget
+		want   string
+	}{
+		{targets[0], "-i /keys/id_acme ops@bastion.acme.com"},
+		{targets[1], "-i /keys/id_acme -J ops@bastion.acme.com ops@db.acme.internal"},
+		{targets[2], "-i /keys/id_web -J other.acme.com deploy@web.acme.internal"},
+	}
+	for _, tt := range tests {
+		got := strings.Join(defaults.Resolve(tt.target, targets).GetSSHCommand(), " ")
+		if got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.target.Nickname, tt.want, got)
+		}
+	}
+
+	// Without a user, ssh chooses its own default
+	if got := (SSHTarget{Host: "example.com"}).GetSSHCommand(); !reflect.DeepEqual(got, []string{"example.com"}) {
+		t.Errorf("Expected only the host, got %v", got)
+	}
+}
@@ -0,0 +1,590 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// maxIncludeDepth limits nested Include directives, mirroring OpenSSH.
+const maxIncludeDepth = 16
+
+// SSHConfigHost is a concrete host resolved from an OpenSSH client config.
+type SSHConfigHost struct {
+	// Alias is the name used on the Host line.
+	Alias string
+	// HostName is the real hostname to connect to.
+	HostName string
+	// User is the login user, empty if not specified.
+	User string
+	// Port is the SSH port, 0 if not specified.
+	Port int
+	// IdentityFile is the first IdentityFile configured for the host.
+	IdentityFile string
+	// ProxyJump is the raw ProxyJump value for the host.
+	ProxyJump string
+	// ForwardAgent is true when ForwardAgent is set to yes.
+	ForwardAgent bool
+}
+
+// ToTarget converts the host into an SSHTarget using fallbackUser when no User is set.
+func (h SSHConfigHost) ToTarget(fallbackUser string) SSHTarget {
+	target := SSHTarget{
+		Nickname:     h.Alias,
+		User:         h.User,
+		Host:         h.HostName,
+		Port:         h.Port,
+		IdentityFile: h.IdentityFile,
+		ProxyJump:    h.ProxyJump,
+		ForwardAgent: h.ForwardAgent,
+	}
+	if target.User == "" {
+		target.User = fallbackUser
+	}
+	if target.Host == "" {
+		target.Host = h.Alias
+	}
+	if target.Port == 0 {
+		target.Port = 22
+	}
+	return target
+}
+
+// sshConfigOption is a single keyword/value pair from an OpenSSH config file.
+type sshConfigOption struct {
+	key   string
+	value string
+}
+
+// sshConfigBlock groups options under the patterns of a Host line.
+type sshConfigBlock struct {
+	patterns []string
+	options  []sshConfigOption
+}
+
+// DefaultSSHConfigPath returns the path to the user's OpenSSH client config.
+func DefaultSSHConfigPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user home directory: %w", err)
+	}
+	return filepath.Join(home, ".ssh", "config"), nil
+}
+
+// ImportSSHConfig parses the OpenSSH client config at path and returns
+// every concrete host as an SSHTarget.
+func ImportSSHConfig(path string) ([]SSHTarget, error) {
+	hosts, err := ParseSSHConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	fallbackUser := currentUsername()
+	targets := make([]SSHTarget, 0, len(hosts))
+	for _, h := range hosts {
+		targets = append(targets, h.ToTarget(fallbackUser))
+	}
+	return targets, nil
+}
+
+// ParseSSHConfigFile parses the OpenSSH client config at path, following
+// Include directives, and resolves every concrete Host alias.
+func ParseSSHConfigFile(path string) ([]SSHConfigHost, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ssh config %s: %w", path, err)
+	}
+	defer f.Close()
+
+	blocks, err := parseSSHConfigBlocks(f, filepath.Dir(path), 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ssh config %s: %w", path, err)
+	}
+	return resolveSSHConfigHosts(blocks), nil
+}
+
+// ParseSSHConfig parses OpenSSH client config data from r. Relative Include
+// paths are resolved against baseDir.
+func ParseSSHConfig(r io.Reader, baseDir string) ([]SSHConfigHost, error) {
+	blocks, err := parseSSHConfigBlocks(r, baseDir, 0)
+	if err != nil {
+		return nil, err
+	}
+	return resolveSSHConfigHosts(blocks), nil
+}
+
+// parseSSHConfigBlocks splits the config into Host blocks. Options before the
+// first Host line belong to an implicit "*" block.
+func parseSSHConfigBlocks(r io.Reader, baseDir string, depth int) ([]sshConfigBlock, error) {
+	if depth > maxIncludeDepth {
+		return nil, fmt.Errorf("include nested too deeply")
+	}
+
+	blocks := []sshConfigBlock{{patterns: []string{"*"}}}
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		keyword, args := splitSSHConfigLine(scanner.Text())
+		if keyword == "" {
+			continue
+		}
+
+		current := &blocks[len(blocks)-1]
+		switch keyword {
+		case "host":
+			if len(args) == 0 {
+				return nil, fmt.Errorf("line %d: Host requires at least one pattern", lineNo)
+			}
+			blocks = append(blocks, sshConfigBlock{patterns: args})
+		case "match":
+			// Match criteria are not evaluated; treat the block as never matching.
+			blocks = append(blocks, sshConfigBlock{})
+		case "include":
+			patterns := current.patterns
+			for _, arg := range args {
+				included, err := parseSSHConfigInclude(arg, baseDir, depth)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %w", lineNo, err)
+				}
+				// Leading options of an included file inherit the enclosing block.
+				if len(included) > 0 {
+					included[0].patterns = patterns
+				}
+				blocks = append(blocks, included...)
+			}
+			blocks = append(blocks, sshConfigBlock{patterns: patterns})
+		default:
+			if len(args) == 0 {
+				continue
+			}
+			current.options = append(current.options, sshConfigOption{
+				key:   keyword,
+				value: strings.Join(args, " "),
+			})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return blocks, nil
+}
+
+// parseSSHConfigInclude expands an Include argument and parses every matching file.
+func parseSSHConfigInclude(pattern, baseDir string, depth int) ([]sshConfigBlock, error) {
+	pattern = expandHome(pattern)
+	if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(baseDir, pattern)
+	}
+
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid include pattern %q: %w", pattern, err)
+	}
+
+	var blocks []sshConfigBlock
+	for _, match := range matches {
+		info, err := os.Stat(match)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		f, err := os.Open(match)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open included file %s: %w", match, err)
+		}
+		included, err := parseSSHConfigBlocks(f, baseDir, depth+1)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", match, err)
+		}
+		blocks = append(blocks, included...)
+	}
+	return blocks, nil
+}
+
+// splitSSHConfigLine returns the lower-cased keyword and arguments of a config line.
+func splitSSHConfigLine(line string) (string, []string) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", nil
+	}
+
+	// Keywords may be separated from their arguments by "=" as well as whitespace.
+	sep := strings.IndexAny(line, " \t=")
+	if sep < 0 {
+		return strings.ToLower(line), nil
+	}
+	keyword := strings.ToLower(line[:sep])
+	rest := strings.TrimLeft(line[sep:], " \t")
+	rest = strings.TrimPrefix(rest, "=")
+
+	return keyword, splitSSHConfigArgs(rest)
+}
+
+// splitSSHConfigArgs splits arguments on whitespace, honouring double quotes
+// and stopping at a trailing comment.
+func splitSSHConfigArgs(s string) []string {
+	var (
+		args    []string
+		current strings.Builder
+		inQuote bool
+		hasArg  bool
+	)
+	for _, r := range s {
+		switch {
+		case r == '"':
+			inQuote = !inQuote
+			hasArg = true
+		case !inQuote && (r == ' ' || r == '\t'):
+			if hasArg {
+				args = append(args, current.String())
+				current.Reset()
+				hasArg = false
+			}
+		case !inQuote && r == '#' && !hasArg:
+			return args
+		default:
+			current.WriteRune(r)
+			hasArg = true
+		}
+	}
+	if hasArg {
+		args = append(args, current.String())
+	}
+	return args
+}
+
+// resolveSSHConfigHosts computes the effective settings for every concrete alias.
+// As in OpenSSH, the first value obtained for each keyword wins.
+func resolveSSHConfigHosts(blocks []sshConfigBlock) []SSHConfigHost {
+	var hosts []SSHConfigHost
+	seen := make(map[string]bool)
+	for _, block := range blocks {
+		for _, pattern := range block.patterns {
+			if !isConcreteHostPattern(pattern) || seen[pattern] {
+				continue
+			}
+			seen[pattern] = true
+			hosts = append(hosts, resolveSSHConfigHost(pattern, blocks))
+		}
+	}
+	return hosts
+}
+
+// resolveSSHConfigHost applies every block matching alias in order.
+func resolveSSHConfigHost(alias string, blocks []sshConfigBlock) SSHConfigHost {
+	values := make(map[string]string)
+	for _, block := range blocks {
+		if !matchHostPatterns(alias, block.patterns) {
+			continue
+		}
+		for _, opt := range block.options {
+			if _, ok := values[opt.key]; !ok {
+				values[opt.key] = opt.value
+			}
+		}
+	}
+
+	host := SSHConfigHost{
+		Alias:        alias,
+		HostName:     alias,
+		User:         values["user"],
+		IdentityFile: values["identityfile"],
+		ProxyJump:    values["proxyjump"],
+		ForwardAgent: strings.EqualFold(values["forwardagent"], "yes"),
+	}
+	if hostName, ok := values["hostname"]; ok {
+		host.HostName = strings.ReplaceAll(hostName, "%h", alias)
+	}
+	if port, err := strconv.Atoi(values["port"]); err == nil {
+		host.Port = port
+	}
+	return host
+}
+
+// isConcreteHostPattern reports whether pattern names a single host.
+func isConcreteHostPattern(pattern string) bool {
+	return pattern != "" && !strings.ContainsAny(pattern, "*?!")
+}
+
+// matchHostPatterns reports whether host matches a Host line's pattern list.
+// A negated pattern that matches excludes the host regardless of other patterns.
+func matchHostPatterns(host string, patterns []string) bool {
+	matched := false
+	for _, pattern := range patterns {
+		negated := strings.HasPrefix(pattern, "!")
+		pattern = strings.TrimPrefix(pattern, "!")
+		if !matchWildcard(strings.ToLower(pattern), strings.ToLower(host)) {
+			continue
+		}
+		if negated {
+			return false
+		}
+		matched = true
+	}
+	return matched
+}
+
+// matchWildcard matches s against a pattern supporting '*' and '?'.
+func matchWildcard(pattern, s string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 0 && pattern[0] == '*' {
+				pattern = pattern[1:]
+			}
+			if pattern == "" {
+				return true
+			}
+			for i := 0; i <= len(s); i++ {
+				if matchWildcard(pattern, s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if s == "" {
+				return false
+			}
+		default:
+			if s == "" || s[0] != pattern[0] {
+				return false
+			}
+		}
+		pattern = pattern[1:]
+		s = s[1:]
+	}
+	return s == ""
+}
+
+// expandHome replaces a leading "~" with the user's home directory.
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~"))
+}
+
+// currentUsername returns the local username, as ssh does when no User is set.
+func currentUsername() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+// ImportCandidate is an imported target together with its duplicate status.
+type ImportCandidate struct {
+	// Target is the imported SSH target.
+	Target SSHTarget
+	// Duplicate is true when the target already exists in the configuration.
+	Duplicate bool
+}
+
+// ClassifyImport marks each imported target as new or duplicate of an existing one.
+// Targets are duplicates when their nickname or user@host:port already exists.
+func ClassifyImport(existing, imported []SSHTarget) []ImportCandidate {
+	nicknames := make(map[string]bool)
+	addresses := make(map[string]bool)
+	for _, t := range existing {
+		if t.Nickname != "" {
+			nicknames[t.Nickname] = true
+		}
+		addresses[targetAddress(t)] = true
+	}
+
+	candidates := make([]ImportCandidate, 0, len(imported))
+	for _, t := range imported {
+		duplicate := (t.Nickname != "" && nicknames[t.Nickname]) || addresses[targetAddress(t)]
+		candidates = append(candidates, ImportCandidate{Target: t, Duplicate: duplicate})
+		// Guard against duplicates within the imported set itself
+		if t.Nickname != "" {
+			nicknames[t.Nickname] = true
+		}
+		addresses[targetAddress(t)] = true
+	}
+	return candidates
+}
+
+// targetAddress returns a normalized user@host:port key for duplicate detection.
+func targetAddress(t SSHTarget) string {
+	port := t.Port
+	if port == 0 {
+		port = 22
+	}
+	return fmt.Sprintf("%s@%s:%d", t.User, strings.ToLower(t.Host), port)
+}
+
+const (
+	// managedBlockBegin marks the start of the Akumi-managed section of an exported file.
+	managedBlockBegin = "# BEGIN AKUMI MANAGED BLOCK"
+	// managedBlockEnd marks the end of the Akumi-managed section of an exported file.
+	managedBlockEnd = "# END AKUMI MANAGED BLOCK"
+)
+
+// DefaultSSHExportPath returns the default include file targets are exported to.
+func DefaultSSHExportPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user home directory: %w", err)
+	}
+	return filepath.Join(home, ".ssh", "config.d", "akumi"), nil
+}
+
+// SSHConfigAlias returns the Host alias used when exporting the target.
+// Whitespace in the nickname is replaced so the alias stays a single token.
+func (t SSHTarget) SSHConfigAlias() string {
+	if t.Nickname == "" {
+		return t.Host
+	}
+	return strings.Join(strings.Fields(t.Nickname), "-")
+}
+
+// RenderSSHConfig renders targets as OpenSSH client config Host blocks.
+// ProxyJump hops naming other targets are rewritten to their exported alias.
+func RenderSSHConfig(targets []SSHTarget) string {
+	expanded := make([]SSHTarget, len(targets))
+	for i, t := range targets {
+		if e, err := t.Expand(); err == nil {
+			t = e
+		}
+		expanded[i] = t
+	}
+	aliases := exportAliases(expanded)
+	jumpAliases := make(map[string]string)
+	for i, t := range expanded {
+		if t.Nickname != "" {
+			jumpAliases[t.SSHConfigAlias()] = aliases[i]
+			jumpAliases[t.Nickname] = aliases[i]
+		}
+	}
+
+	var b strings.Builder
+	for i, t := range expanded {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "Host %s\n", aliases[i])
+		fmt.Fprintf(&b, "    HostName %s\n", t.Host)
+		if t.User != "" {
+			fmt.Fprintf(&b, "    User %s\n", t.User)
+		}
+		if t.Port != 0 && t.Port != 22 {
+			fmt.Fprintf(&b, "    Port %d\n", t.Port)
+		}
+		if t.IdentityFile != "" {
+			fmt.Fprintf(&b, "    IdentityFile %s\n", quoteSSHConfigValue(t.IdentityFile))
+		}
+		if t.ProxyJump != "" {
+			fmt.Fprintf(&b, "    ProxyJump %s\n", exportProxyJump(t.ProxyJump, jumpAliases))
+		}
+		if t.ForwardAgent {
+			b.WriteString("    ForwardAgent yes\n")
+		}
+		for _, key := range sortedOptionKeys(t.Options) {
+			fmt.Fprintf(&b, "    %s %s\n", key, t.Options[key])
+		}
+	}
+	return b.String()
+}
+
+// exportAliases returns a distinct Host alias for every target. ssh applies
+// only the first block of an alias, so targets sharing one, such as two
+// targets on the same host without a nickname, get a numeric suffix.
+// Nicknamed targets are given their alias first.
+func exportAliases(targets []SSHTarget) []string {
+	aliases := make([]string, len(targets))
+	taken := make(map[string]bool)
+	claim := func(i int) {
+		alias := targets[i].SSHConfigAlias()
+		for n := 2; taken[alias]; n++ {
+			alias = fmt.Sprintf("%s-%d", targets[i].SSHConfigAlias(), n)
+		}
+		aliases[i] = alias
+		taken[alias] = true
+	}
+	for i, t := range targets {
+		if t.Nickname != "" {
+			claim(i)
+		}
+	}
+	for i, t := range targets {
+		if t.Nickname == "" {
+			claim(i)
+		}
+	}
+	return aliases
+}
+
+// exportProxyJump maps jump hops that name targets to their exported aliases.
+func exportProxyJump(proxyJump string, jumpAliases map[string]string) string {
+	hops := strings.Split(proxyJump, ",")
+	for i, hop := range hops {
+		hop = strings.TrimSpace(hop)
+		if alias, ok := jumpAliases[hop]; ok {
+			hop = alias
+		}
+		hops[i] = hop
+	}
+	return strings.Join(hops, ",")
+}
+
+// quoteSSHConfigValue wraps values containing whitespace in double quotes.
+func quoteSSHConfigValue(value string) string {
+	if strings.ContainsAny(value, " \t") {
+		return `"` + value + `"`
+	}
+	return value
+}
+
+// WriteManagedSSHConfig writes targets into the managed block of the file at path.
+// Content outside the block markers is preserved; the block is appended if missing.
+func WriteManagedSSHConfig(path string, targets []SSHTarget) error {
+	existing, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read ssh config %s: %w", path, err)
+	}
+
+	block := managedBlockBegin + "\n" + RenderSSHConfig(targets) + managedBlockEnd + "\n"
+	content := replaceManagedBlock(string(existing), block)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", path, err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		return fmt.Errorf("failed to write ssh config %s: %w", path, err)
+	}
+	return nil
+}
+
+// replaceManagedBlock swaps the managed block inside content for block.
+func replaceManagedBlock(content, block string) string {
+	start := strings.Index(content, managedBlockBegin)
+	if start >= 0 {
+		if end := strings.Index(content[start:], managedBlockEnd); end >= 0 {
+			end += start + len(managedBlockEnd)
+			// Consume the newline that terminated the end marker
+			if end < len(content) && content[end] == '\n' {
+				end++
+			}
+			return content[:start] + block + content[end:]
+		}
+	}
+
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	if content != "" {
+		content += "\n"
+	}
+	return content + block
+}
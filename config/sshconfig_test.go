@@ -0,0 +1,172 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseSSHConfig(t *testing.T) {
+	testDir := t.TempDir()
+
+	// Included file with its own host
+	includeDir := filepath.Join(testDir, "config.d")
+	if err := os.MkdirAll(includeDir, 0750); err != nil {
+		t.Fatalf("Failed to create include directory: %v", err)
+	}
+	included := "Host included\n    HostName 10.0.0.9\n    User inc\n"
+	if err := os.WriteFile(filepath.Join(includeDir, "extra"), []byte(included), 0600); err != nil {
+		t.Fatalf("Failed to write include file: %v", err)
+	}
+
+	data := `# Global settings
+Include config.d/*
+
+Host dev-server web
+    HostName %h.example.com
+    User alice
+    Port 2222
+    IdentityFile ~/.ssh/id_dev
+
+Host db
+    HostName=10.0.0.5
+    ProxyJump bastion
+
+Host *.internal !skip.internal
+    User internal
+
+Host * !db
+    User fallback
+    Port 2200
+`
+
+	hosts, err := ParseSSHConfig(strings.NewReader(data), testDir)
+	if err != nil {
+		t.Fatalf("Failed to parse ssh config: %v", err)
+	}
+
+	byAlias := make(map[string]SSHConfigHost)
+	for _, h := range hosts {
+		byAlias[h.Alias] = h
+	}
+
+	if len(hosts) != 4 {
+		t.Fatalf("Expected 4 concrete hosts, got %d: %+v", len(hosts), hosts)
+	}
+
+	dev := byAlias["dev-server"]
+	if dev.HostName != "dev-server.example.com" || dev.User != "alice" || dev.Port != 2222 {
+		t.Errorf("Unexpected dev-server entry: %+v", dev)
+	}
+	if dev.IdentityFile != "~/.ssh/id_dev" {
+		t.Errorf("Expected identity file ~/.ssh/id_dev, got %s", dev.IdentityFile)
+	}
+
+	db := byAlias["db"]
+	if db.HostName != "10.0.0.5" || db.ProxyJump != "bastion" {
+		t.Errorf("Unexpected db entry: %+v", db)
+	}
+	if db.User != "" || db.Port != 0 {
+		t.Errorf("Expected db to be excluded from wildcard block, got %+v", db)
+	}
+
+	inc := byAlias["included"]
+	if inc.HostName != "10.0.0.9" || inc.User != "inc" || inc.Port != 2200 {
+		t.Errorf("Unexpected included entry: %+v", inc)
+	}
+}
+
+func TestClassifyImport(t *testing.T) {
+	existing := []SSHTarget{
+		{Nickname: "prod", User: "root", Host: "prod.example.com", Port: 22},
+	}
+	imported := []SSHTarget{
+		{Nickname: "prod", User: "admin", Host: "other.example.com", Port: 22},
+		{Nickname: "alias", User: "root", Host: "PROD.example.com"},
+		{Nickname: "new", User: "root", Host: "new.example.com", Port: 22},
+		{Nickname: "new", User: "root", Host: "new.example.com", Port: 22},
+	}
+
+	candidates := ClassifyImport(existing, imported)
+	expected := []bool{true, true, false, true}
+	for i, c := range candidates {
+		if c.Duplicate != expected[i] {
+			t.Errorf("Candidate %d (%s): expected duplicate=%v, got %v", i, c.Target, expected[i], c.Duplicate)
+		}
+	}
+}
+
+func TestWriteManagedSSHConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.d", "akumi")
+
+	handWritten := "Host personal\n    HostName 192.168.0.2\n"
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(handWritten), 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	targets := []SSHTarget{
+		{Nickname: "dev server", User: "root", Host: "dev.example.com", Port: 22},
+		{User: "admin", Host: "db.example.com", Port: 2222},
+	}
+	if err := WriteManagedSSHConfig(path, targets); err != nil {
+		t.Fatalf("Failed to write managed config: %v", err)
+	}
+
+	// Writing again must replace the block rather than append a second one
+	if err := WriteManagedSSHConfig(path, targets[:1]); err != nil {
+		t.Fatalf("Failed to rewrite managed config: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read managed config: %v", err)
+	}
+	content := string(data)
+
+	if !strings.HasPrefix(content, handWritten) {
+		t.Errorf("Hand-written entries were not preserved:\n%s", content)
+	}
+	if strings.Count(content, managedBlockBegin) != 1 {
+		t.Errorf("Expected exactly one managed block:\n%s", content)
+	}
+	if strings.Contains(content, "db.example.com") {
+		t.Errorf("Stale entry left in managed block:\n%s", content)
+	}
+
+	hosts, err := ParseSSHConfigFile(path)
+	if err != nil {
+		t.Fatalf("Failed to parse exported config: %v", err)
+	}
+	if len(hosts) != 2 || hosts[1].Alias != "dev-server" || hosts[1].User != "root" {
+		t.Errorf("Unexpected hosts parsed from exported config: %+v", hosts)
+	}
+}
+
+func TestRenderSSHConfigUniqueAliases(t *testing.T) {
+	targets := []SSHTarget{
+		{User: "root", Host: "db"},
+		{User: "admin", Host: "db"},
+		{Nickname: "db", User: "ops", Host: "db.example.com"},
+		{Nickname: "web", User: "root", Host: "web.example.com", ProxyJump: "db"},
+	}
+
+	var aliases []string
+	for _, line := range strings.Split(RenderSSHConfig(targets), "\n") {
+		if alias, ok := strings.CutPrefix(line, "Host "); ok {
+			aliases = append(aliases, alias)
+		}
+	}
+	// The nickname keeps its alias and hosts without one are numbered
+	want := []string{"db-2", "db-3", "db", "web"}
+	if !slices.Equal(aliases, want) {
+		t.Errorf("Expected aliases %v, got %v", want, aliases)
+	}
+	if !strings.Contains(RenderSSHConfig(targets), "ProxyJump db\n") {
+		t.Errorf("Expected the jump host to keep its nickname alias:\n%s", RenderSSHConfig(targets))
+	}
+}
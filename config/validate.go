@@ -0,0 +1,431 @@
+package config
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Problem is a single validation error found in a config file.
+type Problem struct {
+	// Line and Column locate the offending YAML node, starting at 1.
+	Line   int
+	Column int
+	// Field is the path of the offending value, e.g. "targets[2].port".
+	Field string
+	// Message describes what is wrong.
+	Message string
+	// Warning marks problems that do not stop the file from loading, such
+	// as unknown keys akumi keeps but ignores.
+	Warning bool
+}
+
+// Error returns the problem formatted as "line:column: field: message", with
+// "warning: " before the field of warnings.
+func (p Problem) Error() string {
+	if p.Warning {
+		return fmt.Sprintf("%d:%d: warning: %s: %s", p.Line, p.Column, p.Field, p.Message)
+	}
+	return fmt.Sprintf("%d:%d: %s: %s", p.Line, p.Column, p.Field, p.Message)
+}
+
+// errorProblems returns the problems that are not warnings
+func errorProblems(problems []Problem) []Problem {
+	var errs []Problem
+	for _, p := range problems {
+		if !p.Warning {
+			errs = append(errs, p)
+		}
+	}
+	return errs
+}
+
+// ValidationError reports every problem found while loading a config file.
+type ValidationError struct {
+	// Path is the config file that was validated.
+	Path string
+	// Problems lists the problems in document order.
+	Problems []Problem
+}
+
+// Error summarizes the problems on a single line.
+func (e *ValidationError) Error() string {
+	msg := "invalid config"
+	if e.Path != "" {
+		msg += " file " + e.Path
+	}
+	msg += ": " + e.Problems[0].Error()
+	if len(e.Problems) > 1 {
+		msg += fmt.Sprintf(" (and %d more problems)", len(e.Problems)-1)
+	}
+	return msg
+}
+
+// colorPattern matches the hex colors accepted in the theme.
+var colorPattern = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// validator collects problems while walking a config document.
+type validator struct {
+	problems []Problem
+}
+
+func (v *validator) addf(node *yaml.Node, field, format string, args ...any) {
+	v.problems = append(v.problems, Problem{
+		Line:    node.Line,
+		Column:  node.Column,
+		Field:   field,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// warnf records a problem that does not prevent loading the file
+func (v *validator) warnf(node *yaml.Node, field, format string, args ...any) {
+	v.addf(node, field, format, args...)
+	v.problems[len(v.problems)-1].Warning = true
+}
+
+// validateDocument checks a parsed config document and returns every problem.
+func validateDocument(doc *yaml.Node) []Problem {
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		return nil
+	}
+
+	var v validator
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		v.addf(root, "config", "must be a mapping with targets and theme")
+		return v.problems
+	}
+
+	if defaults := mappingValue(root, "defaults"); defaults != nil {
+		v.validateDefaults(defaults)
+	}
+	if targets := mappingValue(root, "targets"); targets != nil {
+		v.validateTargets(targets)
+	}
+	if theme := mappingValue(root, "theme"); theme != nil {
+		v.validateTheme(theme)
+	}
+	if probe := mappingValue(root, "probe"); probe != nil {
+		v.validateProbe(probe)
+	}
+	if connection := mappingValue(root, "connection"); connection != nil {
+		v.validateConnection(connection)
+	}
+	return v.problems
+}
+
+// validateTargets checks every target. A missing user is left to
+// missingUsers, since the default user may come from another layer.
+func (v *validator) validateTargets(targets *yaml.Node) {
+	if targets.Kind != yaml.SequenceNode {
+		if targets.Tag != "!!null" {
+			v.addf(targets, "targets", "must be a list of targets")
+		}
+		return
+	}
+
+	nicknames := make(map[string]*yaml.Node)
+	for i, target := range targets.Content {
+		field := fmt.Sprintf("targets[%d]", i)
+		if target.Kind != yaml.MappingNode {
+			v.addf(target, field, "must be a mapping")
+			continue
+		}
+
+		for _, key := range []string{"user", "host"} {
+			value := mappingValue(target, key)
+			switch {
+			case value == nil && key == "user":
+			case value == nil:
+				v.addf(target, field, "%s is required", key)
+			case value.Kind != yaml.ScalarNode:
+				v.addf(value, field+"."+key, "must be a string")
+			case strings.TrimSpace(value.Value) == "":
+				v.addf(value, field+"."+key, "must not be empty")
+			}
+		}
+
+		if port := mappingValue(target, "port"); port != nil {
+			if n, err := strconv.Atoi(port.Value); port.Kind != yaml.ScalarNode || err != nil || n < 1 || n > 65535 {
+				v.addf(port, field+".port", "must be a number between 1 and 65535, got %q", port.Value)
+			}
+		}
+
+		for _, key := range []string{"nickname", "group", "identity_file", "proxy_jump", "connector"} {
+			if value := mappingValue(target, key); value != nil && value.Kind != yaml.ScalarNode {
+				v.addf(value, field+"."+key, "must be a string")
+			}
+		}
+		v.validateTemplates(target, field)
+		if tags := mappingValue(target, "tags"); tags != nil && tags.Kind != yaml.SequenceNode {
+			v.addf(tags, field+".tags", "must be a list")
+		}
+		if options := mappingValue(target, "options"); options != nil && options.Kind != yaml.MappingNode {
+			v.addf(options, field+".options", "must be a mapping of ssh option names to values")
+		}
+		if forward := mappingValue(target, "forward_agent"); forward != nil {
+			var b bool
+			if err := forward.Decode(&b); err != nil {
+				v.addf(forward, field+".forward_agent", "must be true or false, got %q", forward.Value)
+			}
+		}
+
+		if nickname := mappingValue(target, "nickname"); nickname != nil && nickname.Kind == yaml.ScalarNode && nickname.Value != "" {
+			if first, ok := nicknames[nickname.Value]; ok {
+				v.addf(nickname, field+".nickname", "duplicate nickname %q, first used on line %d", nickname.Value, first.Line)
+			} else {
+				nicknames[nickname.Value] = nickname
+			}
+		}
+	}
+}
+
+func (v *validator) validateDefaults(defaults *yaml.Node) {
+	if defaults.Kind != yaml.MappingNode {
+		if defaults.Tag != "!!null" {
+			v.addf(defaults, "defaults", "must be a mapping of target settings")
+		}
+		return
+	}
+
+	known := yamlFieldNames(reflect.TypeOf(TargetDefaults{}))
+	for i := 0; i+1 < len(defaults.Content); i += 2 {
+		key, value := defaults.Content[i], defaults.Content[i+1]
+		field := "defaults." + key.Value
+		if !known[key.Value] {
+			v.warnf(key, field, "unknown default, ignored; expected user, identity_file or proxy_jump")
+			continue
+		}
+		if value.Kind != yaml.ScalarNode {
+			v.addf(value, field, "must be a string")
+		}
+	}
+	v.validateTemplates(defaults, "defaults")
+}
+
+// validateTemplates checks the templates in the expandable fields of a target
+// or the defaults
+func (v *validator) validateTemplates(mapping *yaml.Node, field string) {
+	for _, key := range []string{"user", "host", "identity_file", "proxy_jump"} {
+		value := mappingValue(mapping, key)
+		if value == nil || value.Kind != yaml.ScalarNode {
+			continue
+		}
+		if err := checkTemplate(value.Value); err != nil {
+			v.addf(value, field+"."+key, "invalid template: %v", err)
+		}
+	}
+}
+
+// missingUsers reports the targets of a config document that have no user.
+// They are only a problem when no layer sets a default user.
+func missingUsers(doc *yaml.Node) []Problem {
+	if doc == nil || doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		return nil
+	}
+	targets := mappingValue(doc.Content[0], "targets")
+	if targets == nil || targets.Kind != yaml.SequenceNode {
+		return nil
+	}
+
+	var v validator
+	for i, target := range targets.Content {
+		if target.Kind == yaml.MappingNode && mappingValue(target, "user") == nil {
+			v.addf(target, fmt.Sprintf("targets[%d]", i), "user is required when no default user is set")
+		}
+	}
+	return v.problems
+}
+
+// unknownConnector reports the connection.connector of a config document if
+// it names neither a built-in connector nor one of templates, which are merged
+// from every layer.
+func unknownConnector(doc *yaml.Node, templates map[string]string) []Problem {
+	if doc == nil || doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		return nil
+	}
+	connection := mappingValue(doc.Content[0], "connection")
+	if connection == nil || connection.Kind != yaml.MappingNode {
+		return nil
+	}
+	value := mappingValue(connection, "connector")
+	if value == nil || value.Kind != yaml.ScalarNode {
+		return nil
+	}
+	switch _, ok := templates[value.Value]; {
+	case ok, value.Value == ConnectorOpenSSH, value.Value == ConnectorMosh, value.Value == ConnectorBuiltin:
+		return nil
+	}
+
+	var v validator
+	v.addf(value, "connection.connector", "unknown connector %q, expected openssh, mosh, builtin or a name from connection.templates", value.Value)
+	return v.problems
+}
+
+func (v *validator) validateTheme(theme *yaml.Node) {
+	if theme.Kind != yaml.MappingNode {
+		if theme.Tag != "!!null" {
+			v.addf(theme, "theme", "must be a mapping of colors")
+		}
+		return
+	}
+
+	known := yamlFieldNames(reflect.TypeOf(ThemeColors{}))
+	for i := 0; i+1 < len(theme.Content); i += 2 {
+		key, value := theme.Content[i], theme.Content[i+1]
+		field := "theme." + key.Value
+		if !known[key.Value] {
+			// Unknown keys are kept on save, so they must not lock the user out
+			v.warnf(key, field, "unknown theme color, ignored")
+			continue
+		}
+		if !isColor(value) {
+			v.addf(value, field, "must be a hex color like #5E81AC or an ANSI color number, got %q", value.Value)
+		}
+	}
+}
+
+func (v *validator) validateProbe(probe *yaml.Node) {
+	if probe.Kind != yaml.MappingNode {
+		if probe.Tag != "!!null" {
+			v.addf(probe, "probe", "must be a mapping of probe settings")
+		}
+		return
+	}
+
+	known := yamlFieldNames(reflect.TypeOf(ProbeSettings{}))
+	for i := 0; i+1 < len(probe.Content); i += 2 {
+		key, value := probe.Content[i], probe.Content[i+1]
+		field := "probe." + key.Value
+		switch {
+		case !known[key.Value]:
+			v.warnf(key, field, "unknown probe setting, ignored")
+		case key.Value == "disabled":
+			var b bool
+			if err := value.Decode(&b); err != nil {
+				v.addf(value, field, "must be true or false, got %q", value.Value)
+			}
+		case key.Value == "concurrency":
+			if n, err := strconv.Atoi(value.Value); value.Kind != yaml.ScalarNode || err != nil || n < 1 {
+				v.addf(value, field, "must be a positive number, got %q", value.Value)
+			}
+		default:
+			if d, err := time.ParseDuration(value.Value); value.Kind != yaml.ScalarNode || err != nil || d <= 0 {
+				v.addf(value, field, "must be a duration such as 3s or 1m, got %q", value.Value)
+			}
+		}
+	}
+}
+
+func (v *validator) validateConnection(connection *yaml.Node) {
+	if connection.Kind != yaml.MappingNode {
+		if connection.Tag != "!!null" {
+			v.addf(connection, "connection", "must be a mapping of connection settings")
+		}
+		return
+	}
+
+	connectors := map[string]bool{ConnectorOpenSSH: true, ConnectorMosh: true, ConnectorBuiltin: true}
+	if templates := mappingValue(connection, "templates"); templates != nil {
+		if templates.Kind != yaml.MappingNode {
+			v.addf(templates, "connection.templates", "must be a mapping of connector names to command lines")
+		} else {
+			for i := 0; i+1 < len(templates.Content); i += 2 {
+				name, value := templates.Content[i], templates.Content[i+1]
+				field := "connection.templates." + name.Value
+				switch {
+				case connectors[name.Value]:
+					v.addf(name, field, "must not replace the built-in connector %q", name.Value)
+				case value.Kind != yaml.ScalarNode || strings.TrimSpace(value.Value) == "":
+					v.addf(value, field, "must be a command line")
+				default:
+					if _, err := ParseConnectorTemplate(value.Value); err != nil {
+						v.addf(value, field, "invalid template: %v", err)
+					}
+				}
+				connectors[name.Value] = true
+			}
+		}
+	}
+
+	known := yamlFieldNames(reflect.TypeOf(ConnectionSettings{}))
+	for i := 0; i+1 < len(connection.Content); i += 2 {
+		key, value := connection.Content[i], connection.Content[i+1]
+		field := "connection." + key.Value
+		switch {
+		case !known[key.Value]:
+			v.warnf(key, field, "unknown connection setting, ignored")
+		case key.Value == "templates":
+		case value.Kind != yaml.ScalarNode:
+			v.addf(value, field, "must be a string")
+		}
+	}
+}
+
+// isColor reports whether node holds a color lipgloss understands: a hex
+// color or an ANSI 256 color number
+func isColor(node *yaml.Node) bool {
+	if node.Kind != yaml.ScalarNode {
+		return false
+	}
+	if colorPattern.MatchString(node.Value) {
+		return true
+	}
+	n, err := strconv.Atoi(node.Value)
+	return err == nil && n >= 0 && n <= 255
+}
+
+// ValidateConfigFile checks the configuration file and returns every problem
+// found, including warnings. A missing file is valid. Syntax errors are returned as an error.
+func ValidateConfigFile() (string, []Problem, error) {
+	configPath, err := GetConfigPath()
+	if err != nil {
+		return "", nil, err
+	}
+
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return configPath, nil, nil
+		}
+		return configPath, nil, fmt.Errorf("failed to read config file %s: %w", configPath, err)
+	}
+
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return configPath, nil, fmt.Errorf("failed to parse config file %s: %w", configPath, err)
+	}
+	if _, _, err := migrateDocument(&node); err != nil {
+		return configPath, nil, err
+	}
+	problems := validateDocument(&node)
+
+	// Targets may leave out the user if this file or a shared layer sets a
+	// default user, and the connector may come from a shared template
+	shared, _, err := loadSharedLayers(configPath)
+	if err != nil {
+		return configPath, nil, err
+	}
+	var own struct {
+		Defaults   TargetDefaults     `yaml:"defaults"`
+		Connection ConnectionSettings `yaml:"connection"`
+	}
+	_ = node.Decode(&own)
+	if overlay(shared.Defaults, own.Defaults).User == "" {
+		problems = append(problems, missingUsers(&node)...)
+	}
+	problems = append(problems, unknownConnector(&node, overlay(shared.Connection, own.Connection).Templates)...)
+	slices.SortStableFunc(problems, func(a, b Problem) int {
+		return cmp.Or(cmp.Compare(a.Line, b.Line), cmp.Compare(a.Column, b.Column))
+	})
+	return configPath, problems, nil
+}
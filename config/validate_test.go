@@ -0,0 +1,306 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestValidateConfig(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	restoreConfigPath := SetConfigPathProvider(func() (string, error) {
+		return configPath, nil
+	})
+	defer restoreConfigPath()
+
+	data := `version: 1
+targets:
+  - nickname: web
+    user: root
+    host: web.example.com
+  - nickname: web
+    user: root
+    host: ""
+    port: 70000
+  - user: admin
+    forward_agent: maybe
+theme:
+  primary_color: "#12345"
+  text_color: "250"
+`
+	if err := os.WriteFile(configPath, []byte(data), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	_, problems, err := ValidateConfigFile()
+	if err != nil {
+		t.Fatalf("Failed to validate config: %v", err)
+	}
+
+	expected := []Problem{
+		{Line: 6, Column: 15, Field: "targets[1].nickname"},
+		{Line: 8, Column: 11, Field: "targets[1].host"},
+		{Line: 9, Column: 11, Field: "targets[1].port"},
+		{Line: 10, Column: 5, Field: "targets[2]"},
+		{Line: 11, Column: 20, Field: "targets[2].forward_agent"},
+		{Line: 13, Column: 18, Field: "theme.primary_color"},
+	}
+	byField := make(map[string]Problem)
+	for _, p := range problems {
+		byField[p.Field] = p
+	}
+	if len(problems) != len(expected) {
+		t.Errorf("Expected %d problems, got %d: %v", len(expected), len(problems), problems)
+	}
+	for _, want := range expected {
+		got, ok := byField[want.Field]
+		if !ok {
+			t.Errorf("Missing problem for %s", want.Field)
+			continue
+		}
+		if got.Line != want.Line || got.Column != want.Column {
+			t.Errorf("%s: expected %d:%d, got %d:%d", want.Field, want.Line, want.Column, got.Line, got.Column)
+		}
+	}
+
+	// Loading reports the same problems as a structured error
+	_, err = LoadConfig()
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Expected a ValidationError, got %v", err)
+	}
+	if validationErr.Path != configPath || len(validationErr.Problems) != len(expected) {
+		t.Errorf("Unexpected validation error: %+v", validationErr)
+	}
+}
+
+func TestValidateDefaults(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	restoreConfigPath := SetConfigPathProvider(func() (string, error) {
+		return configPath, nil
+	})
+	defer restoreConfigPath()
+
+	// A default user makes the user of each target optional
+	data := `version: 1
+defaults:
+  user: ops
+  jump: bastion
+targets:
+  - host: db.example.com
+`
+	if err := os.WriteFile(configPath, []byte(data), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	_, problems, err := ValidateConfigFile()
+	if err != nil {
+		t.Fatalf("Failed to validate config: %v", err)
+	}
+	if len(problems) != 1 || problems[0].Field != "defaults.jump" || problems[0].Line != 4 || !problems[0].Warning {
+		t.Errorf("Expected only a warning for the unknown default, got %v", problems)
+	}
+	if _, err := LoadConfig(); err != nil {
+		t.Errorf("Expected the config to load despite the unknown default, got %v", err)
+	}
+}
+
+func TestValidateTemplates(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	restoreConfigPath := SetConfigPathProvider(func() (string, error) {
+		return configPath, nil
+	})
+	defer restoreConfigPath()
+
+	data := `version: 1
+targets:
+  - nickname: web
+    user: ${USER}
+    host: "{{.Nickname}}.corp.internal"
+  - user: root
+    host: "{{.Name}}.corp.internal"
+  - user: "{{env"
+    host: db.example.com
+`
+	if err := os.WriteFile(configPath, []byte(data), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	_, problems, err := ValidateConfigFile()
+	if err != nil {
+		t.Fatalf("Failed to validate config: %v", err)
+	}
+	if len(problems) != 2 || problems[0].Field != "targets[1].host" || problems[1].Field != "targets[2].user" {
+		t.Errorf("Expected the two invalid templates to be reported, got %v", problems)
+	}
+}
+
+func TestValidateConnection(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	restoreConfigPath := SetConfigPathProvider(func() (string, error) {
+		return configPath, nil
+	})
+	defer restoreConfigPath()
+
+	data := `version: 1
+connection:
+  connector: tsh
+  templates:
+    tsh: tsh ssh {{.Destination}}
+    mosh: mosh --predict=always {{.Destination}}
+    builtin: ssh {{.Destination}}
+    broken: "wrap {{.Host"
+targets:
+  - user: root
+    host: db.example.com
+    connector: [mosh]
+`
+	if err := os.WriteFile(configPath, []byte(data), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	_, problems, err := ValidateConfigFile()
+	if err != nil {
+		t.Fatalf("Failed to validate config: %v", err)
+	}
+	var fields []string
+	for _, problem := range problems {
+		fields = append(fields, problem.Field)
+	}
+	slices.Sort(fields)
+	want := []string{"connection.templates.broken", "connection.templates.builtin", "connection.templates.mosh", "targets[0].connector"}
+	if !slices.Equal(fields, want) {
+		t.Errorf("Expected problems for %v, got %v", want, problems)
+	}
+}
+
+func TestValidateWarnings(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	restoreConfigPath := SetConfigPathProvider(func() (string, error) {
+		return configPath, nil
+	})
+	defer restoreConfigPath()
+
+	data := `version: 1
+targets:
+  - user: root
+    host: web.example.com
+theme:
+  primary_color: "#5E81AC"
+  accent_color: "#FF0000"
+`
+	if err := os.WriteFile(configPath, []byte(data), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	// An unknown theme key is a warning and does not stop loading
+	_, problems, err := ValidateConfigFile()
+	if err != nil {
+		t.Fatalf("Failed to validate config: %v", err)
+	}
+	if len(problems) != 1 || !problems[0].Warning || problems[0].Field != "theme.accent_color" {
+		t.Errorf("Expected a warning for the unknown theme key, got %v", problems)
+	}
+	if _, err := LoadConfig(); err != nil {
+		t.Errorf("Expected the config to load despite the warning, got %v", err)
+	}
+
+	// Port 0 is outside the documented range
+	data = "version: 1\ntargets:\n  - user: root\n    host: web.example.com\n    port: 0\n"
+	if err := os.WriteFile(configPath, []byte(data), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	if _, problems, _ := ValidateConfigFile(); len(problems) != 1 || problems[0].Field != "targets[0].port" || problems[0].Warning {
+		t.Errorf("Expected port 0 to be rejected, got %v", problems)
+	}
+}
+
+func TestSaveRejectsInvalidConfig(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	restoreConfigPath := SetConfigPathProvider(func() (string, error) {
+		return configPath, nil
+	})
+	defer restoreConfigPath()
+
+	data := "version: 1\ntargets:\n  - nickname: web\n    user: root\n    host: a.example.com\n  - nickname: db\n    user: root\n    host: b.example.com\n"
+	if err := os.WriteFile(configPath, []byte(data), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	// A save that the next load would reject leaves the file alone
+	cfg.Targets[1].Nickname = "web"
+	err = SaveConfig(cfg)
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Problems[0].Field != "targets[1].nickname" {
+		t.Fatalf("Expected the duplicate nickname to be refused, got %v", err)
+	}
+	if saved, _ := os.ReadFile(configPath); string(saved) != data {
+		t.Errorf("Expected the config to be unchanged, got:\n%s", saved)
+	}
+	if _, err := LoadConfig(); err != nil {
+		t.Errorf("Expected the config to still load, got %v", err)
+	}
+}
+
+func TestValidateUnknownProbeSetting(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	restoreConfigPath := SetConfigPathProvider(func() (string, error) {
+		return configPath, nil
+	})
+	defer restoreConfigPath()
+
+	data := "version: 1\nprobe:\n  interval: 1m\n  jitter: 5s\ntargets: []\n"
+	if err := os.WriteFile(configPath, []byte(data), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	_, problems, err := ValidateConfigFile()
+	if err != nil {
+		t.Fatalf("Failed to validate config: %v", err)
+	}
+	if len(problems) != 1 || problems[0].Field != "probe.jitter" || !problems[0].Warning {
+		t.Errorf("Expected a warning for the unknown probe setting, got %v", problems)
+	}
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("Expected the config to load despite the unknown setting, got %v", err)
+	}
+	if cfg.Probe.Interval != time.Minute {
+		t.Errorf("Expected the known settings to apply, got %+v", cfg.Probe)
+	}
+}
+
+func TestValidateUnknownConnectionSetting(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	restoreConfigPath := SetConfigPathProvider(func() (string, error) {
+		return configPath, nil
+	})
+	defer restoreConfigPath()
+
+	data := "version: 1\nconnection:\n  connector: mosh\n  retries: 3\ntargets: []\n"
+	if err := os.WriteFile(configPath, []byte(data), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	_, problems, err := ValidateConfigFile()
+	if err != nil {
+		t.Fatalf("Failed to validate config: %v", err)
+	}
+	if len(problems) != 1 || problems[0].Field != "connection.retries" || !problems[0].Warning {
+		t.Errorf("Expected a warning for the unknown connection setting, got %v", problems)
+	}
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("Expected the config to load despite the unknown setting, got %v", err)
+	}
+	if cfg.Connection.Connector != ConnectorMosh {
+		t.Errorf("Expected the known settings to apply, got %+v", cfg.Connection)
+	}
+}
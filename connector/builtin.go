@@ -0,0 +1,449 @@
+package connector
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/muesli/cancelreader"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+
+	"github.com/omegaatt36/akumi/config"
+	"github.com/omegaatt36/akumi/hostkey"
+)
+
+// DefaultBuiltinTimeout bounds connecting to and authenticating with each
+// host when Builtin.Timeout is not set.
+const DefaultBuiltinTimeout = 15 * time.Second
+
+// defaultIdentityFiles are the keys tried when a target has no identity file,
+// in the order ssh tries them
+var defaultIdentityFiles = []string{"id_ed25519", "id_ecdsa", "id_rsa"}
+
+// Builtin connects with the SSH client built into akumi, for systems without
+// an ssh binary. It authenticates with the SSH agent and private keys,
+// verifies host keys against known_hosts and runs an interactive shell in a
+// pseudo-terminal. Of the ssh options, only StrictHostKeyChecking is honoured.
+type Builtin struct {
+	// KnownHosts is the known_hosts file. Defaults to ~/.ssh/known_hosts.
+	KnownHosts string
+	// Timeout bounds connecting to and authenticating with each host.
+	Timeout time.Duration
+}
+
+// Connect prepares a session with target. Nothing is dialled before Run.
+func (c Builtin) Connect(target config.SSHTarget) (Session, error) {
+	if expanded, err := target.Expand(); err == nil {
+		target = expanded
+	}
+	knownHosts := c.KnownHosts
+	if knownHosts == "" {
+		path, err := hostkey.DefaultPath()
+		if err != nil {
+			return nil, err
+		}
+		knownHosts = path
+	}
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = DefaultBuiltinTimeout
+	}
+	return &builtinSession{
+		target:     target,
+		knownHosts: knownHosts,
+		timeout:    timeout,
+		stdin:      os.Stdin,
+		stdout:     os.Stdout,
+		stderr:     os.Stderr,
+	}, nil
+}
+
+// builtinSession is an interactive shell on a target run by Builtin
+type builtinSession struct {
+	target     config.SSHTarget
+	knownHosts string
+	timeout    time.Duration
+
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+
+	// tty is the local terminal; it is detected from stdin when nil
+	tty terminal
+	// agent is the connection to the SSH agent, if one is running
+	agent agent.ExtendedAgent
+	// handshake is the connection being set up; its deadline is lifted
+	// while the user answers a prompt
+	handshake net.Conn
+}
+
+func (s *builtinSession) SetStdin(r io.Reader)  { s.stdin = r }
+func (s *builtinSession) SetStdout(w io.Writer) { s.stdout = w }
+func (s *builtinSession) SetStderr(w io.Writer) { s.stderr = w }
+
+// Run connects through the jump hosts of the target, starts a shell and
+// returns when it exits. A non-zero exit status is returned as an
+// *ssh.ExitError.
+func (s *builtinSession) Run() error {
+	if s.tty == nil {
+		s.tty = detectTerminal(s.stdin)
+	}
+	if socket := os.Getenv("SSH_AUTH_SOCK"); socket != "" {
+		if conn, err := net.Dial("unix", socket); err == nil {
+			defer conn.Close()
+			s.agent = agent.NewClient(conn)
+		}
+	}
+
+	client, closeClients, err := s.dial()
+	if err != nil {
+		return err
+	}
+	defer closeClients()
+
+	session, err := client.NewSession()
+	if err != nil {
+		return fmt.Errorf("failed to open a session: %w", err)
+	}
+	defer session.Close()
+
+	if s.target.ForwardAgent && s.agent != nil {
+		if err := agent.ForwardToAgent(client, s.agent); err == nil {
+			_ = agent.RequestAgentForwarding(session)
+		}
+	}
+
+	// Reads of stdin are cancelled when the shell exits, so they do not
+	// swallow the next key pressed in the TUI
+	stdin, err := cancelreader.NewReader(s.stdin)
+	if err != nil {
+		return fmt.Errorf("failed to read from stdin: %w", err)
+	}
+	defer stdin.Close()
+	defer stdin.Cancel()
+	session.Stdin = stdin
+	session.Stdout = s.stdout
+	session.Stderr = s.stderr
+
+	if s.tty != nil {
+		restore, err := s.startTerminal(session)
+		if err != nil {
+			return err
+		}
+		defer restore()
+	}
+
+	if err := session.Shell(); err != nil {
+		return fmt.Errorf("failed to start a shell: %w", err)
+	}
+	return session.Wait()
+}
+
+// startTerminal requests a pseudo-terminal the size of the local one, puts
+// the local terminal in raw mode and forwards window size changes
+func (s *builtinSession) startTerminal(session *ssh.Session) (func(), error) {
+	width, height, err := s.tty.Size()
+	if err != nil {
+		width, height = 80, 24
+	}
+	termType := os.Getenv("TERM")
+	if termType == "" {
+		termType = "xterm-256color"
+	}
+	modes := ssh.TerminalModes{
+		ssh.ECHO:          1,
+		ssh.TTY_OP_ISPEED: 14400,
+		ssh.TTY_OP_OSPEED: 14400,
+	}
+	if err := session.RequestPty(termType, height, width, modes); err != nil {
+		return nil, fmt.Errorf("failed to request a terminal: %w", err)
+	}
+
+	restore, err := s.tty.MakeRaw()
+	if err != nil {
+		return nil, fmt.Errorf("failed to put the terminal in raw mode: %w", err)
+	}
+	resized, stop := s.tty.Resized()
+	go func() {
+		for range resized {
+			if width, height, err := s.tty.Size(); err == nil {
+				_ = session.WindowChange(height, width)
+			}
+		}
+	}()
+	return func() {
+		stop()
+		restore()
+	}, nil
+}
+
+// hop is one host on the way to the target
+type hop struct {
+	user    string
+	address string
+}
+
+// hops returns the jump hosts of the target followed by the target itself
+func (s *builtinSession) hops() []hop {
+	var hops []hop
+	for _, spec := range strings.Split(s.target.ProxyJump, ",") {
+		if spec = strings.TrimSpace(spec); spec != "" {
+			hops = append(hops, parseHop(spec))
+		}
+	}
+	port := s.target.Port
+	if port == 0 {
+		port = 22
+	}
+	return append(hops, hop{user: s.target.User, address: net.JoinHostPort(s.target.Host, strconv.Itoa(port))})
+}
+
+// parseHop parses a [user@]host[:port] jump host
+func parseHop(spec string) hop {
+	var h hop
+	if i := strings.LastIndex(spec, "@"); i >= 0 {
+		h.user, spec = spec[:i], spec[i+1:]
+	}
+	host, port, err := net.SplitHostPort(spec)
+	if err != nil {
+		host, port = strings.Trim(spec, "[]"), "22"
+	}
+	h.address = net.JoinHostPort(host, port)
+	return h
+}
+
+// dial connects to each hop through the previous one and returns the client
+// of the target and a function closing every client
+func (s *builtinSession) dial() (*ssh.Client, func(), error) {
+	var clients []*ssh.Client
+	closeClients := func() {
+		for i := len(clients) - 1; i >= 0; i-- {
+			clients[i].Close()
+		}
+	}
+
+	var client *ssh.Client
+	for _, h := range s.hops() {
+		var (
+			conn net.Conn
+			err  error
+		)
+		if client == nil {
+			conn, err = net.DialTimeout("tcp", h.address, s.timeout)
+		} else {
+			conn, err = client.Dial("tcp", h.address)
+		}
+		if err != nil {
+			closeClients()
+			return nil, nil, fmt.Errorf("failed to connect to %s: %w", h.address, err)
+		}
+
+		// The deadline bounds the key exchange and authentication, except
+		// for the time spent waiting on the user, see prompt
+		s.handshake = conn
+		_ = conn.SetDeadline(time.Now().Add(s.timeout))
+		c, chans, reqs, err := ssh.NewClientConn(conn, h.address, s.clientConfig(h))
+		s.handshake = nil
+		if err != nil {
+			conn.Close()
+			closeClients()
+			return nil, nil, fmt.Errorf("failed to connect to %s: %w", h.address, err)
+		}
+		_ = conn.SetDeadline(time.Time{})
+
+		client = ssh.NewClient(c, chans, reqs)
+		clients = append(clients, client)
+	}
+	return client, closeClients, nil
+}
+
+// clientConfig returns the configuration used to log in to h
+func (s *builtinSession) clientConfig(h hop) *ssh.ClientConfig {
+	name := h.user
+	if name == "" {
+		if current, err := user.Current(); err == nil {
+			name = current.Username
+		}
+	}
+	return &ssh.ClientConfig{
+		User:            name,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeysCallback(s.signers)},
+		HostKeyCallback: s.verifyHostKey,
+		Timeout:         s.timeout,
+	}
+}
+
+// signers returns the keys of the SSH agent followed by the target's
+// identity file, or the default keys in ~/.ssh when it has none. They are
+// offered in a single method because the server is asked about each
+// authentication method only once.
+func (s *builtinSession) signers() ([]ssh.Signer, error) {
+	var signers []ssh.Signer
+	if s.agent != nil {
+		if agentSigners, err := s.agent.Signers(); err == nil {
+			signers = append(signers, agentSigners...)
+		}
+	}
+
+	paths := []string{s.target.IdentityFile}
+	if s.target.IdentityFile == "" {
+		paths = nil
+		if home, err := os.UserHomeDir(); err == nil {
+			for _, name := range defaultIdentityFiles {
+				paths = append(paths, filepath.Join(home, ".ssh", name))
+			}
+		}
+	}
+	for _, path := range paths {
+		signer, err := s.loadKey(path)
+		switch {
+		case err == nil:
+			signers = append(signers, signer)
+		case s.target.IdentityFile != "":
+			// Only a key the user asked for is worth reporting
+			fmt.Fprintf(s.stderr, "akumi: %v\r\n", err)
+		}
+	}
+	if len(signers) == 0 {
+		return nil, errors.New("no SSH agent or private key available")
+	}
+	return signers, nil
+}
+
+// loadKey reads a private key, asking for its passphrase on the terminal if
+// it is encrypted
+func (s *builtinSession) loadKey(path string) (ssh.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key %s: %w", path, err)
+	}
+	signer, err := ssh.ParsePrivateKey(data)
+	var missing *ssh.PassphraseMissingError
+	if !errors.As(err, &missing) {
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse private key %s: %w", path, err)
+		}
+		return signer, nil
+	}
+
+	if s.tty == nil {
+		return nil, fmt.Errorf("private key %s is encrypted and there is no terminal to ask for its passphrase", path)
+	}
+	fmt.Fprintf(s.stderr, "Enter passphrase for key '%s': ", path)
+	// Decrypting is slow on purpose, so it does not count against the
+	// handshake deadline either
+	var readErr error
+	s.prompt(func() {
+		var passphrase []byte
+		if passphrase, readErr = s.tty.ReadPassword(); readErr == nil {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(data, passphrase)
+		}
+	})
+	fmt.Fprint(s.stderr, "\r\n")
+	if readErr != nil {
+		return nil, fmt.Errorf("failed to read passphrase: %w", readErr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt private key %s: %w", path, err)
+	}
+	return signer, nil
+}
+
+// verifyHostKey checks the host key against known_hosts. Changed and revoked
+// keys are refused. Unknown keys are added after asking, or without asking
+// when StrictHostKeyChecking is accept-new or no, and refused when it is yes.
+func (s *builtinSession) verifyHostKey(address string, _ net.Addr, key ssh.PublicKey) error {
+	knownHosts, err := hostkey.Load(s.knownHosts)
+	if err != nil {
+		return err
+	}
+	fingerprint := key.Type() + " " + ssh.FingerprintSHA256(key)
+	switch knownHosts.Check(address, key) {
+	case hostkey.Known:
+		return nil
+	case hostkey.Revoked:
+		return fmt.Errorf("the host key of %s is revoked (%s)", address, fingerprint)
+	case hostkey.Changed:
+		return fmt.Errorf("the host key of %s has changed to %s; if the host was rebuilt, replace the entry in %s", address, fingerprint, s.knownHosts)
+	}
+
+	switch strings.ToLower(s.option("StrictHostKeyChecking")) {
+	case "yes":
+		return fmt.Errorf("no host key is known for %s and StrictHostKeyChecking is yes", address)
+	case "accept-new", "no", "off":
+	default:
+		if s.tty == nil {
+			return fmt.Errorf("the host key of %s is unknown and there is no terminal to confirm it (%s)", address, fingerprint)
+		}
+		fmt.Fprintf(s.stderr, "The authenticity of host '%s' can't be established.\r\n%s.\r\nAre you sure you want to continue connecting (yes/no)? ", address, fingerprint)
+		var (
+			answer string
+			err    error
+		)
+		s.prompt(func() {
+			answer, err = readLine(s.stdin)
+		})
+		if err != nil || strings.ToLower(answer) != "yes" {
+			return fmt.Errorf("host key verification failed for %s", address)
+		}
+	}
+
+	knownHosts.Add(address, key)
+	if err := knownHosts.Save(); err != nil {
+		return err
+	}
+	fmt.Fprintf(s.stderr, "Permanently added '%s' to the list of known hosts.\r\n", address)
+	return nil
+}
+
+// prompt runs ask, which waits for the user, without the handshake deadline
+// and arms the deadline again once it returns
+func (s *builtinSession) prompt(ask func()) {
+	if s.handshake == nil {
+		ask()
+		return
+	}
+	_ = s.handshake.SetDeadline(time.Time{})
+	ask()
+	_ = s.handshake.SetDeadline(time.Now().Add(s.timeout))
+}
+
+// option returns the value of an ssh option of the target, ignoring the case
+// of its name as ssh does
+func (s *builtinSession) option(name string) string {
+	for key, value := range s.target.Options {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return ""
+}
+
+// readLine reads up to the next newline one byte at a time, so nothing
+// after it is consumed
+func readLine(r io.Reader) (string, error) {
+	var (
+		line []byte
+		b    [1]byte
+	)
+	for {
+		n, err := r.Read(b[:])
+		if n > 0 {
+			if b[0] == '\n' {
+				return strings.TrimSpace(string(line)), nil
+			}
+			line = append(line, b[0])
+		}
+		if err != nil {
+			return strings.TrimSpace(string(line)), err
+		}
+	}
+}
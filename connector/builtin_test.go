@@ -0,0 +1,569 @@
+package connector
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+
+	"github.com/omegaatt36/akumi/config"
+	"github.com/omegaatt36/akumi/hostkey"
+)
+
+// newKey generates an ed25519 key
+func newKey(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	return private
+}
+
+// newSigner generates an ed25519 signer
+func newSigner(t *testing.T) ssh.Signer {
+	t.Helper()
+	signer, err := ssh.NewSignerFromKey(newKey(t))
+	if err != nil {
+		t.Fatalf("Failed to create signer: %v", err)
+	}
+	return signer
+}
+
+// testServer is an SSH server accepting one client key. Its shell prints a
+// greeting, reads input until a line reading "exit" and exits with status 3.
+type testServer struct {
+	host    string
+	port    int
+	hostKey ssh.Signer
+
+	mu      sync.Mutex
+	user    string
+	term    string
+	size    [2]int
+	resizes [][2]int
+
+	started chan struct{}
+	resized chan struct{}
+}
+
+func newTestServer(t *testing.T, authorized ssh.PublicKey) *testServer {
+	t.Helper()
+	s := &testServer{
+		hostKey: newSigner(t),
+		started: make(chan struct{}, 1),
+		resized: make(chan struct{}, 4),
+	}
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if !bytes.Equal(key.Marshal(), authorized.Marshal()) {
+				return nil, errors.New("unauthorized key")
+			}
+			s.mu.Lock()
+			s.user = conn.User()
+			s.mu.Unlock()
+			return nil, nil
+		},
+	}
+	config.AddHostKey(s.hostKey)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	s.host = "127.0.0.1"
+	s.port = ln.Addr().(*net.TCPAddr).Port
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn, config)
+		}
+	}()
+	return s
+}
+
+func (s *testServer) address() string {
+	return net.JoinHostPort(s.host, strconv.Itoa(s.port))
+}
+
+func (s *testServer) serve(conn net.Conn, config *ssh.ServerConfig) {
+	defer conn.Close()
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for newChannel := range chans {
+		switch newChannel.ChannelType() {
+		case "session":
+			channel, requests, err := newChannel.Accept()
+			if err != nil {
+				return
+			}
+			go s.session(channel, requests)
+		case "direct-tcpip":
+			go s.forward(newChannel)
+		default:
+			newChannel.Reject(ssh.UnknownChannelType, "unsupported channel type")
+		}
+	}
+}
+
+// forward connects a direct-tcpip channel to its destination, acting as a
+// jump host
+func (s *testServer) forward(newChannel ssh.NewChannel) {
+	var dest struct {
+		Host     string
+		Port     uint32
+		OrigHost string
+		OrigPort uint32
+	}
+	if err := ssh.Unmarshal(newChannel.ExtraData(), &dest); err != nil {
+		newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	conn, err := net.Dial("tcp", net.JoinHostPort(dest.Host, strconv.Itoa(int(dest.Port))))
+	if err != nil {
+		newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	channel, requests, err := newChannel.Accept()
+	if err != nil {
+		conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(requests)
+	go func() {
+		io.Copy(conn, channel)
+		conn.Close()
+	}()
+	io.Copy(channel, conn)
+	channel.Close()
+}
+
+func (s *testServer) session(channel ssh.Channel, requests <-chan *ssh.Request) {
+	for req := range requests {
+		switch req.Type {
+		case "pty-req":
+			var pty struct {
+				Term                                   string
+				Width, Height, PixelWidth, PixelHeight uint32
+				Modes                                  string
+			}
+			ok := ssh.Unmarshal(req.Payload, &pty) == nil
+			if ok {
+				s.mu.Lock()
+				s.term, s.size = pty.Term, [2]int{int(pty.Width), int(pty.Height)}
+				s.mu.Unlock()
+			}
+			req.Reply(ok, nil)
+		case "window-change":
+			var size struct{ Width, Height, PixelWidth, PixelHeight uint32 }
+			if ssh.Unmarshal(req.Payload, &size) == nil {
+				s.mu.Lock()
+				s.resizes = append(s.resizes, [2]int{int(size.Width), int(size.Height)})
+				s.mu.Unlock()
+				s.resized <- struct{}{}
+			}
+		case "shell":
+			req.Reply(true, nil)
+			go s.shell(channel)
+		default:
+			req.Reply(false, nil)
+		}
+	}
+}
+
+func (s *testServer) shell(channel ssh.Channel) {
+	defer channel.Close()
+	io.WriteString(channel, "welcome\r\n")
+	s.started <- struct{}{}
+	scanner := bufio.NewScanner(channel)
+	for scanner.Scan() && strings.TrimSpace(scanner.Text()) != "exit" {
+	}
+	channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{3}))
+}
+
+// fakeTerminal is a terminal whose size the test controls
+type fakeTerminal struct {
+	mu            sync.Mutex
+	width, height int
+	raw, restored bool
+	passphrase    string
+	// delay is how long the user takes to type the passphrase
+	delay time.Duration
+
+	resized chan struct{}
+	stop    sync.Once
+}
+
+func newFakeTerminal(width, height int) *fakeTerminal {
+	return &fakeTerminal{width: width, height: height, resized: make(chan struct{})}
+}
+
+func (f *fakeTerminal) Size() (int, int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.width, f.height, nil
+}
+
+func (f *fakeTerminal) MakeRaw() (func(), error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.raw = true
+	return func() {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.restored = true
+	}, nil
+}
+
+func (f *fakeTerminal) ReadPassword() ([]byte, error) {
+	time.Sleep(f.delay)
+	return []byte(f.passphrase), nil
+}
+
+func (f *fakeTerminal) Resized() (<-chan struct{}, func()) {
+	return f.resized, func() { f.stop.Do(func() { close(f.resized) }) }
+}
+
+func (f *fakeTerminal) resize(width, height int) {
+	f.mu.Lock()
+	f.width, f.height = width, height
+	f.mu.Unlock()
+	f.resized <- struct{}{}
+}
+
+// writeKey writes key to a private key file, encrypted if passphrase is set
+func writeKey(t *testing.T, key ed25519.PrivateKey, passphrase string) string {
+	t.Helper()
+	var (
+		block *pem.Block
+		err   error
+	)
+	if passphrase == "" {
+		block, err = ssh.MarshalPrivateKey(key, "")
+	} else {
+		block, err = ssh.MarshalPrivateKeyWithPassphrase(key, "", []byte(passphrase))
+	}
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "id_ed25519")
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+	return path
+}
+
+// isolate points HOME and SSH_AUTH_SOCK away from the user's keys and agent
+func isolate(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("SSH_AUTH_SOCK", "")
+}
+
+// newSession returns a built-in session with target and the given terminal
+func newSession(t *testing.T, target config.SSHTarget, knownHosts string, tty terminal, stdin io.Reader, stdout io.Writer) *builtinSession {
+	t.Helper()
+	session, err := Builtin{KnownHosts: knownHosts, Timeout: 5 * time.Second}.Connect(target)
+	if err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	s := session.(*builtinSession)
+	s.tty = tty
+	s.SetStdin(stdin)
+	s.SetStdout(stdout)
+	s.SetStderr(io.Discard)
+	return s
+}
+
+// wait receives from ch or fails the test after a while
+func wait(t *testing.T, ch <-chan struct{}, what string) {
+	t.Helper()
+	select {
+	case <-ch:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for %s", what)
+	}
+}
+
+// syncBuffer is a bytes.Buffer safe for concurrent use
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestBuiltinSession(t *testing.T) {
+	isolate(t)
+	key := newKey(t)
+	signer, _ := ssh.NewSignerFromKey(key)
+	server := newTestServer(t, signer.PublicKey())
+
+	knownHosts := filepath.Join(t.TempDir(), "known_hosts")
+	target := config.SSHTarget{
+		User:         "alice",
+		Host:         server.host,
+		Port:         server.port,
+		IdentityFile: writeKey(t, key, "correct horse"),
+		Options:      map[string]string{"stricthostkeychecking": "accept-new"},
+	}
+	tty := newFakeTerminal(80, 24)
+	tty.passphrase = "correct horse"
+	stdin, input := io.Pipe()
+	defer input.Close()
+	var stdout syncBuffer
+	session := newSession(t, target, knownHosts, tty, stdin, &stdout)
+
+	done := make(chan error, 1)
+	go func() { done <- session.Run() }()
+
+	wait(t, server.started, "the shell to start")
+	tty.resize(120, 40)
+	wait(t, server.resized, "the window change")
+	io.WriteString(input, "exit\n")
+
+	var err error
+	select {
+	case err = <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the session to end")
+	}
+	if code, ok := ExitCode(err); !ok || code != 3 {
+		t.Fatalf("Run() error = %v, want exit status 3", err)
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if server.user != "alice" {
+		t.Errorf("user = %q, want alice", server.user)
+	}
+	if server.term == "" || server.size != [2]int{80, 24} {
+		t.Errorf("pty = %q %v, want a terminal of 80x24", server.term, server.size)
+	}
+	if len(server.resizes) != 1 || server.resizes[0] != [2]int{120, 40} {
+		t.Errorf("window changes = %v, want [[120 40]]", server.resizes)
+	}
+	if !tty.raw || !tty.restored {
+		t.Errorf("raw = %v, restored = %v, want the terminal made raw and restored", tty.raw, tty.restored)
+	}
+	if !strings.Contains(stdout.String(), "welcome") {
+		t.Errorf("stdout = %q, want the greeting", stdout.String())
+	}
+
+	file, err := hostkey.Load(knownHosts)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if status := file.Check(server.address(), server.hostKey.PublicKey()); status != hostkey.Known {
+		t.Errorf("host key status = %v, want the key added with accept-new", status)
+	}
+}
+
+func TestBuiltinAgentAuth(t *testing.T) {
+	isolate(t)
+	key := newKey(t)
+	signer, _ := ssh.NewSignerFromKey(key)
+	server := newTestServer(t, signer.PublicKey())
+
+	keyring := agent.NewKeyring()
+	if err := keyring.Add(agent.AddedKey{PrivateKey: key}); err != nil {
+		t.Fatalf("Failed to add key to agent: %v", err)
+	}
+	socket := filepath.Join(t.TempDir(), "agent.sock")
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skipf("Unix sockets are not available: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go agent.ServeAgent(keyring, conn)
+		}
+	}()
+	t.Setenv("SSH_AUTH_SOCK", socket)
+
+	// The user confirms the unknown host key before the shell reads its input
+	knownHosts := filepath.Join(t.TempDir(), "known_hosts")
+	target := config.SSHTarget{User: "bob", Host: server.host, Port: server.port}
+	session := newSession(t, target, knownHosts, newFakeTerminal(80, 24), strings.NewReader("yes\nexit\n"), io.Discard)
+
+	if code, ok := ExitCode(session.Run()); !ok || code != 3 {
+		t.Fatalf("Run() exit status = %d, %v, want 3", code, ok)
+	}
+	file, err := hostkey.Load(knownHosts)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if status := file.Check(server.address(), server.hostKey.PublicKey()); status != hostkey.Known {
+		t.Errorf("host key status = %v, want the confirmed key added", status)
+	}
+}
+
+func TestBuiltinProxyJump(t *testing.T) {
+	isolate(t)
+	key := newKey(t)
+	signer, _ := ssh.NewSignerFromKey(key)
+	jump := newTestServer(t, signer.PublicKey())
+	server := newTestServer(t, signer.PublicKey())
+
+	knownHosts := filepath.Join(t.TempDir(), "known_hosts")
+	target := config.SSHTarget{
+		User:         "carol",
+		Host:         server.host,
+		Port:         server.port,
+		IdentityFile: writeKey(t, key, ""),
+		ProxyJump:    "ops@" + jump.address(),
+		Options:      map[string]string{"StrictHostKeyChecking": "accept-new"},
+	}
+	session := newSession(t, target, knownHosts, nil, strings.NewReader("exit\n"), io.Discard)
+
+	if code, ok := ExitCode(session.Run()); !ok || code != 3 {
+		t.Fatalf("Run() exit status = %d, %v, want 3", code, ok)
+	}
+	jump.mu.Lock()
+	server.mu.Lock()
+	defer jump.mu.Unlock()
+	defer server.mu.Unlock()
+	if jump.user != "ops" || server.user != "carol" {
+		t.Errorf("users = %q, %q, want ops on the jump host and carol on the target", jump.user, server.user)
+	}
+	if server.term != "" {
+		t.Errorf("pty = %q, want none without a terminal", server.term)
+	}
+
+	file, err := hostkey.Load(knownHosts)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	for _, s := range []*testServer{jump, server} {
+		if status := file.Check(s.address(), s.hostKey.PublicKey()); status != hostkey.Known {
+			t.Errorf("host key status of %s = %v, want known", s.address(), status)
+		}
+	}
+}
+
+func TestBuiltinHostKeyVerification(t *testing.T) {
+	isolate(t)
+	key := newKey(t)
+	signer, _ := ssh.NewSignerFromKey(key)
+	server := newTestServer(t, signer.PublicKey())
+	identity := writeKey(t, key, "")
+
+	changed := filepath.Join(t.TempDir(), "known_hosts")
+	file, _ := hostkey.Load(changed)
+	file.Add(server.address(), newSigner(t).PublicKey())
+	if err := file.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	before, _ := os.ReadFile(changed)
+
+	tests := []struct {
+		name       string
+		knownHosts string
+		options    map[string]string
+		stdin      string
+		want       string
+	}{
+		{name: "changed key", knownHosts: changed, want: "has changed"},
+		{name: "strict", options: map[string]string{"StrictHostKeyChecking": "yes"}, want: "StrictHostKeyChecking is yes"},
+		{name: "declined", stdin: "no\n", want: "host key verification failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			knownHosts := tt.knownHosts
+			if knownHosts == "" {
+				knownHosts = filepath.Join(t.TempDir(), "known_hosts")
+			}
+			target := config.SSHTarget{Host: server.host, Port: server.port, IdentityFile: identity, Options: tt.options}
+			session := newSession(t, target, knownHosts, newFakeTerminal(80, 24), strings.NewReader(tt.stdin), io.Discard)
+
+			err := session.Run()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Run() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	if after, _ := os.ReadFile(changed); !bytes.Equal(before, after) {
+		t.Errorf("known_hosts was modified after a changed key:\n%s", after)
+	}
+}
+
+func TestBuiltinSlowPrompts(t *testing.T) {
+	isolate(t)
+	key := newKey(t)
+	signer, _ := ssh.NewSignerFromKey(key)
+	server := newTestServer(t, signer.PublicKey())
+
+	// Both the host key confirmation and the passphrase take longer than
+	// the connection timeout
+	target := config.SSHTarget{User: "dave", Host: server.host, Port: server.port, IdentityFile: writeKey(t, key, "slow")}
+	tty := newFakeTerminal(80, 24)
+	tty.passphrase = "slow"
+	tty.delay = 500 * time.Millisecond
+	stdin, input := io.Pipe()
+	defer input.Close()
+	go func() {
+		time.Sleep(500 * time.Millisecond)
+		io.WriteString(input, "yes\nexit\n")
+	}()
+	session := newSession(t, target, filepath.Join(t.TempDir(), "known_hosts"), tty, stdin, io.Discard)
+	session.timeout = 200 * time.Millisecond
+
+	err := session.Run()
+	if code, ok := ExitCode(err); !ok || code != 3 {
+		t.Fatalf("Run() error = %v, want exit status 3", err)
+	}
+}
+
+func TestParseHop(t *testing.T) {
+	tests := []struct {
+		spec string
+		want hop
+	}{
+		{"bastion.example.com", hop{address: "bastion.example.com:22"}},
+		{"ops@bastion.example.com:2222", hop{user: "ops", address: "bastion.example.com:2222"}},
+		{"ops@[2001:db8::1]:2200", hop{user: "ops", address: "[2001:db8::1]:2200"}},
+		{"[2001:db8::1]", hop{address: "[2001:db8::1]:22"}},
+	}
+	for _, tt := range tests {
+		if got := parseHop(tt.spec); got != tt.want {
+			t.Errorf("parseHop(%q) = %+v, want %+v", tt.spec, got, tt.want)
+		}
+	}
+}
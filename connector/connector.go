@@ -0,0 +1,87 @@
+// Package connector starts interactive sessions with SSH targets through
+// OpenSSH, mosh, a custom command or the built-in SSH client.
+package connector
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/omegaatt36/akumi/config"
+)
+
+// Session is a connection ready to take over the terminal. It has the
+// methods of tea.ExecCommand, so the TUI can suspend itself while it runs.
+type Session interface {
+	// Run connects and blocks until the session ends.
+	Run() error
+	SetStdin(io.Reader)
+	SetStdout(io.Writer)
+	SetStderr(io.Writer)
+}
+
+// Connector prepares sessions for targets. The target passed to Connect has
+// the profile defaults applied and its jump hosts resolved.
+type Connector interface {
+	Connect(target config.SSHTarget) (Session, error)
+}
+
+// Commander is implemented by connectors that run an external program.
+type Commander interface {
+	// Command returns the command line that connects to target.
+	Command(target config.SSHTarget) ([]string, error)
+}
+
+// New returns the connector configured for target.
+func New(settings config.ConnectionSettings, target config.SSHTarget) (Connector, error) {
+	switch name := settings.ConnectorFor(target); name {
+	case config.ConnectorOpenSSH:
+		return OpenSSH{Binary: settings.SSH}, nil
+	case config.ConnectorMosh:
+		return Mosh{Binary: settings.Mosh, SSH: settings.SSH}, nil
+	case config.ConnectorBuiltin:
+		return Builtin{}, nil
+	default:
+		line, ok := settings.Templates[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown connector %q", name)
+		}
+		return Template{Name: name, Line: line}, nil
+	}
+}
+
+// execSession runs an external program as a session
+type execSession struct {
+	cmd *exec.Cmd
+}
+
+// commandSession returns a session running the command line of c for target
+func commandSession(c Commander, target config.SSHTarget) (Session, error) {
+	argv, err := c.Command(target)
+	if err != nil {
+		return nil, err
+	}
+	return &execSession{cmd: exec.Command(argv[0], argv[1:]...)}, nil
+}
+
+func (s *execSession) Run() error            { return s.cmd.Run() }
+func (s *execSession) SetStdin(r io.Reader)  { s.cmd.Stdin = r }
+func (s *execSession) SetStdout(w io.Writer) { s.cmd.Stdout = w }
+func (s *execSession) SetStderr(w io.Writer) { s.cmd.Stderr = w }
+
+// ExitCode returns the exit status of the remote command or program when err
+// reports one.
+func ExitCode(err error) (int, bool) {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), true
+	}
+	var sshErr *ssh.ExitError
+	if errors.As(err, &sshErr) {
+		return sshErr.ExitStatus(), true
+	}
+	return 0, false
+}
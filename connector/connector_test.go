@@ -0,0 +1,69 @@
+package connector
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/omegaatt36/akumi/config"
+)
+
+func TestCommand(t *testing.T) {
+	settings := config.ConnectionSettings{
+		SSH: "/opt/ssh/bin/ssh",
+		Templates: map[string]string{
+			"tsh":  "tsh ssh --proxy='teleport.example.com:443' {{.User}}@{{.Host}}:{{.Port}}",
+			"wrap": `wrapper --label "{{.Nickname}} ({{.Group}})" -- ssh {{.SSHArgs}}`,
+		},
+	}
+	target := config.SSHTarget{
+		Nickname: "db",
+		User:     "root",
+		Host:     "db.example.com",
+		Port:     2222,
+		Group:    "prod",
+		Options:  map[string]string{"SetEnv": "A=b c"},
+	}
+
+	tests := []struct {
+		connector string
+		want      []string
+	}{
+		{"", []string{"/opt/ssh/bin/ssh", "-p", "2222", "-o", "SetEnv=A=b c", "root@db.example.com"}},
+		{"mosh", []string{"mosh", "--ssh=/opt/ssh/bin/ssh -p 2222 -o 'SetEnv=A=b c'", "root@db.example.com"}},
+		{"tsh", []string{"tsh", "ssh", "--proxy=teleport.example.com:443", "root@db.example.com:2222"}},
+		{"wrap", []string{"wrapper", "--label", "db (prod)", "--", "ssh", "-p", "2222", "-o", "SetEnv=A=b c", "root@db.example.com"}},
+	}
+	for _, tt := range tests {
+		target.Connector = tt.connector
+		c, err := New(settings, target)
+		if err != nil {
+			t.Fatalf("New(%q): %v", tt.connector, err)
+		}
+		got, err := c.(Commander).Command(target)
+		if err != nil {
+			t.Fatalf("Command(%q): %v", tt.connector, err)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("Connector %q: expected %q, got %q", tt.connector, tt.want, got)
+		}
+	}
+
+	target.Connector = "missing"
+	if _, err := New(settings, target); err == nil {
+		t.Error("Expected an unknown connector to be rejected")
+	}
+}
+
+func TestSplitArgs(t *testing.T) {
+	got, err := splitArgs(`a 'b c' "d \"e\"" f\ g ''`)
+	if err != nil {
+		t.Fatalf("Failed to split: %v", err)
+	}
+	if want := []string{"a", "b c", `d "e"`, "f g", ""}; !slices.Equal(got, want) {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+
+	if _, err := splitArgs(`echo "unterminated`); err == nil {
+		t.Error("Expected an unterminated quote to be rejected")
+	}
+}
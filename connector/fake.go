@@ -0,0 +1,42 @@
+package connector
+
+import (
+	"io"
+	"sync"
+
+	"github.com/omegaatt36/akumi/config"
+)
+
+// Fake records the targets it is asked to connect to instead of connecting.
+// It is meant for tests.
+type Fake struct {
+	// Err is returned by Run of every session.
+	Err error
+
+	mu      sync.Mutex
+	targets []config.SSHTarget
+}
+
+// Connect records target and returns a session that does nothing.
+func (f *Fake) Connect(target config.SSHTarget) (Session, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.targets = append(f.targets, target)
+	return fakeSession{err: f.Err}, nil
+}
+
+// Targets returns the targets passed to Connect, oldest first.
+func (f *Fake) Targets() []config.SSHTarget {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]config.SSHTarget(nil), f.targets...)
+}
+
+type fakeSession struct {
+	err error
+}
+
+func (s fakeSession) Run() error          { return s.err }
+func (s fakeSession) SetStdin(io.Reader)  {}
+func (s fakeSession) SetStdout(io.Writer) {}
+func (s fakeSession) SetStderr(io.Writer) {}
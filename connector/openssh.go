@@ -0,0 +1,62 @@
+package connector
+
+import (
+	"strings"
+
+	"github.com/omegaatt36/akumi/config"
+)
+
+// OpenSSH connects with the OpenSSH client.
+type OpenSSH struct {
+	// Binary is the ssh executable. Defaults to "ssh".
+	Binary string
+}
+
+// Command returns the ssh command line for target.
+func (c OpenSSH) Command(target config.SSHTarget) ([]string, error) {
+	return append([]string{binary(c.Binary, "ssh")}, target.GetSSHCommand()...), nil
+}
+
+// Connect runs ssh in the terminal.
+func (c OpenSSH) Connect(target config.SSHTarget) (Session, error) {
+	return commandSession(c, target)
+}
+
+// Mosh connects with mosh, which starts the session over ssh and then keeps
+// it alive across roaming and sleep.
+type Mosh struct {
+	// Binary is the mosh executable. Defaults to "mosh".
+	Binary string
+	// SSH is the ssh executable mosh starts the server with. Defaults to "ssh".
+	SSH string
+}
+
+// Command returns the mosh command line for target. The port, identity file,
+// jump hosts and options are passed to ssh through --ssh.
+func (c Mosh) Command(target config.SSHTarget) ([]string, error) {
+	args := target.GetSSHCommand()
+	options, destination := args[:len(args)-1], args[len(args)-1]
+
+	argv := []string{binary(c.Binary, "mosh")}
+	if len(options) > 0 || c.SSH != "" {
+		ssh := []string{config.ShellQuote(binary(c.SSH, "ssh"))}
+		for _, option := range options {
+			ssh = append(ssh, config.ShellQuote(option))
+		}
+		argv = append(argv, "--ssh="+strings.Join(ssh, " "))
+	}
+	return append(argv, destination), nil
+}
+
+// Connect runs mosh in the terminal.
+func (c Mosh) Connect(target config.SSHTarget) (Session, error) {
+	return commandSession(c, target)
+}
+
+// binary returns name, or fallback when it is empty
+func binary(name, fallback string) string {
+	if name == "" {
+		return fallback
+	}
+	return name
+}
@@ -0,0 +1,39 @@
+//go:build !unix
+
+package connector
+
+import "time"
+
+// resizePollInterval is how often the terminal size is checked on systems
+// without SIGWINCH
+const resizePollInterval = 250 * time.Millisecond
+
+// watchResize polls the size of t and signals when it changes
+func watchResize(t terminal) (<-chan struct{}, func()) {
+	resized := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(resized)
+		width, height, _ := t.Size()
+		ticker := time.NewTicker(resizePollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				w, h, err := t.Size()
+				if err != nil || (w == width && h == height) {
+					continue
+				}
+				width, height = w, h
+				select {
+				case resized <- struct{}{}:
+				case <-done:
+					return
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return resized, func() { close(done) }
+}
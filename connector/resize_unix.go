@@ -0,0 +1,36 @@
+//go:build unix
+
+package connector
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// watchResize signals whenever the process receives SIGWINCH
+func watchResize(terminal) (<-chan struct{}, func()) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGWINCH)
+	resized := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(resized)
+		for {
+			select {
+			case <-signals:
+				select {
+				case resized <- struct{}{}:
+				case <-done:
+					return
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return resized, func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
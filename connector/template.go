@@ -0,0 +1,124 @@
+package connector
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/omegaatt36/akumi/config"
+)
+
+// Template connects by running a command line rendered from a Go template,
+// such as "tsh ssh --proxy=teleport.example.com {{.Destination}}". The
+// rendered line is split into arguments like a shell would, honouring quotes
+// and backslashes, but it is not run by a shell.
+type Template struct {
+	// Name is the connector name used in the config.
+	Name string
+	// Line is the command line template.
+	Line string
+}
+
+// templateData is what command line templates are rendered with: the
+// target's fields, plus Port defaulted to 22 and SSHArgs
+type templateData struct {
+	config.SSHTarget
+	// Port is the target's port, 22 when it is not set.
+	Port int
+	// SSHArgs are the arguments ssh would be called with, quoted for the
+	// command line, e.g. "-p 2222 -J bastion root@db.example.com".
+	SSHArgs string
+}
+
+// Command renders the command line for target.
+func (c Template) Command(target config.SSHTarget) ([]string, error) {
+	tmpl, err := config.ParseConnectorTemplate(c.Line)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse connector %s: %w", c.Name, err)
+	}
+
+	data := templateData{SSHTarget: target, Port: target.Port}
+	if expanded, err := target.Expand(); err == nil {
+		data.SSHTarget = expanded
+	}
+	if data.Port == 0 {
+		data.Port = 22
+	}
+	var quoted []string
+	for _, arg := range target.GetSSHCommand() {
+		quoted = append(quoted, config.ShellQuote(arg))
+	}
+	data.SSHArgs = strings.Join(quoted, " ")
+
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return nil, fmt.Errorf("failed to render connector %s: %w", c.Name, err)
+	}
+	argv, err := splitArgs(b.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse command line of connector %s: %w", c.Name, err)
+	}
+	if len(argv) == 0 {
+		return nil, fmt.Errorf("connector %s rendered an empty command line", c.Name)
+	}
+	return argv, nil
+}
+
+// Connect runs the rendered command line in the terminal.
+func (c Template) Connect(target config.SSHTarget) (Session, error) {
+	return commandSession(c, target)
+}
+
+// splitArgs splits a command line into words. Single quotes keep their
+// contents literally, double quotes allow backslash escapes and a backslash
+// outside quotes escapes the next character.
+func splitArgs(line string) ([]string, error) {
+	var (
+		args   []string
+		word   strings.Builder
+		inWord bool
+		quote  rune
+		escape bool
+	)
+	for _, r := range line {
+		switch {
+		case escape:
+			word.WriteRune(r)
+			escape = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\\' && quote == '"':
+			escape = true
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\\':
+			escape, inWord = true, true
+		case r == '\'' || r == '"':
+			quote, inWord = r, true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				args = append(args, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 || escape {
+		return nil, errors.New("unterminated quote or escape")
+	}
+	if inWord {
+		args = append(args, word.String())
+	}
+	return args, nil
+}
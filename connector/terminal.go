@@ -0,0 +1,57 @@
+package connector
+
+import (
+	"io"
+	"os"
+
+	"golang.org/x/term"
+)
+
+// terminal is the local terminal a built-in session runs in
+type terminal interface {
+	// Size returns the width and height of the terminal.
+	Size() (width, height int, err error)
+	// MakeRaw puts the terminal in raw mode and returns a function restoring
+	// the previous mode.
+	MakeRaw() (restore func(), err error)
+	// ReadPassword reads a line without echoing it.
+	ReadPassword() ([]byte, error)
+	// Resized signals on the channel whenever the terminal changes size,
+	// until stop is called.
+	Resized() (resized <-chan struct{}, stop func())
+}
+
+// osTerminal is a terminal device
+type osTerminal struct {
+	file *os.File
+}
+
+// detectTerminal returns the terminal stdin reads from, or nil if it is not a
+// terminal
+func detectTerminal(stdin io.Reader) terminal {
+	file, ok := stdin.(*os.File)
+	if !ok || !term.IsTerminal(int(file.Fd())) {
+		return nil
+	}
+	return osTerminal{file: file}
+}
+
+func (t osTerminal) Size() (int, int, error) {
+	return term.GetSize(int(t.file.Fd()))
+}
+
+func (t osTerminal) MakeRaw() (func(), error) {
+	state, err := term.MakeRaw(int(t.file.Fd()))
+	if err != nil {
+		return nil, err
+	}
+	return func() { _ = term.Restore(int(t.file.Fd()), state) }, nil
+}
+
+func (t osTerminal) ReadPassword() ([]byte, error) {
+	return term.ReadPassword(int(t.file.Fd()))
+}
+
+func (t osTerminal) Resized() (<-chan struct{}, func()) {
+	return watchResize(t)
+}
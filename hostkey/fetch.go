@@ -0,0 +1,82 @@
+package hostkey
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// hostKeyAlgorithms lists the host key algorithms offered to the server, most
+// preferred first.
+var hostKeyAlgorithms = []string{
+	ssh.KeyAlgoED25519,
+	ssh.KeyAlgoECDSA256,
+	ssh.KeyAlgoECDSA384,
+	ssh.KeyAlgoECDSA521,
+	ssh.KeyAlgoRSASHA512,
+	ssh.KeyAlgoRSASHA256,
+	ssh.KeyAlgoRSA,
+}
+
+// errKeyReceived aborts the handshake once the host key is known
+var errKeyReceived = errors.New("host key received")
+
+// Fetch connects to address and returns the host key the server presents,
+// without authenticating. Key types listed in prefer, such as the types
+// already recorded in known_hosts, are requested first so the returned key
+// can be compared with them.
+func Fetch(ctx context.Context, address string, timeout time.Duration, prefer []string) (ssh.PublicKey, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", address, err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	var hostKey ssh.PublicKey
+	config := &ssh.ClientConfig{
+		HostKeyCallback: func(_ string, _ net.Addr, key ssh.PublicKey) error {
+			hostKey = key
+			return errKeyReceived
+		},
+		HostKeyAlgorithms: preferAlgorithms(prefer),
+	}
+	_, _, _, err = ssh.NewClientConn(conn, address, config)
+	if hostKey != nil {
+		return hostKey, nil
+	}
+	return nil, fmt.Errorf("failed to read host key of %s: %w", address, err)
+}
+
+// preferAlgorithms moves the algorithms for the given key types to the front
+func preferAlgorithms(keyTypes []string) []string {
+	var preferred []string
+	for _, keyType := range keyTypes {
+		algorithms := []string{keyType}
+		if keyType == ssh.KeyAlgoRSA {
+			algorithms = []string{ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA}
+		}
+		for _, algorithm := range algorithms {
+			if slices.Contains(hostKeyAlgorithms, algorithm) && !slices.Contains(preferred, algorithm) {
+				preferred = append(preferred, algorithm)
+			}
+		}
+	}
+	for _, algorithm := range hostKeyAlgorithms {
+		if !slices.Contains(preferred, algorithm) {
+			preferred = append(preferred, algorithm)
+		}
+	}
+	return preferred
+}
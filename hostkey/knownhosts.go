@@ -0,0 +1,345 @@
+// Package hostkey fetches the host keys of SSH servers and inspects and edits
+// the entries of OpenSSH known_hosts files.
+package hostkey
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// Entry is a host key line of a known_hosts file.
+type Entry struct {
+	// Line is the 1-based line number of the entry.
+	Line int
+	// Marker is "cert-authority", "revoked" or empty.
+	Marker string
+	// Patterns are the host patterns of the entry, hashed or plain.
+	Patterns []string
+	// Key is the public key of the entry.
+	Key ssh.PublicKey
+}
+
+// Hashed reports whether the entry stores its host name hashed.
+func (e Entry) Hashed() bool {
+	return len(e.Patterns) == 1 && strings.HasPrefix(e.Patterns[0], "|1|")
+}
+
+// Fingerprint returns the SHA256 fingerprint of the entry's key.
+func (e Entry) Fingerprint() string {
+	return ssh.FingerprintSHA256(e.Key)
+}
+
+// Status is the result of checking a host key against a known_hosts file.
+type Status int
+
+const (
+	// Unknown means the file has no key for the host.
+	Unknown Status = iota
+	// Known means the key matches an entry for the host.
+	Known
+	// Changed means the file has keys for the host but none match.
+	Changed
+	// Revoked means the key is marked as revoked.
+	Revoked
+)
+
+func (s Status) String() string {
+	switch s {
+	case Known:
+		return "known"
+	case Changed:
+		return "changed"
+	case Revoked:
+		return "revoked"
+	default:
+		return "unknown"
+	}
+}
+
+// File is a known_hosts file held in memory. Lines akumi does not change are
+// written back as they were read.
+type File struct {
+	// Path is the file the entries were read from and are saved to.
+	Path string
+
+	lines    []string
+	original []byte
+}
+
+// DefaultPath returns ~/.ssh/known_hosts.
+func DefaultPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user home directory: %w", err)
+	}
+	return filepath.Join(home, ".ssh", "known_hosts"), nil
+}
+
+// Load reads the known_hosts file at path. A missing file yields an empty
+// file that is created on save.
+func Load(path string) (*File, error) {
+	f := &File{Path: path}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return f, nil
+		}
+		return nil, fmt.Errorf("failed to read known hosts %s: %w", path, err)
+	}
+	f.original = data
+	if text := strings.TrimSuffix(string(data), "\n"); text != "" {
+		f.lines = strings.Split(text, "\n")
+	}
+	return f, nil
+}
+
+// Entries returns every host key entry of the file.
+func (f *File) Entries() []Entry {
+	var entries []Entry
+	for i, line := range f.lines {
+		if entry, ok := parseLine(line); ok {
+			entry.Line = i + 1
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// Lookup returns the entries whose host patterns match address, given as
+// host or host:port.
+func (f *File) Lookup(address string) []Entry {
+	host := knownhosts.Normalize(address)
+	var entries []Entry
+	for _, entry := range f.Entries() {
+		if matchEntry(entry.Patterns, host) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// Check compares key with the entries for address. Certificate authority
+// entries are ignored.
+func (f *File) Check(address string, key ssh.PublicKey) Status {
+	status := Unknown
+	for _, entry := range f.Lookup(address) {
+		switch {
+		case entry.Marker == "revoked":
+			if keyEqual(entry.Key, key) {
+				return Revoked
+			}
+		case entry.Marker != "":
+		case keyEqual(entry.Key, key):
+			status = Known
+		case status == Unknown:
+			status = Changed
+		}
+	}
+	return status
+}
+
+// Add appends an entry for address with key. The host name is hashed if the
+// file already contains hashed entries, like HashKnownHosts would.
+func (f *File) Add(address string, key ssh.PublicKey) {
+	f.add(address, key, f.hashesHosts())
+}
+
+func (f *File) add(address string, key ssh.PublicKey, hash bool) {
+	host := knownhosts.Normalize(address)
+	if hash {
+		host = knownhosts.HashHostname(host)
+	}
+	f.lines = append(f.lines, host+" "+strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key))))
+}
+
+// Remove deletes the plain entries written for address, as ssh-keygen -R
+// does, and returns how many were removed. Entries that only match through a
+// wildcard, certificate authorities and revocations are kept.
+func (f *File) Remove(address string) int {
+	host := knownhosts.Normalize(address)
+	removed := 0
+	lines := f.lines[:0]
+	for _, line := range f.lines {
+		if entry, ok := parseLine(line); ok && entry.Marker == "" && namesHost(entry.Patterns, host) {
+			removed++
+			continue
+		}
+		lines = append(lines, line)
+	}
+	f.lines = lines
+	return removed
+}
+
+// Replace removes the entries for address and adds key in their place.
+func (f *File) Replace(address string, key ssh.PublicKey) {
+	hash := f.hashesHosts()
+	f.Remove(address)
+	f.add(address, key, hash)
+}
+
+// Save writes the file, keeping the previous version as <path>.old like
+// ssh-keygen does. If Path is a symlink, the file it points to is replaced
+// and the link is kept.
+func (f *File) Save() error {
+	path := f.Path
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", f.Path, err)
+	}
+	if f.original != nil {
+		if err := os.WriteFile(path+".old", f.original, 0600); err != nil {
+			return fmt.Errorf("failed to back up known hosts %s: %w", f.Path, err)
+		}
+	}
+
+	var data []byte
+	if len(f.lines) > 0 {
+		data = []byte(strings.Join(f.lines, "\n") + "\n")
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to write known hosts %s: %w", f.Path, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write known hosts %s: %w", f.Path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write known hosts %s: %w", f.Path, err)
+	}
+	// The temporary file is private; an existing file keeps its own mode
+	if info, err := os.Stat(path); err == nil {
+		if err := os.Chmod(tmp.Name(), info.Mode().Perm()); err != nil {
+			return fmt.Errorf("failed to write known hosts %s: %w", f.Path, err)
+		}
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write known hosts %s: %w", f.Path, err)
+	}
+	f.original = data
+	return nil
+}
+
+// hashesHosts reports whether the file contains hashed entries
+func (f *File) hashesHosts() bool {
+	for _, entry := range f.Entries() {
+		if entry.Hashed() {
+			return true
+		}
+	}
+	return false
+}
+
+// parseLine parses a known_hosts line, skipping comments, blank lines and
+// lines with keys that cannot be parsed
+func parseLine(line string) (Entry, bool) {
+	marker, hosts, key, _, _, err := ssh.ParseKnownHosts([]byte(line))
+	if err != nil {
+		return Entry{}, false
+	}
+	return Entry{Marker: marker, Patterns: hosts, Key: key}, true
+}
+
+// matchEntry reports whether host matches one of the patterns and none of the
+// negated ones
+func matchEntry(patterns []string, host string) bool {
+	matched := false
+	for _, pattern := range patterns {
+		if negated, ok := strings.CutPrefix(pattern, "!"); ok {
+			if matchPattern(negated, host) {
+				return false
+			}
+			continue
+		}
+		if matchPattern(pattern, host) {
+			matched = true
+		}
+	}
+	return matched
+}
+
+// namesHost reports whether host is listed literally or hashed in patterns
+func namesHost(patterns []string, host string) bool {
+	for _, pattern := range patterns {
+		if strings.HasPrefix(pattern, "|1|") {
+			if matchHashed(pattern, host) {
+				return true
+			}
+			continue
+		}
+		if strings.EqualFold(pattern, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPattern matches host against a plain, wildcard or hashed pattern
+func matchPattern(pattern, host string) bool {
+	if strings.HasPrefix(pattern, "|1|") {
+		return matchHashed(pattern, host)
+	}
+	return wildcardMatch(strings.ToLower(pattern), strings.ToLower(host))
+}
+
+// matchHashed checks host against a "|1|salt|hash" pattern written with
+// HashKnownHosts
+func matchHashed(pattern, host string) bool {
+	parts := strings.Split(pattern, "|")
+	if len(parts) != 4 || parts[1] != "1" {
+		return false
+	}
+	salt, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	want, err := base64.StdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha1.New, salt)
+	mac.Write([]byte(host))
+	return hmac.Equal(mac.Sum(nil), want)
+}
+
+// wildcardMatch matches s against a pattern where * matches any run of
+// characters and ? any single character
+func wildcardMatch(pattern, s string) bool {
+	for pattern != "" {
+		switch pattern[0] {
+		case '*':
+			for i := len(s); i >= 0; i-- {
+				if wildcardMatch(pattern[1:], s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if s == "" {
+				return false
+			}
+		default:
+			if s == "" || s[0] != pattern[0] {
+				return false
+			}
+		}
+		pattern, s = pattern[1:], s[1:]
+	}
+	return s == ""
+}
+
+func keyEqual(a, b ssh.PublicKey) bool {
+	return bytes.Equal(a.Marshal(), b.Marshal())
+}
@@ -0,0 +1,172 @@
+package hostkey
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// newSigner generates an ed25519 host key
+func newSigner(t *testing.T) ssh.Signer {
+	t.Helper()
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	signer, err := ssh.NewSignerFromKey(private)
+	if err != nil {
+		t.Fatalf("Failed to create signer: %v", err)
+	}
+	return signer
+}
+
+func TestKnownHosts(t *testing.T) {
+	oldKey, newKey, otherKey := newSigner(t).PublicKey(), newSigner(t).PublicKey(), newSigner(t).PublicKey()
+	line := func(hosts string, key ssh.PublicKey) string {
+		return hosts + " " + strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key)))
+	}
+
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	content := strings.Join([]string{
+		"# managed by hand",
+		line("web.example.com,10.0.0.1", oldKey),
+		line(knownhosts.HashHostname("[db.example.com]:2222"), oldKey),
+		line("*.internal", otherKey),
+		line("@revoked bad.example.com", otherKey),
+	}, "\n") + "\n"
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := Load(path)
+	if err != nil {
+		t.Fatalf("Failed to load known hosts: %v", err)
+	}
+
+	tests := []struct {
+		address string
+		key     ssh.PublicKey
+		want    Status
+	}{
+		{"web.example.com:22", oldKey, Known},
+		{"web.example.com:22", newKey, Changed},
+		{"db.example.com:2222", oldKey, Known},
+		{"db.example.com:22", oldKey, Unknown},
+		{"git.internal:22", otherKey, Known},
+		{"bad.example.com:22", otherKey, Revoked},
+		{"new.example.com:22", newKey, Unknown},
+	}
+	for _, tt := range tests {
+		if got := f.Check(tt.address, tt.key); got != tt.want {
+			t.Errorf("Check(%s) = %s, want %s", tt.address, got, tt.want)
+		}
+	}
+
+	if entries := f.Lookup("db.example.com:2222"); len(entries) != 1 || entries[0].Line != 3 || !entries[0].Hashed() {
+		t.Errorf("Expected the hashed entry on line 3, got %+v", entries)
+	}
+
+	// Replacing a hashed entry keeps the file hashed
+	f.Replace("db.example.com:2222", newKey)
+	if f.Check("db.example.com:2222", newKey) != Known {
+		t.Error("Expected the replaced key to be known")
+	}
+	if entries := f.Lookup("db.example.com:2222"); len(entries) != 1 || !entries[0].Hashed() {
+		t.Errorf("Expected one hashed entry after replacing, got %+v", entries)
+	}
+
+	// Wildcard entries are not removed for a single host
+	if removed := f.Remove("git.internal"); removed != 0 {
+		t.Errorf("Expected the wildcard entry to be kept, removed %d", removed)
+	}
+	if removed := f.Remove("web.example.com"); removed != 1 {
+		t.Errorf("Expected one entry to be removed, got %d", removed)
+	}
+
+	if err := f.Save(); err != nil {
+		t.Fatalf("Failed to save known hosts: %v", err)
+	}
+	saved, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(saved), "# managed by hand\n") || strings.Contains(string(saved), "web.example.com") {
+		t.Errorf("Unexpected known hosts after saving:\n%s", saved)
+	}
+	if old, err := os.ReadFile(path + ".old"); err != nil || string(old) != content {
+		t.Errorf("Expected the previous file to be kept as .old, got %q (%v)", old, err)
+	}
+}
+
+func TestSaveKeepsSymlink(t *testing.T) {
+	dotfiles := filepath.Join(t.TempDir(), "known_hosts")
+	if err := os.WriteFile(dotfiles, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(dotfiles, 0644); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	if err := os.Symlink(dotfiles, path); err != nil {
+		t.Skipf("Symlinks are not supported: %v", err)
+	}
+
+	f, err := Load(path)
+	if err != nil {
+		t.Fatalf("Failed to load known hosts: %v", err)
+	}
+	f.Add("web.example.com:22", newSigner(t).PublicKey())
+	if err := f.Save(); err != nil {
+		t.Fatalf("Failed to save known hosts: %v", err)
+	}
+	if info, err := os.Lstat(path); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Fatalf("Expected known_hosts to stay a symlink, got %v (%v)", info, err)
+	}
+	if data, _ := os.ReadFile(dotfiles); !strings.Contains(string(data), "web.example.com") {
+		t.Errorf("Expected the linked file to be written, got:\n%s", data)
+	}
+	if info, err := os.Stat(dotfiles); err != nil || info.Mode().Perm() != 0644 {
+		t.Errorf("Expected known_hosts to keep mode 0644, got %v (%v)", info, err)
+	}
+}
+
+func TestFetch(t *testing.T) {
+	signer := newSigner(t)
+	config := &ssh.ServerConfig{NoClientAuth: true}
+	config.AddHostKey(signer)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				ssh.NewServerConn(conn, config)
+			}()
+		}
+	}()
+
+	key, err := Fetch(context.Background(), ln.Addr().String(), 2*time.Second, []string{ssh.KeyAlgoRSA})
+	if err != nil {
+		t.Fatalf("Failed to fetch host key: %v", err)
+	}
+	if ssh.FingerprintSHA256(key) != ssh.FingerprintSHA256(signer.PublicKey()) {
+		t.Errorf("Expected the server's host key, got %s", ssh.FingerprintSHA256(key))
+	}
+}
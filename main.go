@@ -1,21 +1,77 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 
+	"github.com/omegaatt36/akumi/cli"
+	"github.com/omegaatt36/akumi/config"
 	"github.com/omegaatt36/akumi/tui"
 )
 
 // Version contains the application version
 const Version = "1.0.0"
 
+// tagFlags collects repeated --tag selectors
+type tagFlags []string
+
+func (t *tagFlags) String() string {
+	return strings.Join(*t, ",")
+}
+
+func (t *tagFlags) Set(value string) error {
+	*t = append(*t, strings.Split(value, ",")...)
+	return nil
+}
+
 func main() {
+	var (
+		tags       tagFlags
+		configPath string
+		profile    string
+	)
+	flag.Var(&tags, "tag", "Only show targets matching the tag selector (repeatable, e.g. --tag env:prod)")
+	flag.StringVar(&configPath, "config", "", "Use this config file instead of the default location (overrides $"+config.ConfigPathEnv+")")
+	flag.StringVar(&profile, "profile", config.DefaultProfile, "Use the named profile, created on first use")
+	flag.Usage = func() {
+		cli.PrintUsage(flag.CommandLine.Output())
+		fmt.Fprintln(flag.CommandLine.Output(), "\nFlags:")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if configPath != "" {
+		if _, err := config.SetConfigPath(configPath); err != nil {
+			fmt.Fprintf(os.Stderr, "akumi: %v\n", err)
+			os.Exit(2)
+		}
+	}
+
+	if profile != config.DefaultProfile {
+		if _, err := config.SetProfile(profile); err != nil {
+			fmt.Fprintf(os.Stderr, "akumi: %v\n", err)
+			os.Exit(2)
+		}
+	}
+
+	// Run non-interactive subcommands without starting the TUI
+	if flag.NArg() > 0 && cli.IsCommand(flag.Arg(0)) {
+		os.Exit(cli.Run(flag.Args(), os.Stdout, os.Stderr))
+	}
+
+	if flag.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "akumi: unknown command %q\n\n", flag.Arg(0))
+		flag.Usage()
+		os.Exit(2)
+	}
+
 	// Setup log file
 	logPath := filepath.Join(os.TempDir(), "akumi.log")
 	f, err := tea.LogToFile(logPath, "debug")
@@ -30,6 +86,15 @@ func main() {
 
 	// Create and start program
 	initialModel := tui.InitialModel()
+	if len(tags) > 0 {
+		selectors := make([]string, 0, len(tags))
+		for _, tag := range tags {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				selectors = append(selectors, "#"+tag)
+			}
+		}
+		initialModel = initialModel.WithFilter(strings.Join(selectors, " "))
+	}
 	p := tea.NewProgram(
 		initialModel,
 		tea.WithAltScreen(),       // Use alternate screen
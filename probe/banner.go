@@ -0,0 +1,98 @@
+package probe
+
+import (
+	"strings"
+	"unicode"
+)
+
+// ServerVersion is a parsed SSH identification string.
+type ServerVersion struct {
+	// Protocol is the SSH protocol version, normally "2.0".
+	Protocol string
+	// Software is the server software and version, e.g. "OpenSSH_8.9p1".
+	Software string
+	// Comments is the free-form text after the software version, e.g.
+	// "Ubuntu-3ubuntu0.6".
+	Comments string
+}
+
+// ParseBanner splits an identification string of the form
+// "SSH-protoversion-softwareversion comments". It returns false if banner is
+// not an SSH identification string.
+func ParseBanner(banner string) (ServerVersion, bool) {
+	rest, ok := strings.CutPrefix(banner, "SSH-")
+	if !ok {
+		return ServerVersion{}, false
+	}
+	protocol, rest, ok := strings.Cut(rest, "-")
+	if !ok {
+		return ServerVersion{}, false
+	}
+	software, comments, _ := strings.Cut(rest, " ")
+	return ServerVersion{Protocol: protocol, Software: software, Comments: comments}, true
+}
+
+// Version returns the version number of the server software, e.g. "8.9p1"
+// for "OpenSSH_8.9p1", or the whole software string if it has no separator.
+func (v ServerVersion) Version() string {
+	if i := strings.IndexAny(v.Software, "_-"); i >= 0 {
+		return v.Software[i+1:]
+	}
+	return v.Software
+}
+
+// Name returns the name of the server software without its version, e.g.
+// "OpenSSH" for "OpenSSH_8.9p1".
+func (v ServerVersion) Name() string {
+	if i := strings.IndexAny(v.Software, "_-"); i >= 0 {
+		return v.Software[:i]
+	}
+	return ""
+}
+
+// CompareVersions compares two version strings segment by segment, treating
+// runs of digits as numbers so that "9.0" sorts after "8.10p1". It returns -1,
+// 0 or 1.
+func CompareVersions(a, b string) int {
+	for a != "" && b != "" {
+		var sa, sb string
+		sa, a = nextSegment(a)
+		sb, b = nextSegment(b)
+		if c := compareSegments(sa, sb); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case a == b:
+		return 0
+	case a == "":
+		return -1
+	default:
+		return 1
+	}
+}
+
+// nextSegment splits off a leading run of digits or of other characters
+func nextSegment(s string) (string, string) {
+	digit := unicode.IsDigit(rune(s[0]))
+	i := 1
+	for i < len(s) && unicode.IsDigit(rune(s[i])) == digit {
+		i++
+	}
+	return s[:i], s[i:]
+}
+
+// compareSegments compares numeric segments by value and others as text
+func compareSegments(a, b string) int {
+	aNum, bNum := unicode.IsDigit(rune(a[0])), unicode.IsDigit(rune(b[0]))
+	if aNum && bNum {
+		a, b = strings.TrimLeft(a, "0"), strings.TrimLeft(b, "0")
+		if len(a) != len(b) {
+			if len(a) < len(b) {
+				return -1
+			}
+			return 1
+		}
+	}
+	return strings.Compare(a, b)
+}
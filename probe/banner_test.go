@@ -0,0 +1,63 @@
+package probe
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseBanner(t *testing.T) {
+	v, ok := ParseBanner("SSH-2.0-OpenSSH_8.9p1 Ubuntu-3ubuntu0.6")
+	if !ok {
+		t.Fatal("Expected the banner to parse")
+	}
+	if v.Protocol != "2.0" || v.Software != "OpenSSH_8.9p1" || v.Comments != "Ubuntu-3ubuntu0.6" || v.Version() != "8.9p1" || v.Name() != "OpenSSH" {
+		t.Errorf("Unexpected server version %+v (%s)", v, v.Version())
+	}
+
+	if _, ok := ParseBanner("HTTP/1.1 400 Bad Request"); ok {
+		t.Error("Expected a non-SSH banner to be rejected")
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"8.9p1", "8.9p1", 0},
+		{"7.4", "8.9p1", -1},
+		{"9.0", "8.10p1", 1},
+		{"8.10", "8.9", 1},
+		{"8.9", "8.9p1", -1},
+		{"9", "9.6", -1},
+	}
+	for _, tt := range tests {
+		if got := CompareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestBannerCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "akumi", "banners.json")
+
+	banners, err := LoadBannerCache(path)
+	if err != nil || len(banners) != 0 {
+		t.Fatalf("Expected an empty cache, got %v (%v)", banners, err)
+	}
+
+	seen := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	banners["db.example.com:22"] = CachedBanner{Banner: "SSH-2.0-OpenSSH_7.4", Seen: seen}
+	if err := SaveBannerCache(path, banners); err != nil {
+		t.Fatalf("Failed to save cache: %v", err)
+	}
+
+	loaded, err := LoadBannerCache(path)
+	if err != nil {
+		t.Fatalf("Failed to load cache: %v", err)
+	}
+	if got := loaded["db.example.com:22"]; got.Banner != "SSH-2.0-OpenSSH_7.4" || !got.Seen.Equal(seen) {
+		t.Errorf("Unexpected cached banner %+v", got)
+	}
+}
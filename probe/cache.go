@@ -0,0 +1,73 @@
+package probe
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// CachedBanner is the last SSH banner received from an address.
+type CachedBanner struct {
+	// Banner is the server's identification string.
+	Banner string `json:"banner"`
+	// Seen is when the banner was received.
+	Seen time.Time `json:"seen"`
+}
+
+// DefaultCachePath returns the file banners are cached in between runs.
+func DefaultCachePath() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user cache directory: %w", err)
+	}
+	return filepath.Join(cacheDir, "akumi", "banners.json"), nil
+}
+
+// LoadBannerCache reads the banners cached at path, keyed by host:port.
+// A missing file yields an empty cache.
+func LoadBannerCache(path string) (map[string]CachedBanner, error) {
+	banners := make(map[string]CachedBanner)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return banners, nil
+		}
+		return banners, fmt.Errorf("failed to read banner cache %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, &banners); err != nil {
+		return make(map[string]CachedBanner), fmt.Errorf("failed to parse banner cache %s: %w", path, err)
+	}
+	return banners, nil
+}
+
+// SaveBannerCache writes banners to path, replacing the previous cache.
+func SaveBannerCache(path string, banners map[string]CachedBanner) error {
+	data, err := json.MarshalIndent(banners, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal banner cache: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return fmt.Errorf("failed to create cache directory for %s: %w", path, err)
+	}
+
+	// Write through a temporary file so concurrent readers never see a
+	// partial cache
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to write banner cache %s: %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write banner cache %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write banner cache %s: %w", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write banner cache %s: %w", path, err)
+	}
+	return nil
+}
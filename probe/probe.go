@@ -0,0 +1,123 @@
+// Package probe checks whether SSH servers are reachable and measures the
+// time it takes to connect to them.
+package probe
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxPreambleLines bounds the lines a server may send before its SSH
+// identification string (RFC 4253, section 4.2).
+const maxPreambleLines = 16
+
+// Result is the outcome of probing one address.
+type Result struct {
+	// Address is the host:port that was probed.
+	Address string
+	// Reachable is true when the TCP connection succeeded.
+	Reachable bool
+	// Latency is the time taken to establish the TCP connection.
+	Latency time.Duration
+	// Banner is the SSH identification string sent by the server, such as
+	// "SSH-2.0-OpenSSH_9.6". It is empty if none was received.
+	Banner string
+	// Err describes why the connection failed or no banner was read.
+	Err error
+	// Time is when the probe finished.
+	Time time.Time
+}
+
+// Prober probes addresses with a bounded number of concurrent workers.
+type Prober struct {
+	// Concurrency is the maximum number of probes in flight.
+	Concurrency int
+	// Timeout bounds the connection and banner read of each probe.
+	Timeout time.Duration
+}
+
+// Probe connects to address and reads the server's SSH banner.
+func (p Prober) Probe(ctx context.Context, address string) Result {
+	result := p.probe(ctx, address)
+	result.Time = time.Now()
+	return result
+}
+
+func (p Prober) probe(ctx context.Context, address string) Result {
+	result := Result{Address: address}
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	start := time.Now()
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	defer conn.Close()
+	result.Reachable = true
+	result.Latency = time.Since(start)
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetReadDeadline(deadline)
+	}
+	result.Banner, result.Err = readBanner(conn)
+	return result
+}
+
+// readBanner reads lines from the server until its SSH identification string
+func readBanner(conn net.Conn) (string, error) {
+	reader := bufio.NewReaderSize(conn, 256)
+	for range maxPreambleLines {
+		line, err := reader.ReadString('\n')
+		line = strings.TrimRight(line, "\r\n")
+		if strings.HasPrefix(line, "SSH-") {
+			return line, nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to read ssh banner: %w", err)
+		}
+	}
+	return "", errors.New("no ssh banner received")
+}
+
+// Run probes every address and sends each result to results as soon as it is
+// available. At most Concurrency probes run at once. Run returns when all
+// probes finished or ctx was cancelled; it does not close results.
+func (p Prober) Run(ctx context.Context, addresses []string, results chan<- Result) {
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	for range max(p.Concurrency, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for address := range jobs {
+				result := p.Probe(ctx, address)
+				select {
+				case results <- result:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	for _, address := range addresses {
+		select {
+		case jobs <- address:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(jobs)
+	wg.Wait()
+}
@@ -0,0 +1,144 @@
+package probe
+
+import (
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// listen starts a local listener that handles every connection with handle
+func listen(t *testing.T, handle func(net.Conn)) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				handle(conn)
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// closedAddress returns a local address nothing is listening on
+func closedAddress(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	address := ln.Addr().String()
+	ln.Close()
+	return address
+}
+
+func TestProbe(t *testing.T) {
+	ssh := listen(t, func(conn net.Conn) {
+		conn.Write([]byte("Welcome\r\nSSH-2.0-OpenSSH_9.6 Ubuntu\r\n"))
+	})
+	silent := listen(t, func(conn net.Conn) {
+		time.Sleep(time.Second)
+	})
+	closed := closedAddress(t)
+
+	p := Prober{Concurrency: 2, Timeout: 200 * time.Millisecond}
+
+	result := p.Probe(context.Background(), ssh)
+	if !result.Reachable || result.Err != nil || result.Banner != "SSH-2.0-OpenSSH_9.6 Ubuntu" {
+		t.Errorf("Expected the ssh banner, got %+v", result)
+	}
+	if result.Latency <= 0 || result.Time.IsZero() {
+		t.Errorf("Expected latency and time to be recorded, got %+v", result)
+	}
+
+	result = p.Probe(context.Background(), silent)
+	if !result.Reachable || result.Err == nil || result.Banner != "" {
+		t.Errorf("Expected a reachable host without banner, got %+v", result)
+	}
+
+	result = p.Probe(context.Background(), closed)
+	if result.Reachable || result.Err == nil {
+		t.Errorf("Expected an unreachable host, got %+v", result)
+	}
+}
+
+func TestRunLimitsConcurrency(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		active  int
+		maximum int
+		total   atomic.Int32
+	)
+	address := listen(t, func(conn net.Conn) {
+		mu.Lock()
+		active++
+		maximum = max(maximum, active)
+		mu.Unlock()
+
+		time.Sleep(50 * time.Millisecond)
+		total.Add(1)
+
+		// Leave before answering so the next probe cannot overlap this one
+		mu.Lock()
+		active--
+		mu.Unlock()
+		conn.Write([]byte("SSH-2.0-test\r\n"))
+	})
+
+	addresses := make([]string, 9)
+	for i := range addresses {
+		addresses[i] = address
+	}
+
+	results := make(chan Result, len(addresses))
+	Prober{Concurrency: 3, Timeout: time.Second}.Run(context.Background(), addresses, results)
+	close(results)
+
+	count := 0
+	for result := range results {
+		count++
+		if result.Banner != "SSH-2.0-test" {
+			t.Errorf("Unexpected result %+v", result)
+		}
+	}
+	if count != len(addresses) || total.Load() != int32(len(addresses)) {
+		t.Errorf("Expected %d results, got %d", len(addresses), count)
+	}
+	if maximum > 3 {
+		t.Errorf("Expected at most 3 concurrent probes, got %d", maximum)
+	}
+}
+
+func TestRunStopsOnCancel(t *testing.T) {
+	address := listen(t, func(conn net.Conn) {
+		time.Sleep(time.Second)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	results := make(chan Result)
+	done := make(chan struct{})
+	go func() {
+		Prober{Concurrency: 1, Timeout: 5 * time.Second}.Run(ctx, []string{address, address, address}, results)
+		close(done)
+	}()
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Run did not return after the context was cancelled")
+	}
+}
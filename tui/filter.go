@@ -0,0 +1,196 @@
+package tui
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/omegaatt36/akumi/config"
+	"github.com/omegaatt36/akumi/probe"
+)
+
+// fuzzyMatch reports whether every rune of query appears in text in order,
+// ignoring case, and returns the rune positions in text that matched.
+func fuzzyMatch(query, text string) ([]int, bool) {
+	queryRunes := []rune(strings.ToLower(query))
+	if len(queryRunes) == 0 {
+		return nil, true
+	}
+
+	positions := make([]int, 0, len(queryRunes))
+	qi := 0
+	for i, r := range []rune(text) {
+		if unicode.ToLower(r) == queryRunes[qi] {
+			positions = append(positions, i)
+			qi++
+			if qi == len(queryRunes) {
+				return positions, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// matchTarget fuzzy-matches query against the target's display string,
+// falling back to its group path and tags. Only display matches are highlighted.
+func matchTarget(query string, target config.SSHTarget) ([]int, bool) {
+	if positions, ok := fuzzyMatch(query, target.String()); ok {
+		return positions, true
+	}
+	for _, field := range append([]string{target.Group}, target.Tags...) {
+		if field == "" {
+			continue
+		}
+		if _, ok := fuzzyMatch(query, field); ok {
+			return nil, true
+		}
+	}
+	return nil, false
+}
+
+// parseFilterQuery splits a filter query into "#tag" selectors, "ssh:"
+// server version selectors and the remaining fuzzy text.
+func parseFilterQuery(query string) ([]string, []string, string) {
+	var (
+		tags    []string
+		servers []string
+		words   []string
+	)
+	for _, field := range strings.Fields(query) {
+		if tag, ok := strings.CutPrefix(field, "#"); ok {
+			if tag != "" {
+				tags = append(tags, tag)
+			}
+			continue
+		}
+		if server, ok := strings.CutPrefix(field, "ssh:"); ok {
+			if server != "" {
+				servers = append(servers, server)
+			}
+			continue
+		}
+		words = append(words, field)
+	}
+	return tags, servers, strings.Join(words, " ")
+}
+
+// matchServer reports whether banner satisfies an "ssh:" selector. A selector
+// starting with <, <=, >, >= or = compares the server's software version,
+// anything else matches a case-insensitive substring of the banner.
+func matchServer(selector, banner string) bool {
+	version, ok := probe.ParseBanner(banner)
+	if !ok {
+		return false
+	}
+	for _, op := range []string{"<=", ">=", "<", ">", "="} {
+		want, found := strings.CutPrefix(selector, op)
+		if !found {
+			continue
+		}
+		c := probe.CompareVersions(version.Version(), want)
+		switch op {
+		case "<=":
+			return c <= 0
+		case ">=":
+			return c >= 0
+		case "<":
+			return c < 0
+		case ">":
+			return c > 0
+		default:
+			return c == 0
+		}
+	}
+	return strings.Contains(strings.ToLower(banner), strings.ToLower(selector))
+}
+
+// filterQuery returns the active filter query, trimmed of surrounding spaces.
+func (m Model) filterQuery() string {
+	return strings.TrimSpace(m.FilterInput.Value())
+}
+
+// WithFilter returns the model with the list filter preset to query.
+func (m Model) WithFilter(query string) Model {
+	m.FilterInput.SetValue(query)
+	m.Cursor = 0
+	m.clampCursor()
+	return m
+}
+
+// selectedTargetIndex maps the cursor to an index into Targets, or -1 if the
+// cursor is not on a target.
+func (m Model) selectedTargetIndex() int {
+	row, ok := m.selectedRow()
+	if !ok {
+		return -1
+	}
+	return row.TargetIndex
+}
+
+// selectTarget moves the cursor onto the target at targetIndex, expanding its
+// group if necessary.
+func (m *Model) selectTarget(targetIndex int) {
+	if targetIndex >= 0 && targetIndex < len(m.Targets) {
+		m.expandGroup(m.Targets[targetIndex].Group)
+	}
+	for i, row := range m.visibleRows() {
+		if row.TargetIndex == targetIndex {
+			m.Cursor = i
+			m.scrollToCursor()
+			return
+		}
+	}
+	m.clampCursor()
+}
+
+// clampCursor keeps the cursor within the bounds of the visible rows.
+func (m *Model) clampCursor() {
+	visible := len(m.visibleRows())
+	if m.Cursor >= visible {
+		m.Cursor = visible - 1
+	}
+	if m.Cursor < 0 {
+		m.Cursor = 0
+	}
+	m.scrollToCursor()
+}
+
+// highlightMatches renders text with the runes at positions emphasized.
+func highlightMatches(text string, positions []int, base, match lipgloss.Style) string {
+	if len(positions) == 0 {
+		return base.Render(text)
+	}
+
+	matched := make(map[int]bool, len(positions))
+	for _, p := range positions {
+		matched[p] = true
+	}
+
+	var (
+		b       strings.Builder
+		segment []rune
+		inMatch bool
+	)
+	flush := func() {
+		if len(segment) == 0 {
+			return
+		}
+		if inMatch {
+			b.WriteString(match.Render(string(segment)))
+		} else {
+			b.WriteString(base.Render(string(segment)))
+		}
+		segment = segment[:0]
+	}
+	for i, r := range []rune(text) {
+		if matched[i] != inMatch {
+			flush()
+			inMatch = matched[i]
+		}
+		segment = append(segment, r)
+	}
+	flush()
+
+	return b.String()
+}
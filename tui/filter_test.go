@@ -0,0 +1,51 @@
+package tui
+
+import (
+	"testing"
+
+	"github.com/omegaatt36/akumi/config"
+)
+
+func TestFuzzyMatch(t *testing.T) {
+	positions, ok := fuzzyMatch("pdb", "[prod-db] root@10.0.0.5")
+	if !ok {
+		t.Fatal("Expected query to match")
+	}
+	expected := []int{1, 4, 7}
+	for i, p := range expected {
+		if positions[i] != p {
+			t.Errorf("Expected match positions %v, got %v", expected, positions)
+			break
+		}
+	}
+
+	if _, ok := fuzzyMatch("dbp", "[prod-db] root@10.0.0.5"); ok {
+		t.Error("Expected out-of-order query not to match")
+	}
+}
+
+func TestFilteredSelection(t *testing.T) {
+	m := Model{
+		Targets: []config.SSHTarget{
+			{Nickname: "web", User: "www", Host: "web.example.com", Port: 22},
+			{Nickname: "prod-db", User: "root", Host: "10.0.0.5", Port: 22},
+			{Nickname: "staging-db", User: "root", Host: "10.0.1.5", Port: 22},
+		},
+		FilterInput: newTextInput(),
+	}
+	m.FilterInput.SetValue("db")
+
+	if visible := m.visibleRows(); len(visible) != 2 {
+		t.Fatalf("Expected 2 visible targets, got %v", visible)
+	}
+
+	m.Cursor = 1
+	if idx := m.selectedTargetIndex(); idx != 2 {
+		t.Errorf("Expected cursor to map to target 2, got %d", idx)
+	}
+
+	m.selectTarget(1)
+	if m.Cursor != 0 {
+		t.Errorf("Expected cursor 0 for target 1, got %d", m.Cursor)
+	}
+}
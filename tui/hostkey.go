@@ -0,0 +1,140 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/omegaatt36/akumi/config"
+	"github.com/omegaatt36/akumi/hostkey"
+)
+
+// HostKeyInspection is the host key screen of one target.
+type HostKeyInspection struct {
+	// Target is the inspected target.
+	Target config.SSHTarget
+	// Address is the host:port the key was fetched from.
+	Address string
+	// Key is the host key presented by the server, nil while fetching.
+	Key ssh.PublicKey
+	// Err is set when the key could not be fetched.
+	Err error
+	// KnownHosts is the known_hosts file the key is compared with.
+	KnownHosts *hostkey.File
+}
+
+// Status compares the fetched key with the known_hosts entries.
+func (h HostKeyInspection) Status() hostkey.Status {
+	if h.Key == nil {
+		return hostkey.Unknown
+	}
+	return h.KnownHosts.Check(h.Address, h.Key)
+}
+
+// hostKeyFetchedMsg carries the host key fetched for an address
+type hostKeyFetchedMsg struct {
+	address string
+	key     ssh.PublicKey
+	err     error
+}
+
+// handleHostKey opens the host key screen for the selected target and starts
+// fetching its key
+func (m Model) handleHostKey() (tea.Model, tea.Cmd) {
+	target := m.Targets[m.selectedTargetIndex()]
+	address := m.probeAddress(target)
+	if address == "" {
+		m.StatusMessage = "Host keys can only be inspected for targets without a jump host"
+		m.StatusMessageType = StatusWarning
+		return m, hideStatusMessageAfterDelay
+	}
+
+	knownHosts, err := hostkey.Load(m.KnownHostsPath)
+	if err != nil {
+		log.Printf("Failed to load known hosts: %v", err)
+		m.StatusMessage = "Failed to read " + m.KnownHostsPath
+		m.StatusMessageType = StatusError
+		return m, hideStatusMessageAfterDelay
+	}
+
+	// Ask for the key types already recorded so a changed key is not
+	// mistaken for a key of another type
+	var keyTypes []string
+	for _, entry := range knownHosts.Lookup(address) {
+		if entry.Marker == "" {
+			keyTypes = append(keyTypes, entry.Key.Type())
+		}
+	}
+
+	m.HostKey = &HostKeyInspection{Target: target, Address: address, KnownHosts: knownHosts}
+	m.State = StateHostKey
+	hostKeyModeActive = true
+
+	timeout := m.Config.Probe.WithDefaults().Timeout
+	return m, func() tea.Msg {
+		key, err := hostkey.Fetch(context.Background(), address, timeout, keyTypes)
+		return hostKeyFetchedMsg{address: address, key: key, err: err}
+	}
+}
+
+// handleHostKeyFetched shows the fetched key if its screen is still open
+func (m Model) handleHostKeyFetched(msg hostKeyFetchedMsg) (tea.Model, tea.Cmd) {
+	if m.State != StateHostKey || m.HostKey == nil || m.HostKey.Address != msg.address {
+		return m, nil
+	}
+	inspection := *m.HostKey
+	inspection.Key, inspection.Err = msg.key, msg.err
+	m.HostKey = &inspection
+	return m, nil
+}
+
+// updateHostKeyState handles keypresses in the host key screen
+func (m Model) updateHostKeyState(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	inspection := m.HostKey
+	switch {
+	case key.Matches(msg, m.Keys.AcceptKey):
+		if inspection.Key != nil && inspection.Status() == hostkey.Unknown {
+			inspection.KnownHosts.Add(inspection.Address, inspection.Key)
+			return m, m.saveKnownHosts("Added the host key to " + inspection.KnownHosts.Path)
+		}
+
+	case key.Matches(msg, m.Keys.ReplaceKey):
+		if inspection.Key != nil && inspection.Status() == hostkey.Changed {
+			inspection.KnownHosts.Replace(inspection.Address, inspection.Key)
+			return m, m.saveKnownHosts("Replaced the host key in " + inspection.KnownHosts.Path)
+		}
+
+	case key.Matches(msg, m.Keys.RemoveKey):
+		if removed := inspection.KnownHosts.Remove(inspection.Address); removed > 0 {
+			return m, m.saveKnownHosts(fmt.Sprintf("Removed %d entry(s) from %s", removed, inspection.KnownHosts.Path))
+		}
+
+	case key.Matches(msg, m.Keys.Deny), key.Matches(msg, m.Keys.Quit):
+		m.State = StateListTargets
+		m.HostKey = nil
+		hostKeyModeActive = false
+	}
+
+	return m, nil
+}
+
+// saveKnownHosts writes the edited known_hosts file and reports the outcome.
+// If the file cannot be written the screen goes back to its contents on disk.
+func (m *Model) saveKnownHosts(success string) tea.Cmd {
+	if err := m.HostKey.KnownHosts.Save(); err != nil {
+		log.Printf("Failed to save known hosts: %v", err)
+		if knownHosts, err := hostkey.Load(m.HostKey.KnownHosts.Path); err == nil {
+			m.HostKey.KnownHosts = knownHosts
+		}
+		m.StatusMessage = "Failed to write " + m.HostKey.KnownHosts.Path
+		m.StatusMessageType = StatusError
+		return hideStatusMessageAfterDelay
+	}
+	m.StatusMessage = success
+	m.StatusMessageType = StatusSuccess
+	return hideStatusMessageAfterDelay
+}
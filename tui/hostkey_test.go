@@ -0,0 +1,119 @@
+package tui
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/omegaatt36/akumi/config"
+	"github.com/omegaatt36/akumi/hostkey"
+)
+
+// newHostKey generates an ed25519 host key
+func newHostKey(t *testing.T) ssh.Signer {
+	t.Helper()
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	signer, err := ssh.NewSignerFromKey(private)
+	if err != nil {
+		t.Fatalf("Failed to create signer: %v", err)
+	}
+	return signer
+}
+
+func TestHostKeyReplace(t *testing.T) {
+	signer := newHostKey(t)
+	serverConfig := &ssh.ServerConfig{NoClientAuth: true}
+	serverConfig.AddHostKey(signer)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				ssh.NewServerConn(conn, serverConfig)
+			}()
+		}
+	}()
+
+	// The host was rebuilt: known_hosts still holds its previous key
+	port := ln.Addr().(*net.TCPAddr).Port
+	address := ln.Addr().String()
+	knownHostsPath := filepath.Join(t.TempDir(), "known_hosts")
+	f, err := hostkey.Load(knownHostsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Add(address, newHostKey(t).PublicKey())
+	if err := f.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	m := Model{
+		State:          StateListTargets,
+		Keys:           DefaultKeyMap(),
+		KnownHostsPath: knownHostsPath,
+		Targets:        []config.SSHTarget{{Nickname: "web", User: "root", Host: "127.0.0.1", Port: port}},
+	}
+
+	press := func(keys string) tea.Cmd {
+		t.Helper()
+		updated, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(keys)})
+		m = updated.(Model)
+		return cmd
+	}
+
+	fetch := press("f")
+	if m.State != StateHostKey || fetch == nil {
+		t.Fatalf("Expected the host key screen to fetch the key, got state %s", m.State)
+	}
+	updated, _ := m.Update(fetch())
+	m = updated.(Model)
+
+	fingerprint := ssh.FingerprintSHA256(signer.PublicKey())
+	if view := m.View(); !strings.Contains(view, fingerprint) || !strings.Contains(view, "CHANGED") {
+		t.Errorf("Expected the changed key and its fingerprint, got:\n%s", view)
+	}
+
+	press("r")
+	if m.HostKey.Status() != hostkey.Known {
+		t.Errorf("Expected the key to be known after replacing, got %s", m.HostKey.Status())
+	}
+	saved, err := hostkey.Load(knownHostsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entries := saved.Lookup(address); len(entries) != 1 || entries[0].Fingerprint() != fingerprint {
+		t.Errorf("Expected known_hosts to hold only the new key, got %+v", entries)
+	}
+	if _, err := os.Stat(knownHostsPath + ".old"); err != nil {
+		t.Errorf("Expected the previous known_hosts to be kept: %v", err)
+	}
+
+	press("d")
+	if m.HostKey.Status() != hostkey.Unknown {
+		t.Errorf("Expected the key to be unknown after removing, got %s", m.HostKey.Status())
+	}
+
+	press("n")
+	if m.State != StateListTargets || m.HostKey != nil {
+		t.Errorf("Expected to return to the list, got state %s", m.State)
+	}
+}
@@ -1,31 +1,57 @@
 package tui
 
 import (
+	"fmt"
+	"log"
+	"strings"
+
 	"github.com/charmbracelet/bubbles/help"
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 
 	"github.com/omegaatt36/akumi/config"
+	"github.com/omegaatt36/akumi/connector"
+	"github.com/omegaatt36/akumi/hostkey"
+	"github.com/omegaatt36/akumi/probe"
 	"github.com/omegaatt36/akumi/tui/styles"
 )
 
 // KeyMap defines keybindings for different actions in the application
 type KeyMap struct {
-	Up        key.Binding
-	Down      key.Binding
-	Enter     key.Binding
-	Create    key.Binding
-	Edit      key.Binding
-	Delete    key.Binding
-	Quit      key.Binding
-	ForceQuit key.Binding
-	Confirm   key.Binding
-	Deny      key.Binding
-	Tab       key.Binding
-	ShiftTab  key.Binding
-	Escape    key.Binding
-	Back      key.Binding
+	Up         key.Binding
+	Down       key.Binding
+	PageUp     key.Binding
+	PageDown   key.Binding
+	Home       key.Binding
+	End        key.Binding
+	Enter      key.Binding
+	Create     key.Binding
+	Edit       key.Binding
+	Delete     key.Binding
+	Move       key.Binding
+	Import     key.Binding
+	Export     key.Binding
+	Backups    key.Binding
+	Profile    key.Binding
+	Details    key.Binding
+	Sort       key.Binding
+	HostKey    key.Binding
+	AcceptKey  key.Binding
+	ReplaceKey key.Binding
+	RemoveKey  key.Binding
+	Reload     key.Binding
+	Overwrite  key.Binding
+	Merge      key.Binding
+	Filter     key.Binding
+	Quit       key.Binding
+	ForceQuit  key.Binding
+	Confirm    key.Binding
+	Deny       key.Binding
+	Tab        key.Binding
+	ShiftTab   key.Binding
+	Escape     key.Binding
+	Back       key.Binding
 }
 
 // DefaultKeyMap returns the default keybindings
@@ -39,6 +65,22 @@ func DefaultKeyMap() KeyMap {
 			key.WithKeys("down", "j"),
 			key.WithHelp("↓/j", "Move down"),
 		),
+		PageUp: key.NewBinding(
+			key.WithKeys("pgup", "ctrl+b"),
+			key.WithHelp("pgup", "Page up"),
+		),
+		PageDown: key.NewBinding(
+			key.WithKeys("pgdown", "ctrl+f"),
+			key.WithHelp("pgdn", "Page down"),
+		),
+		Home: key.NewBinding(
+			key.WithKeys("home", "g"),
+			key.WithHelp("g/home", "Go to first"),
+		),
+		End: key.NewBinding(
+			key.WithKeys("end", "G"),
+			key.WithHelp("G/end", "Go to last"),
+		),
 		Enter: key.NewBinding(
 			key.WithKeys("enter"),
 			key.WithHelp("enter", "Select/Connect"),
@@ -55,6 +97,66 @@ func DefaultKeyMap() KeyMap {
 			key.WithKeys("d"),
 			key.WithHelp("d", "Delete connection"),
 		),
+		Move: key.NewBinding(
+			key.WithKeys("m"),
+			key.WithHelp("m", "Move to group"),
+		),
+		Import: key.NewBinding(
+			key.WithKeys("i"),
+			key.WithHelp("i", "Import from ~/.ssh/config"),
+		),
+		Export: key.NewBinding(
+			key.WithKeys("x"),
+			key.WithHelp("x", "Export to ~/.ssh/config.d/akumi"),
+		),
+		Backups: key.NewBinding(
+			key.WithKeys("b"),
+			key.WithHelp("b", "Restore a backup"),
+		),
+		Profile: key.NewBinding(
+			key.WithKeys("p"),
+			key.WithHelp("p", "Switch profile"),
+		),
+		Details: key.NewBinding(
+			key.WithKeys("v"),
+			key.WithHelp("v", "Toggle details"),
+		),
+		Sort: key.NewBinding(
+			key.WithKeys("s"),
+			key.WithHelp("s", "Sort by server version"),
+		),
+		HostKey: key.NewBinding(
+			key.WithKeys("f"),
+			key.WithHelp("f", "Inspect host key"),
+		),
+		AcceptKey: key.NewBinding(
+			key.WithKeys("a"),
+			key.WithHelp("a", "Accept key"),
+		),
+		ReplaceKey: key.NewBinding(
+			key.WithKeys("r"),
+			key.WithHelp("r", "Replace entry"),
+		),
+		RemoveKey: key.NewBinding(
+			key.WithKeys("d"),
+			key.WithHelp("d", "Remove entry"),
+		),
+		Reload: key.NewBinding(
+			key.WithKeys("r"),
+			key.WithHelp("r", "Reload, discard my change"),
+		),
+		Overwrite: key.NewBinding(
+			key.WithKeys("o"),
+			key.WithHelp("o", "Overwrite the file"),
+		),
+		Merge: key.NewBinding(
+			key.WithKeys("m"),
+			key.WithHelp("m", "Merge both changes"),
+		),
+		Filter: key.NewBinding(
+			key.WithKeys("/"),
+			key.WithHelp("/", "Filter connections"),
+		),
 		Quit: key.NewBinding(
 			key.WithKeys("q"),
 			key.WithHelp("q", "Quit"),
@@ -93,21 +195,41 @@ func DefaultKeyMap() KeyMap {
 // ShortHelp returns keybindings to be shown in the mini help view.
 func (k KeyMap) ShortHelp() []key.Binding {
 	switch {
+	case conflictModeActive:
+		return []key.Binding{k.Reload, k.Overwrite, k.Merge}
 	case inputModeActive:
 		// Create copy of Enter with "Next field" help text
 		nextFieldEnter := k.Enter
 		nextFieldEnter.SetHelp("enter", "Next field")
 		return []key.Binding{k.Tab, k.ShiftTab, nextFieldEnter, k.Escape}
+	case filterModeActive:
+		applyFilter := k.Enter
+		applyFilter.SetHelp("enter", "Apply filter")
+		clearFilter := k.Escape
+		clearFilter.SetHelp("esc", "Clear filter")
+		return []key.Binding{filterUp, filterDown, applyFilter, clearFilter}
+	case moveModeActive:
+		applyMove := k.Enter
+		applyMove.SetHelp("enter", "Move")
+		return []key.Binding{applyMove, k.Escape}
+	case hostKeyModeActive:
+		return []key.Binding{k.AcceptKey, k.ReplaceKey, k.RemoveKey, k.Deny}
+	case importModeActive, backupModeActive, profileModeActive:
+		return []key.Binding{k.Up, k.Down, k.Confirm, k.Deny}
 	case confirmationModeActive:
 		return []key.Binding{k.Confirm, k.Deny}
 	default:
-		return []key.Binding{k.Up, k.Down, k.Enter, k.Create, k.Edit, k.Delete, k.Quit}
+		return []key.Binding{k.Up, k.Down, k.Enter, k.Filter, k.Create, k.Edit, k.Delete, k.Move, k.Import, k.Export, k.Backups, k.Profile, k.Quit}
 	}
 }
 
 // FullHelp returns keybindings for the expanded help view.
 func (k KeyMap) FullHelp() [][]key.Binding {
 	switch {
+	case conflictModeActive:
+		return [][]key.Binding{
+			{k.Reload, k.Overwrite, k.Merge},
+		}
 	case inputModeActive:
 		// Create copy of Enter with "Next field" help text
 		nextFieldEnter := k.Enter
@@ -115,33 +237,84 @@ func (k KeyMap) FullHelp() [][]key.Binding {
 		return [][]key.Binding{
 			{k.Tab, k.ShiftTab, nextFieldEnter, k.Escape},
 		}
+	case filterModeActive:
+		applyFilter := k.Enter
+		applyFilter.SetHelp("enter", "Apply filter")
+		clearFilter := k.Escape
+		clearFilter.SetHelp("esc", "Clear filter")
+		return [][]key.Binding{
+			{filterUp, filterDown},
+			{applyFilter, clearFilter},
+		}
+	case moveModeActive:
+		applyMove := k.Enter
+		applyMove.SetHelp("enter", "Move")
+		return [][]key.Binding{
+			{applyMove, k.Escape},
+		}
+	case hostKeyModeActive:
+		return [][]key.Binding{
+			{k.AcceptKey, k.ReplaceKey, k.RemoveKey},
+			{k.Deny},
+		}
+	case importModeActive, backupModeActive, profileModeActive:
+		return [][]key.Binding{
+			{k.Up, k.Down},
+			{k.Confirm, k.Deny},
+		}
 	case confirmationModeActive:
 		return [][]key.Binding{
 			{k.Confirm, k.Deny},
 		}
 	default:
 		return [][]key.Binding{
-			{k.Up, k.Down, k.Enter},
-			{k.Create, k.Edit, k.Delete},
+			{k.Up, k.Down, k.Enter, k.Filter},
+			{k.PageUp, k.PageDown, k.Home, k.End},
+			{k.Create, k.Edit, k.Delete, k.Move},
+			{k.Import, k.Export, k.Backups, k.Profile},
+			{k.Details, k.Sort, k.HostKey},
 			{k.Quit, k.ForceQuit},
 		}
 	}
 }
 
-// Track if we're in input or confirmation mode for help context
+// Track if we're in input, confirmation, import, filter, move, backup, profile or conflict mode for help context
 var (
 	inputModeActive        = false
 	confirmationModeActive = false
+	importModeActive       = false
+	filterModeActive       = false
+	moveModeActive         = false
+	backupModeActive       = false
+	profileModeActive      = false
+	hostKeyModeActive      = false
+	conflictModeActive     = false
+)
+
+// Arrow-only navigation used while typing a filter, since j/k are valid query runes
+var (
+	filterUp = key.NewBinding(
+		key.WithKeys("up"),
+		key.WithHelp("↑", "Move up"),
+	)
+	filterDown = key.NewBinding(
+		key.WithKeys("down"),
+		key.WithHelp("↓", "Move down"),
+	)
 )
 
 // Model represents the state of the TUI application.
 type Model struct {
 	// State represents the current view state of the application.
 	State ViewState
+	// Config is the full loaded configuration, written back with Targets on save.
+	Config config.Config
 	// Targets is the list of configured SSH targets.
 	Targets []config.SSHTarget
-	// Cursor is the current position in the target list.
+	// Cursor is the current position in the filtered target list.
 	Cursor int
+	// ListOffset is the first filtered row rendered in the scrolling list.
+	ListOffset int
 	// Err holds any errors that occur during execution/loading.
 	Err error
 	// CreateInputs holds the input fields for creating/editing targets.
@@ -154,6 +327,9 @@ type Model struct {
 	TerminalHeight int
 	// SaveError holds any errors that occur during configuration saves.
 	SaveError error
+	// ConfigConflict is set when a save found the config file modified by
+	// another process, until the user picks reload, overwrite or merge.
+	ConfigConflict bool
 	// EditIndex tracks which target is being edited (-1 if not editing).
 	EditIndex int
 	// KeyMap holds the keyboard shortcut configurations
@@ -164,6 +340,47 @@ type Model struct {
 	StatusMessage string
 	// StatusMessageType defines the type (info, error, etc) of status message
 	StatusMessageType StatusMessageType
+	// ImportCandidates holds targets parsed from ~/.ssh/config awaiting confirmation.
+	ImportCandidates []config.ImportCandidate
+	// ImportCursor is the current position in the import preview list.
+	ImportCursor int
+	// ImportOffset is the index of the first import candidate on screen.
+	ImportOffset int
+	// FilterInput holds the fuzzy filter query for the target list.
+	FilterInput textinput.Model
+	// Filtering is true while the filter query is being typed.
+	Filtering bool
+	// Collapsed tracks which group paths are collapsed in the target tree.
+	Collapsed map[string]bool
+	// MoveInput holds the destination group when moving a target.
+	MoveInput textinput.Model
+	// Backups holds the configuration backups shown in the restore screen.
+	Backups []config.Backup
+	// BackupCursor is the current position in the backup list.
+	BackupCursor int
+	// Profiles lists the available profile names, the default profile first.
+	Profiles []string
+	// ProfileCursor is the current position in the profile switcher.
+	ProfileCursor int
+	// Probes holds the latest reachability result per probed host:port.
+	Probes map[string]probe.Result
+	// Banners holds the last SSH banner seen per host:port, including those
+	// cached by earlier sessions.
+	Banners map[string]probe.CachedBanner
+	// BannerCachePath is the file banners are persisted to, or "" to keep
+	// them in memory only.
+	BannerCachePath string
+	// ShowDetails is true while the detail pane of the selected target is shown.
+	ShowDetails bool
+	// SortByVersion orders targets within each group by server version.
+	SortByVersion bool
+	// HostKey holds the host key screen while it is open.
+	HostKey *HostKeyInspection
+	// KnownHostsPath is the known_hosts file host keys are checked against.
+	KnownHostsPath string
+	// Connector connects to every target when set, instead of the connector
+	// selected by the config. Tests use it to avoid starting ssh.
+	Connector connector.Connector
 }
 
 // StatusMessageType represents different status message styles
@@ -179,10 +396,15 @@ const (
 func newTextInput() textinput.Model {
 	ti := textinput.New()
 	ti.Prompt = ""
+	styleTextInput(&ti)
+	return ti
+}
+
+// styleTextInput applies the current theme to a text input
+func styleTextInput(ti *textinput.Model) {
 	ti.PromptStyle = styles.InputLabel
 	ti.PlaceholderStyle = styles.InputField.Faint(true)
 	ti.TextStyle = styles.InputField
-	return ti
 }
 
 // InitialModel creates and returns the initial application model.
@@ -196,19 +418,61 @@ func InitialModel() Model {
 	styles.Initialize(cfg.Theme)
 
 	inputs := make([]textinput.Model, NumInputs)
-	placeholders := []string{"Username", "Host", "Port (default 22)", "Nickname (optional)"}
+	placeholders := []string{
+		"Username",
+		"Host",
+		"Port (default 22)",
+		"Nickname (optional)",
+		"Group (optional, e.g. prod/db)",
+		"Tags (optional, e.g. env:prod, role:db)",
+		"Identity file (optional, e.g. ~/.ssh/id_ed25519)",
+		"Jump host (optional, nickname or user@host:port)",
+		"Forward agent (yes/no, default no)",
+		"Options (optional, e.g. StrictHostKeyChecking=no, RequestTTY=force)",
+		"Connector (optional, e.g. mosh, default openssh)",
+	}
 	for i := range inputs {
 		inputs[i] = newTextInput()
 		inputs[i].Placeholder = placeholders[i]
 		inputs[i].CharLimit = 156
 	}
+	applyDefaultPlaceholders(inputs, cfg.Defaults)
 	inputs[InputUser].Focus()
 
+	filterInput := newTextInput()
+	filterInput.Prompt = "/"
+	filterInput.Placeholder = "Type to filter, #tag by tag, ssh:<9 by server version"
+	filterInput.CharLimit = 64
+
+	moveInput := newTextInput()
+	moveInput.Placeholder = "Group (empty for top level)"
+	moveInput.CharLimit = 156
+
+	profiles, err := config.ListProfiles()
+	if err != nil {
+		log.Printf("Failed to list profiles: %v", err)
+	}
+
+	bannerCachePath, err := probe.DefaultCachePath()
+	if err != nil {
+		log.Printf("Failed to locate banner cache: %v", err)
+	}
+	banners, err := probe.LoadBannerCache(bannerCachePath)
+	if err != nil {
+		log.Printf("Failed to load banner cache: %v", err)
+	}
+
+	knownHostsPath, err := hostkey.DefaultPath()
+	if err != nil {
+		log.Printf("Failed to locate known hosts: %v", err)
+	}
+
 	keyMap := DefaultKeyMap()
 	help := help.New()
 
 	return Model{
 		State:        StateListTargets,
+		Config:       cfg,
 		Targets:      cfg.Targets,
 		Cursor:       0,
 		CreateInputs: inputs,
@@ -216,6 +480,34 @@ func InitialModel() Model {
 		EditIndex:    -1,
 		Keys:         keyMap,
 		Help:         help,
+		FilterInput:  filterInput,
+		Collapsed:    make(map[string]bool),
+		MoveInput:    moveInput,
+		Profiles:     profiles,
+
+		Banners:         banners,
+		BannerCachePath: bannerCachePath,
+		KnownHostsPath:  knownHostsPath,
+	}
+}
+
+// applyDefaultPlaceholders shows the profile defaults in the placeholders of
+// the fields that fall back to them when left empty
+func applyDefaultPlaceholders(inputs []textinput.Model, defaults config.TargetDefaults) {
+	fields := []struct {
+		input       int
+		placeholder string
+		value       string
+	}{
+		{InputUser, "Username", defaults.User},
+		{InputIdentityFile, "Identity file (optional, e.g. ~/.ssh/id_ed25519)", defaults.IdentityFile},
+		{InputProxyJump, "Jump host (optional, nickname or user@host:port)", defaults.ProxyJump},
+	}
+	for _, field := range fields {
+		inputs[field.input].Placeholder = field.placeholder
+		if field.value != "" {
+			inputs[field.input].Placeholder = fmt.Sprintf("%s (default %s)", strings.SplitN(field.placeholder, " (", 2)[0], field.value)
+		}
 	}
 }
 
@@ -223,22 +515,38 @@ func InitialModel() Model {
 func (m Model) Init() tea.Cmd {
 	inputModeActive = false
 	confirmationModeActive = false
+	importModeActive = false
+	filterModeActive = false
+	moveModeActive = false
+	backupModeActive = false
+	profileModeActive = false
+	hostKeyModeActive = false
+	conflictModeActive = m.ConfigConflict
+
+	if m.Err != nil {
+		return nil
+	}
 
-	if m.Err == nil {
-		switch m.State {
-		case StateCreateTarget, StateEditTarget:
-			inputModeActive = true
-			if m.CreateFocus >= 0 && m.CreateFocus < len(m.CreateInputs) {
-				// Focus and update width for proper display
-				for i := range m.CreateInputs {
-					if i == m.CreateFocus {
-						return m.CreateInputs[m.CreateFocus].Focus()
-					}
-				}
-			}
-		case StateConfirmDelete:
-			confirmationModeActive = true
+	var cmd tea.Cmd
+	switch m.State {
+	case StateCreateTarget, StateEditTarget:
+		inputModeActive = true
+		if m.CreateFocus >= 0 && m.CreateFocus < len(m.CreateInputs) {
+			// Focus and update width for proper display
+			cmd = m.CreateInputs[m.CreateFocus].Focus()
 		}
+	case StateConfirmDelete:
+		confirmationModeActive = true
+	case StateImportTargets:
+		importModeActive = true
+	case StateMoveTarget:
+		moveModeActive = true
+	case StateBackups:
+		backupModeActive = true
+	case StateProfiles:
+		profileModeActive = true
+	case StateHostKey:
+		hostKeyModeActive = true
 	}
-	return nil
+	return tea.Batch(cmd, m.watchConfig(), m.probeTargets())
 }
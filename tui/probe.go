@@ -0,0 +1,241 @@
+package tui
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log"
+	"maps"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/omegaatt36/akumi/config"
+	"github.com/omegaatt36/akumi/probe"
+	"github.com/omegaatt36/akumi/tui/styles"
+)
+
+// ProbeResultMsg carries the result of probing the address of a target.
+type ProbeResultMsg struct {
+	Result probe.Result
+
+	// results delivers the remaining results of the round
+	results <-chan probe.Result
+}
+
+// probeRoundDoneMsg is sent when every target of a round was probed
+type probeRoundDoneMsg struct{}
+
+// probeTickMsg starts the next round of probes
+type probeTickMsg struct{}
+
+// probeAddress returns the host:port probed for target, or "" when the target
+// is only reachable through a jump host
+func (m Model) probeAddress(target config.SSHTarget) string {
+	target = m.Config.Defaults.Apply(target)
+	if expanded, err := target.Expand(); err == nil {
+		target = expanded
+	}
+	if target.ProxyJump != "" || target.Host == "" {
+		return ""
+	}
+	port := target.Port
+	if port == 0 {
+		port = 22
+	}
+	return net.JoinHostPort(target.Host, strconv.Itoa(port))
+}
+
+// probeTargets probes the address of every target in the background. Each
+// result is delivered as a ProbeResultMsg.
+func (m Model) probeTargets() tea.Cmd {
+	settings := m.Config.Probe.WithDefaults()
+	if settings.Disabled {
+		return nil
+	}
+
+	var addresses []string
+	seen := make(map[string]bool)
+	for _, target := range m.Targets {
+		if address := m.probeAddress(target); address != "" && !seen[address] {
+			seen[address] = true
+			addresses = append(addresses, address)
+		}
+	}
+
+	prober := probe.Prober{Concurrency: settings.Concurrency, Timeout: settings.Timeout}
+	return func() tea.Msg {
+		results := make(chan probe.Result)
+		go func() {
+			prober.Run(context.Background(), addresses, results)
+			close(results)
+		}()
+		return waitForProbe(results)()
+	}
+}
+
+// waitForProbe waits for the next result of a round
+func waitForProbe(results <-chan probe.Result) tea.Cmd {
+	return func() tea.Msg {
+		result, ok := <-results
+		if !ok {
+			return probeRoundDoneMsg{}
+		}
+		return ProbeResultMsg{Result: result, results: results}
+	}
+}
+
+// scheduleProbes starts the next round after the configured interval
+func (m Model) scheduleProbes() tea.Cmd {
+	settings := m.Config.Probe.WithDefaults()
+	if settings.Disabled {
+		return nil
+	}
+	return tea.Tick(settings.Interval, func(time.Time) tea.Msg {
+		return probeTickMsg{}
+	})
+}
+
+// handleProbeResult records a probe result and waits for the next one. New
+// server banners are written to the banner cache.
+func (m Model) handleProbeResult(msg ProbeResultMsg) (tea.Model, tea.Cmd) {
+	if m.Probes == nil {
+		m.Probes = make(map[string]probe.Result)
+	}
+	result := msg.Result
+	m.Probes[result.Address] = result
+
+	var save tea.Cmd
+	if result.Banner != "" {
+		if m.Banners == nil {
+			m.Banners = make(map[string]probe.CachedBanner)
+		}
+		changed := m.Banners[result.Address].Banner != result.Banner
+		m.Banners[result.Address] = probe.CachedBanner{Banner: result.Banner, Seen: result.Time}
+		if changed {
+			save = m.saveBanners()
+		}
+	}
+
+	if msg.results == nil {
+		return m, save
+	}
+	return m, tea.Batch(waitForProbe(msg.results), save)
+}
+
+// saveBanners writes a snapshot of the known banners to the banner cache
+func (m Model) saveBanners() tea.Cmd {
+	if m.BannerCachePath == "" {
+		return nil
+	}
+	path := m.BannerCachePath
+	banners := maps.Clone(m.Banners)
+	return func() tea.Msg {
+		if err := probe.SaveBannerCache(path, banners); err != nil {
+			log.Printf("Failed to save banner cache: %v", err)
+		}
+		return nil
+	}
+}
+
+// serverBanner returns the SSH banner of target from the latest probe, or
+// from the banner cache when this session has not received one yet
+func (m Model) serverBanner(target config.SSHTarget) (probe.CachedBanner, bool) {
+	address := m.probeAddress(target)
+	if address == "" {
+		return probe.CachedBanner{}, false
+	}
+	if result, ok := m.Probes[address]; ok && result.Banner != "" {
+		return probe.CachedBanner{Banner: result.Banner, Seen: result.Time}, true
+	}
+	banner, ok := m.Banners[address]
+	return banner, ok && banner.Banner != ""
+}
+
+// matchServers reports whether the banner of target satisfies every "ssh:"
+// selector; targets without a known banner match none
+func (m Model) matchServers(selectors []string, target config.SSHTarget) bool {
+	if len(selectors) == 0 {
+		return true
+	}
+	banner, ok := m.serverBanner(target)
+	if !ok {
+		return false
+	}
+	for _, selector := range selectors {
+		if !matchServer(selector, banner.Banner) {
+			return false
+		}
+	}
+	return true
+}
+
+// sortByVersion orders target indexes by server software and version, oldest
+// first, keeping targets without a known banner last in config order
+func (m Model) sortByVersion(indexes []int) []int {
+	versions := make(map[int]probe.ServerVersion, len(indexes))
+	for _, idx := range indexes {
+		if banner, ok := m.serverBanner(m.Targets[idx]); ok {
+			if version, ok := probe.ParseBanner(banner.Banner); ok {
+				versions[idx] = version
+			}
+		}
+	}
+
+	sorted := slices.Clone(indexes)
+	slices.SortStableFunc(sorted, func(a, b int) int {
+		va, aok := versions[a]
+		vb, bok := versions[b]
+		if !aok || !bok {
+			return cmp.Compare(boolRank(aok), boolRank(bok))
+		}
+		if c := strings.Compare(va.Name(), vb.Name()); c != 0 {
+			return c
+		}
+		return probe.CompareVersions(va.Version(), vb.Version())
+	})
+	return sorted
+}
+
+// boolRank sorts known values before unknown ones
+func boolRank(known bool) int {
+	if known {
+		return 0
+	}
+	return 1
+}
+
+// renderProbeStatus renders the status dot and round-trip time of a target:
+// green with an SSH banner, yellow when the port is open but no SSH server
+// answered and red when unreachable
+func (m Model) renderProbeStatus(target config.SSHTarget) string {
+	address := m.probeAddress(target)
+	if address == "" || m.Config.Probe.Disabled {
+		return ""
+	}
+
+	result, ok := m.Probes[address]
+	switch {
+	case !ok:
+		return " " + styles.BaseStyle.Faint(true).Render("○")
+	case !result.Reachable:
+		return " " + styles.ErrorText.Render("●")
+	case result.Banner == "":
+		return " " + styles.BaseStyle.Foreground(styles.WarningColor).Render("●") + " " + formatLatency(result.Latency)
+	default:
+		return " " + styles.BaseStyle.Foreground(styles.SuccessColor).Render("●") + " " + formatLatency(result.Latency)
+	}
+}
+
+// formatLatency renders a round-trip time in milliseconds
+func formatLatency(latency time.Duration) string {
+	ms := float64(latency) / float64(time.Millisecond)
+	if ms < 10 {
+		return styles.BaseStyle.Faint(true).Render(fmt.Sprintf("%.1fms", ms))
+	}
+	return styles.BaseStyle.Faint(true).Render(fmt.Sprintf("%.0fms", ms))
+}
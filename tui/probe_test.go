@@ -0,0 +1,147 @@
+package tui
+
+import (
+	"net"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/omegaatt36/akumi/config"
+	"github.com/omegaatt36/akumi/probe"
+)
+
+func TestProbeTargets(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Write([]byte("SSH-2.0-OpenSSH_9.6\r\n"))
+			conn.Close()
+		}
+	}()
+
+	port := ln.Addr().(*net.TCPAddr).Port
+	m := Model{
+		State:  StateListTargets,
+		Config: config.Config{Defaults: config.TargetDefaults{ProxyJump: "bastion"}},
+		Targets: []config.SSHTarget{
+			{Nickname: "bastion", User: "root", Host: "127.0.0.1", Port: port},
+			{Nickname: "behind", User: "root", Host: "10.0.0.1", Port: 22},
+		},
+	}
+	if addr := m.probeAddress(m.Targets[1]); addr != "" {
+		t.Errorf("Expected a target behind a jump host not to be probed, got %s", addr)
+	}
+
+	msg, ok := m.probeTargets()().(ProbeResultMsg)
+	if !ok {
+		t.Fatalf("Expected a probe result, got %T", msg)
+	}
+	if want := "127.0.0.1:" + strconv.Itoa(port); msg.Result.Address != want || msg.Result.Banner != "SSH-2.0-OpenSSH_9.6" {
+		t.Errorf("Unexpected probe result %+v", msg.Result)
+	}
+
+	updated, cmd := m.Update(msg)
+	m = updated.(Model)
+	if status := m.renderProbeStatus(m.Targets[0]); !strings.Contains(status, "●") || !strings.Contains(status, "ms") {
+		t.Errorf("Expected a status dot with latency, got %q", status)
+	}
+	if _, ok := cmd().(probeRoundDoneMsg); !ok {
+		t.Error("Expected the round to finish after the only address was probed")
+	}
+}
+
+func TestServerVersionSortAndFilter(t *testing.T) {
+	m := Model{
+		State: StateListTargets,
+		Keys:  DefaultKeyMap(),
+		Targets: []config.SSHTarget{
+			{Nickname: "new", User: "root", Host: "10.0.0.1", Port: 22},
+			{Nickname: "unknown", User: "root", Host: "10.0.0.2", Port: 22},
+			{Nickname: "old", User: "root", Host: "10.0.0.3", Port: 22},
+			{Nickname: "mid", User: "root", Host: "10.0.0.4", Port: 22},
+		},
+		Probes: map[string]probe.Result{
+			"10.0.0.1:22": {Address: "10.0.0.1:22", Reachable: true, Banner: "SSH-2.0-OpenSSH_9.6p1 Ubuntu-3ubuntu13"},
+		},
+		Banners: map[string]probe.CachedBanner{
+			"10.0.0.3:22": {Banner: "SSH-2.0-OpenSSH_7.4", Seen: time.Now()},
+			"10.0.0.4:22": {Banner: "SSH-2.0-OpenSSH_8.9p1"},
+		},
+	}
+
+	nicknames := func(m Model) []string {
+		var names []string
+		for _, row := range m.visibleRows() {
+			names = append(names, m.Targets[row.TargetIndex].Nickname)
+		}
+		return names
+	}
+
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("s")})
+	m = updated.(Model)
+	if got, want := nicknames(m), []string{"old", "mid", "new", "unknown"}; !slices.Equal(got, want) {
+		t.Errorf("Expected targets sorted by version %v, got %v", want, got)
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"ssh:<8", []string{"old"}},
+		{"ssh:>=8.9", []string{"mid", "new"}},
+		{"ssh:ubuntu", []string{"new"}},
+		{"ssh:openssh ssh:<9 m", []string{"mid"}},
+	}
+	for _, tt := range tests {
+		if got := nicknames(m.WithFilter(tt.query)); !slices.Equal(got, tt.want) {
+			t.Errorf("Filter %q: expected %v, got %v", tt.query, tt.want, got)
+		}
+	}
+
+	for _, keys := range []string{"g", "v"} {
+		updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(keys)})
+		m = updated.(Model)
+	}
+	view := m.View()
+	if !strings.Contains(view, "OpenSSH_7.4") || !strings.Contains(view, "10.0.0.3:22") || !strings.Contains(view, "pending") {
+		t.Errorf("Expected the detail pane of the selected target, got:\n%s", view)
+	}
+}
+
+func TestProbeResultUpdatesBannerCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "banners.json")
+	m := Model{State: StateListTargets, BannerCachePath: path}
+
+	result := probe.Result{Address: "10.0.0.1:22", Reachable: true, Banner: "SSH-2.0-OpenSSH_9.6", Time: time.Now()}
+	updated, cmd := m.Update(ProbeResultMsg{Result: result})
+	m = updated.(Model)
+	if cmd == nil {
+		t.Fatal("Expected a new banner to be saved")
+	}
+	cmd()
+
+	banners, err := probe.LoadBannerCache(path)
+	if err != nil {
+		t.Fatalf("Failed to load banner cache: %v", err)
+	}
+	if banners["10.0.0.1:22"].Banner != "SSH-2.0-OpenSSH_9.6" {
+		t.Errorf("Expected the banner to be cached, got %v", banners)
+	}
+
+	if _, cmd := m.Update(ProbeResultMsg{Result: result}); cmd != nil {
+		t.Error("Expected an unchanged banner not to be saved again")
+	}
+}
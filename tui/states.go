@@ -12,6 +12,16 @@ const (
 	StateEditTarget
 	// StateConfirmDelete represents the confirmation dialog for deleting a target.
 	StateConfirmDelete
+	// StateImportTargets represents the preview of targets imported from ~/.ssh/config.
+	StateImportTargets
+	// StateMoveTarget represents the prompt for moving a target to another group.
+	StateMoveTarget
+	// StateBackups represents the list of configuration backups that can be restored.
+	StateBackups
+	// StateProfiles represents the profile switcher.
+	StateProfiles
+	// StateHostKey represents the host key inspection of a target.
+	StateHostKey
 )
 
 const (
@@ -23,6 +33,20 @@ const (
 	InputPort
 	// InputNickname is the index for the optional nickname input field.
 	InputNickname
+	// InputGroup is the index for the optional group path input field.
+	InputGroup
+	// InputTags is the index for the optional comma-separated tags input field.
+	InputTags
+	// InputIdentityFile is the index for the optional private key path input field.
+	InputIdentityFile
+	// InputProxyJump is the index for the optional jump host input field.
+	InputProxyJump
+	// InputForwardAgent is the index for the agent forwarding (yes/no) input field.
+	InputForwardAgent
+	// InputOptions is the index for the extra ssh options input field.
+	InputOptions
+	// InputConnector is the index for the optional connector name input field.
+	InputConnector
 	// NumInputs represents the total number of input fields.
 	NumInputs
 )
@@ -33,6 +57,11 @@ var StateNames = map[ViewState]string{
 	StateCreateTarget:  "Create View",
 	StateEditTarget:    "Edit View",
 	StateConfirmDelete: "Confirm Delete",
+	StateImportTargets: "Import Preview",
+	StateMoveTarget:    "Move Target",
+	StateBackups:       "Backups",
+	StateProfiles:      "Profiles",
+	StateHostKey:       "Host Key",
 }
 
 // GetStateName returns a human-readable name for the current state
@@ -61,6 +61,9 @@ var (
 	ListItem         lipgloss.Style
 	SelectedListItem lipgloss.Style
 	CursorStyle      lipgloss.Style
+	MatchHighlight   lipgloss.Style
+	GroupHeader      lipgloss.Style
+	TagBadge         lipgloss.Style
 	InputLabel       lipgloss.Style
 	InputField       lipgloss.Style
 	ActiveInputField lipgloss.Style
@@ -103,6 +106,20 @@ func updateStyles() {
 		Foreground(highlightColor).
 		Bold(true)
 
+	MatchHighlight = lipgloss.NewStyle().
+		Foreground(warningColor).
+		Underline(true).
+		Bold(true)
+
+	GroupHeader = lipgloss.NewStyle().
+		Foreground(secondaryColor).
+		Bold(true)
+
+	TagBadge = lipgloss.NewStyle().
+		Foreground(textColor).
+		Background(secondaryColor).
+		Padding(0, 1)
+
 	// Input Styles
 	InputLabel = lipgloss.NewStyle().
 		Foreground(secondaryColor).
@@ -0,0 +1,144 @@
+package tui
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/omegaatt36/akumi/config"
+)
+
+// listRow is a single line of the target tree: either a group header or a target.
+type listRow struct {
+	// Group is the full group path of the header, or of the target's group.
+	Group string
+	// Depth is the nesting level used for indentation.
+	Depth int
+	// TargetIndex is the index into Targets, or -1 for a group header.
+	TargetIndex int
+	// Count is the number of targets below a group header.
+	Count int
+}
+
+// IsGroup reports whether the row is a group header.
+func (r listRow) IsGroup() bool {
+	return r.TargetIndex < 0
+}
+
+// Name returns the last segment of the row's group path.
+func (r listRow) Name() string {
+	return r.Group[strings.LastIndex(r.Group, "/")+1:]
+}
+
+// groupNode is an intermediate tree node used to build the list rows.
+type groupNode struct {
+	path     string
+	children map[string]*groupNode
+	targets  []int
+	count    int
+}
+
+func newGroupNode(path string) *groupNode {
+	return &groupNode{path: path, children: make(map[string]*groupNode)}
+}
+
+// visibleRows returns the rows of the target tree that pass the current filter.
+// Collapsed groups hide their contents unless a filter is active.
+func (m Model) visibleRows() []listRow {
+	query := m.filterQuery()
+	tags, servers, text := parseFilterQuery(query)
+	root := newGroupNode("")
+	for i, target := range m.Targets {
+		if !target.HasTags(tags...) || !m.matchServers(servers, target) {
+			continue
+		}
+		if _, ok := matchTarget(text, target); !ok {
+			continue
+		}
+		node := root
+		node.count++
+		for _, segment := range target.GroupPath() {
+			child, ok := node.children[segment]
+			if !ok {
+				child = newGroupNode(strings.TrimPrefix(node.path+"/"+segment, "/"))
+				node.children[segment] = child
+			}
+			node = child
+			node.count++
+		}
+		node.targets = append(node.targets, i)
+	}
+
+	var rows []listRow
+	m.appendGroupRows(&rows, root, 0, query != "")
+	return rows
+}
+
+// appendGroupRows appends the child groups and then the targets of node.
+func (m Model) appendGroupRows(rows *[]listRow, node *groupNode, depth int, expandAll bool) {
+	names := make([]string, 0, len(node.children))
+	for name := range node.children {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	targets := node.targets
+	if m.SortByVersion {
+		targets = m.sortByVersion(targets)
+	}
+
+	for _, name := range names {
+		child := node.children[name]
+		*rows = append(*rows, listRow{Group: child.path, Depth: depth, TargetIndex: -1, Count: child.count})
+		if expandAll || !m.Collapsed[child.path] {
+			m.appendGroupRows(rows, child, depth+1, expandAll)
+		}
+	}
+
+	for _, idx := range targets {
+		*rows = append(*rows, listRow{Group: node.path, Depth: depth, TargetIndex: idx})
+	}
+}
+
+// selectedRow returns the row under the cursor.
+func (m Model) selectedRow() (listRow, bool) {
+	rows := m.visibleRows()
+	if m.Cursor < 0 || m.Cursor >= len(rows) {
+		return listRow{}, false
+	}
+	return rows[m.Cursor], true
+}
+
+// selectedGroup returns the group of the row under the cursor.
+func (m Model) selectedGroup() string {
+	row, _ := m.selectedRow()
+	return row.Group
+}
+
+// toggleGroup collapses or expands the group at path.
+func (m *Model) toggleGroup(path string) {
+	if m.Collapsed == nil {
+		m.Collapsed = make(map[string]bool)
+	}
+	m.Collapsed[path] = !m.Collapsed[path]
+	m.clampCursor()
+}
+
+// expandGroup makes sure every ancestor of group is expanded.
+func (m *Model) expandGroup(group string) {
+	segments := strings.Split(config.NormalizeGroup(group), "/")
+	for i := range segments {
+		delete(m.Collapsed, strings.Join(segments[:i+1], "/"))
+	}
+}
+
+// selectGroup moves the cursor onto the header of group if it is visible.
+func (m *Model) selectGroup(group string) {
+	for i, row := range m.visibleRows() {
+		if row.IsGroup() && row.Group == group {
+			m.Cursor = i
+			m.scrollToCursor()
+			return
+		}
+	}
+	m.clampCursor()
+}
@@ -0,0 +1,49 @@
+package tui
+
+import (
+	"testing"
+
+	"github.com/omegaatt36/akumi/config"
+)
+
+func TestVisibleRowsGroups(t *testing.T) {
+	m := Model{
+		Targets: []config.SSHTarget{
+			{Nickname: "laptop", User: "me", Host: "localhost", Port: 22},
+			{Nickname: "db1", User: "root", Host: "10.0.0.5", Port: 22, Group: "prod/db"},
+			{Nickname: "web1", User: "www", Host: "10.0.0.6", Port: 22, Group: "prod"},
+			{Nickname: "web2", User: "www", Host: "10.0.1.6", Port: 22, Group: "staging"},
+		},
+		FilterInput: newTextInput(),
+		Collapsed:   make(map[string]bool),
+	}
+
+	rows := m.visibleRows()
+	// prod, prod/db, db1, web1, staging, web2, laptop
+	if len(rows) != 7 {
+		t.Fatalf("Expected 7 rows, got %d: %+v", len(rows), rows)
+	}
+	if !rows[0].IsGroup() || rows[0].Group != "prod" || rows[0].Count != 2 {
+		t.Errorf("Unexpected first row: %+v", rows[0])
+	}
+	if rows[2].TargetIndex != 1 || rows[2].Depth != 2 {
+		t.Errorf("Expected db1 nested at depth 2, got %+v", rows[2])
+	}
+	if rows[6].TargetIndex != 0 || rows[6].Depth != 0 {
+		t.Errorf("Expected ungrouped target last, got %+v", rows[6])
+	}
+
+	m.toggleGroup("prod")
+	if rows = m.visibleRows(); len(rows) != 4 {
+		t.Fatalf("Expected 4 rows with prod collapsed, got %d", len(rows))
+	}
+
+	// Selecting a hidden target expands its group
+	m.selectTarget(1)
+	if idx := m.selectedTargetIndex(); idx != 1 {
+		t.Errorf("Expected cursor on target 1, got %d", idx)
+	}
+	if m.Collapsed["prod"] {
+		t.Error("Expected prod to be expanded after selecting db1")
+	}
+}
@@ -1,20 +1,28 @@
 package tui
 
 import (
+	"errors"
+	"fmt"
 	"log"
-	"os/exec"
 	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/bubbles/key"
 	tea "github.com/charmbracelet/bubbletea"
 
 	"github.com/omegaatt36/akumi/config"
+	"github.com/omegaatt36/akumi/connector"
+	"github.com/omegaatt36/akumi/tui/styles"
 )
 
 // Message types
-type SSHCommandFinishedMsg struct{}
+type SSHCommandFinishedMsg struct {
+	// Err is set when the session could not be started or was cut off,
+	// rather than ending with the exit status of the remote shell.
+	Err error
+}
 type StatusMessageTimeoutMsg struct{}
 
 // parseTargetFromInputs parses the input fields into an SSHTarget struct
@@ -24,10 +32,15 @@ func (m *Model) parseTargetFromInputs() (config.SSHTarget, bool) {
 	host := strings.TrimSpace(m.CreateInputs[InputHost].Value())
 	portStr := strings.TrimSpace(m.CreateInputs[InputPort].Value())
 	nickname := strings.TrimSpace(m.CreateInputs[InputNickname].Value())
+	group := config.NormalizeGroup(m.CreateInputs[InputGroup].Value())
+	tags := config.ParseTags(m.CreateInputs[InputTags].Value())
+	identityFile := strings.TrimSpace(m.CreateInputs[InputIdentityFile].Value())
+	proxyJump := strings.TrimSpace(m.CreateInputs[InputProxyJump].Value())
+	forwardAgentStr := strings.ToLower(strings.TrimSpace(m.CreateInputs[InputForwardAgent].Value()))
 	port := 22
 
 	// Basic validation
-	if user == "" || host == "" {
+	if (user == "" && m.Config.Defaults.User == "") || host == "" {
 		m.StatusMessage = "Username and host cannot be empty"
 		m.StatusMessageType = StatusError
 		return config.SSHTarget{}, false
@@ -43,11 +56,46 @@ func (m *Model) parseTargetFromInputs() (config.SSHTarget, bool) {
 		}
 	}
 
+	var forwardAgent bool
+	switch forwardAgentStr {
+	case "", "n", "no", "false":
+	case "y", "yes", "true":
+		forwardAgent = true
+	default:
+		m.StatusMessage = "Forward agent must be yes or no"
+		m.StatusMessageType = StatusError
+		return config.SSHTarget{}, false
+	}
+
+	options, err := config.ParseOptions(m.CreateInputs[InputOptions].Value())
+	if err != nil {
+		m.StatusMessage = "Options must be a comma-separated list of Key=Value"
+		m.StatusMessageType = StatusError
+		return config.SSHTarget{}, false
+	}
+
+	for i, target := range m.Targets {
+		if nickname != "" && target.Nickname == nickname && i != m.EditIndex {
+			m.StatusMessage = fmt.Sprintf("A target named %q already exists", nickname)
+			m.StatusMessageType = StatusError
+			return config.SSHTarget{}, false
+		}
+	}
+
+	connector := strings.TrimSpace(m.CreateInputs[InputConnector].Value())
+
 	return config.SSHTarget{
-		User:     user,
-		Host:     host,
-		Port:     port,
-		Nickname: nickname,
+		User:         user,
+		Host:         host,
+		Port:         port,
+		Nickname:     nickname,
+		Group:        group,
+		Tags:         tags,
+		IdentityFile: identityFile,
+		ProxyJump:    proxyJump,
+		ForwardAgent: forwardAgent,
+		Options:      options,
+		Connector:    connector,
 	}, true
 }
 
@@ -59,7 +107,7 @@ func (m *Model) finalizeCreateTarget() tea.Cmd {
 	}
 
 	m.Targets = append(m.Targets, newTarget)
-	m.SaveError = config.SaveConfig(config.Config{Targets: m.Targets})
+	m.SaveError = m.saveConfig()
 
 	if m.SaveError != nil {
 		m.StatusMessage = "Error saving configuration"
@@ -69,9 +117,9 @@ func (m *Model) finalizeCreateTarget() tea.Cmd {
 
 	m.State = StateListTargets
 	m.resetCreateInputs()
-	m.Cursor = len(m.Targets) - 1
-	m.StatusMessage = "New connection created successfully"
-	m.StatusMessageType = StatusSuccess
+	m.FilterInput.Reset()
+	m.selectTarget(len(m.Targets) - 1)
+	m.setSavedStatus("New connection created successfully")
 
 	return hideStatusMessageAfterDelay
 }
@@ -92,7 +140,7 @@ func (m *Model) finalizeEditTarget() tea.Cmd {
 	}
 
 	m.Targets[m.EditIndex] = updatedTarget
-	m.SaveError = config.SaveConfig(config.Config{Targets: m.Targets})
+	m.SaveError = m.saveConfig()
 
 	if m.SaveError != nil {
 		m.StatusMessage = "Error saving configuration"
@@ -101,10 +149,9 @@ func (m *Model) finalizeEditTarget() tea.Cmd {
 	}
 
 	m.State = StateListTargets
-	m.Cursor = m.EditIndex
+	m.selectTarget(m.EditIndex)
 	m.resetCreateInputs()
-	m.StatusMessage = "Connection updated successfully"
-	m.StatusMessageType = StatusSuccess
+	m.setSavedStatus("Connection updated successfully")
 
 	return hideStatusMessageAfterDelay
 }
@@ -134,6 +181,17 @@ func (m *Model) populateEditInputs() {
 	}
 	m.CreateInputs[InputPort].SetValue(portStr)
 	m.CreateInputs[InputNickname].SetValue(target.Nickname)
+	m.CreateInputs[InputGroup].SetValue(target.Group)
+	m.CreateInputs[InputTags].SetValue(strings.Join(target.Tags, ", "))
+	m.CreateInputs[InputIdentityFile].SetValue(target.IdentityFile)
+	m.CreateInputs[InputProxyJump].SetValue(target.ProxyJump)
+	forwardAgentStr := ""
+	if target.ForwardAgent {
+		forwardAgentStr = "yes"
+	}
+	m.CreateInputs[InputForwardAgent].SetValue(forwardAgentStr)
+	m.CreateInputs[InputOptions].SetValue(config.FormatOptions(target.Options))
+	m.CreateInputs[InputConnector].SetValue(target.Connector)
 
 	m.CreateFocus = InputUser
 	for i := range m.CreateInputs {
@@ -159,7 +217,30 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.StatusMessage = ""
 		return m, nil
 
+	case ConfigFileChangedMsg:
+		return m.handleConfigFileChanged()
+
+	case configWatchTickMsg:
+		return m, m.watchConfig()
+
+	case ProbeResultMsg:
+		return m.handleProbeResult(msg)
+
+	case probeRoundDoneMsg:
+		return m, m.scheduleProbes()
+
+	case probeTickMsg:
+		return m, m.probeTargets()
+
+	case hostKeyFetchedMsg:
+		return m.handleHostKeyFetched(msg)
+
 	case SSHCommandFinishedMsg:
+		if msg.Err != nil {
+			m.StatusMessage = "Connection failed: " + msg.Err.Error()
+			m.StatusMessageType = StatusError
+			return m, hideStatusMessageAfterDelay
+		}
 		m.StatusMessage = "SSH connection closed"
 		m.StatusMessageType = StatusInfo
 		return m, hideStatusMessageAfterDelay
@@ -168,6 +249,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.TerminalWidth = msg.Width
 		m.TerminalHeight = msg.Height
 		m.Help.Width = msg.Width
+		m.scrollToCursor()
 		return m, nil
 
 	case tea.KeyMsg:
@@ -177,9 +259,17 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, tea.Quit
 		}
 
+		// A pending save conflict must be resolved first
+		if m.ConfigConflict {
+			return m.updateConfigConflictState(msg)
+		}
+
 		// Handle state-specific key presses
 		switch m.State {
 		case StateListTargets:
+			if m.Filtering {
+				return m.updateFilterState(msg)
+			}
 			return m.updateListTargetsState(msg)
 		case StateCreateTarget:
 			return m.updateCreateTargetState(msg)
@@ -187,9 +277,33 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m.updateEditTargetState(msg)
 		case StateConfirmDelete:
 			return m.updateConfirmDeleteState(msg)
+		case StateImportTargets:
+			return m.updateImportTargetsState(msg)
+		case StateMoveTarget:
+			return m.updateMoveTargetState(msg)
+		case StateBackups:
+			return m.updateBackupsState(msg)
+		case StateProfiles:
+			return m.updateProfilesState(msg)
+		case StateHostKey:
+			return m.updateHostKeyState(msg)
 		}
 	}
 
+	// Update move input
+	if m.State == StateMoveTarget {
+		var cmd tea.Cmd
+		m.MoveInput, cmd = m.MoveInput.Update(msg)
+		return m, cmd
+	}
+
+	// Update filter input
+	if m.State == StateListTargets && m.Filtering {
+		var cmd tea.Cmd
+		m.FilterInput, cmd = m.FilterInput.Update(msg)
+		return m, cmd
+	}
+
 	// Update input fields
 	if m.State == StateCreateTarget || m.State == StateEditTarget {
 		var cmd tea.Cmd
@@ -216,67 +330,202 @@ func (m Model) updateListTargetsState(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	case key.Matches(msg, m.Keys.Down):
 		m = m.handleCursorDown()
 
+	case key.Matches(msg, m.Keys.PageUp):
+		m = m.handlePageUp()
+
+	case key.Matches(msg, m.Keys.PageDown):
+		m = m.handlePageDown()
+
+	case key.Matches(msg, m.Keys.Home):
+		m = m.handleCursorHome()
+
+	case key.Matches(msg, m.Keys.End):
+		m = m.handleCursorEnd()
+
 	case key.Matches(msg, m.Keys.Enter):
-		if m.canInteractWithTarget() {
+		if row, ok := m.selectedRow(); ok && row.IsGroup() {
+			m.toggleGroup(row.Group)
+		} else if m.canInteractWithTarget() {
 			return m.executeSSHCommand()
 		}
 
+	case key.Matches(msg, m.Keys.Filter):
+		m.Filtering = true
+		filterModeActive = true
+		return m, m.FilterInput.Focus()
+
+	case key.Matches(msg, m.Keys.Escape):
+		if m.filterQuery() != "" {
+			m.clearFilter()
+		}
+
 	case key.Matches(msg, m.Keys.Create):
 		return m.handleCreateTarget()
 
+	case key.Matches(msg, m.Keys.Import):
+		return m.handleImportTargets()
+
+	case key.Matches(msg, m.Keys.Export):
+		return m.handleExportTargets()
+
+	case key.Matches(msg, m.Keys.Backups):
+		return m.handleBackups()
+
+	case key.Matches(msg, m.Keys.Profile):
+		return m.handleProfiles()
+
+	case key.Matches(msg, m.Keys.Details):
+		m.ShowDetails = !m.ShowDetails
+		m.scrollToCursor()
+
+	case key.Matches(msg, m.Keys.Sort):
+		targetIndex := m.selectedTargetIndex()
+		m.SortByVersion = !m.SortByVersion
+		if targetIndex >= 0 {
+			m.selectTarget(targetIndex)
+		}
+
+	case key.Matches(msg, m.Keys.HostKey):
+		if m.canInteractWithTarget() {
+			return m.handleHostKey()
+		}
+
 	case key.Matches(msg, m.Keys.Edit):
 		if m.canInteractWithTarget() {
+			if m.selectedTargetShared() {
+				return m.rejectSharedTarget()
+			}
 			return m.handleEditTarget()
 		}
 
 	case key.Matches(msg, m.Keys.Delete):
 		if m.canInteractWithTarget() {
+			if m.selectedTargetShared() {
+				return m.rejectSharedTarget()
+			}
 			m.State = StateConfirmDelete
 			confirmationModeActive = true
 		}
+
+	case key.Matches(msg, m.Keys.Move):
+		if m.canInteractWithTarget() {
+			if m.selectedTargetShared() {
+				return m.rejectSharedTarget()
+			}
+			return m.handleMoveTarget()
+		}
 	}
 
 	return m, nil
 }
 
-// handleCursorUp moves the cursor up in the target list
+// updateFilterState handles keypresses while the filter query is being typed
+func (m Model) updateFilterState(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, filterUp):
+		return m.handleCursorUp(), nil
+
+	case key.Matches(msg, filterDown):
+		return m.handleCursorDown(), nil
+
+	case key.Matches(msg, m.Keys.PageUp):
+		return m.handlePageUp(), nil
+
+	case key.Matches(msg, m.Keys.PageDown):
+		return m.handlePageDown(), nil
+
+	case key.Matches(msg, m.Keys.Enter):
+		m.Filtering = false
+		m.FilterInput.Blur()
+		filterModeActive = false
+		return m, nil
+
+	case key.Matches(msg, m.Keys.Escape):
+		m.clearFilter()
+		return m, nil
+	}
+
+	// Keep the cursor on the same target while the visible set changes
+	selected := m.selectedTargetIndex()
+	var cmd tea.Cmd
+	m.FilterInput, cmd = m.FilterInput.Update(msg)
+	if selected >= 0 {
+		m.selectTarget(selected)
+	} else {
+		m.clampCursor()
+	}
+	return m, cmd
+}
+
+// clearFilter leaves filter mode and restores the full target list
+func (m *Model) clearFilter() {
+	selected := m.selectedTargetIndex()
+	m.Filtering = false
+	m.FilterInput.Reset()
+	m.FilterInput.Blur()
+	filterModeActive = false
+	if selected >= 0 {
+		m.selectTarget(selected)
+	}
+}
+
+// handleCursorUp moves the cursor up in the filtered target list
 func (m Model) handleCursorUp() Model {
-	if len(m.Targets) > 0 {
+	if visible := len(m.visibleRows()); visible > 0 {
 		m.Cursor--
 		if m.Cursor < 0 {
-			m.Cursor = len(m.Targets) - 1
+			m.Cursor = visible - 1
 		}
+		m.scrollToCursor()
 	}
 	return m
 }
 
-// handleCursorDown moves the cursor down in the target list
+// handleCursorDown moves the cursor down in the filtered target list
 func (m Model) handleCursorDown() Model {
-	if len(m.Targets) > 0 {
+	if visible := len(m.visibleRows()); visible > 0 {
 		m.Cursor++
-		if m.Cursor >= len(m.Targets) {
+		if m.Cursor >= visible {
 			m.Cursor = 0
 		}
+		m.scrollToCursor()
 	}
 	return m
 }
 
 // canInteractWithTarget checks if the current cursor position is valid for target interaction
 func (m Model) canInteractWithTarget() bool {
-	return len(m.Targets) > 0 && m.Cursor >= 0 && m.Cursor < len(m.Targets)
+	return m.selectedTargetIndex() >= 0
+}
+
+// selectedTargetShared reports whether the selected target comes from a
+// read-only shared config layer
+func (m Model) selectedTargetShared() bool {
+	index := m.selectedTargetIndex()
+	return index >= 0 && m.Targets[index].Shared()
+}
+
+// rejectSharedTarget explains that the selected target cannot be changed
+func (m Model) rejectSharedTarget() (tea.Model, tea.Cmd) {
+	target := m.Targets[m.selectedTargetIndex()]
+	m.StatusMessage = fmt.Sprintf("Read-only: defined in shared config %s", target.Origin)
+	m.StatusMessageType = StatusWarning
+	return m, hideStatusMessageAfterDelay
 }
 
 // handleCreateTarget initializes the create target state
 func (m Model) handleCreateTarget() (tea.Model, tea.Cmd) {
+	group := m.selectedGroup()
 	m.State = StateCreateTarget
 	m.resetCreateInputs()
+	m.CreateInputs[InputGroup].SetValue(group)
 	inputModeActive = true
 	return m, m.CreateInputs[m.CreateFocus].Focus()
 }
 
 // handleEditTarget initializes the edit target state
 func (m Model) handleEditTarget() (tea.Model, tea.Cmd) {
-	m.EditIndex = m.Cursor
+	m.EditIndex = m.selectedTargetIndex()
 	m.populateEditInputs()
 	m.State = StateEditTarget
 	inputModeActive = true
@@ -285,24 +534,45 @@ func (m Model) handleEditTarget() (tea.Model, tea.Cmd) {
 
 // executeSSHCommand executes the SSH connection command
 func (m Model) executeSSHCommand() (tea.Model, tea.Cmd) {
-	if m.Cursor < 0 || m.Cursor >= len(m.Targets) {
+	selectedIndex := m.selectedTargetIndex()
+	if selectedIndex < 0 {
 		m.StatusMessage = "Cannot connect: Selected target does not exist"
 		m.StatusMessageType = StatusError
 		return m, hideStatusMessageAfterDelay
 	}
 
-	selectedTarget := m.Targets[m.Cursor]
-	cmdArgs := selectedTarget.GetSSHCommand()
-	sshCmd := exec.Command("ssh", cmdArgs...)
+	selectedTarget := m.Targets[selectedIndex]
+	resolved := m.Config.Defaults.Resolve(selectedTarget, m.Targets)
+	conn := m.Connector
+	if conn == nil {
+		var err error
+		if conn, err = connector.New(m.Config.Connection, resolved); err != nil {
+			m.StatusMessage = "Cannot connect: " + err.Error()
+			m.StatusMessageType = StatusError
+			return m, hideStatusMessageAfterDelay
+		}
+	}
+	session, err := conn.Connect(resolved)
+	if err != nil {
+		m.StatusMessage = "Cannot connect: " + err.Error()
+		m.StatusMessageType = StatusError
+		return m, hideStatusMessageAfterDelay
+	}
 
 	m.StatusMessage = "Connecting to " + selectedTarget.String() + "..."
 	m.StatusMessageType = StatusInfo
+	if warnings := m.Config.Defaults.JumpWarnings(selectedTarget, m.Targets); len(warnings) > 0 {
+		m.StatusMessage = "Warning: " + strings.Join(warnings, "; ")
+		m.StatusMessageType = StatusWarning
+	}
 
 	return m, tea.Sequence(
-		tea.ExecProcess(sshCmd, func(err error) tea.Msg {
+		tea.Exec(session, func(err error) tea.Msg {
 			if err != nil {
 				log.Printf("SSH command execution failed: %v", err)
-				return SSHCommandFinishedMsg{}
+				if _, ok := connector.ExitCode(err); !ok {
+					return SSHCommandFinishedMsg{Err: err}
+				}
 			}
 			return SSHCommandFinishedMsg{}
 		}),
@@ -393,24 +663,18 @@ func (m Model) updateEditTargetState(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 func (m Model) updateConfirmDeleteState(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	switch {
 	case key.Matches(msg, m.Keys.Confirm):
-		if m.Cursor >= 0 && m.Cursor < len(m.Targets) {
-			deleteIndex := m.Cursor
+		if deleteIndex := m.selectedTargetIndex(); deleteIndex >= 0 {
 			m.Targets = slices.Delete(m.Targets, deleteIndex, deleteIndex+1)
-			m.SaveError = config.SaveConfig(config.Config{Targets: m.Targets})
+			m.SaveError = m.saveConfig()
 
 			if m.SaveError != nil {
 				m.StatusMessage = "Error deleting connection"
 				m.StatusMessageType = StatusError
 			} else {
-				m.StatusMessage = "Connection deleted successfully"
-				m.StatusMessageType = StatusSuccess
+				m.setSavedStatus("Connection deleted successfully")
 			}
 
-			if len(m.Targets) == 0 {
-				m.Cursor = 0
-			} else if m.Cursor >= len(m.Targets) {
-				m.Cursor = len(m.Targets) - 1
-			}
+			m.clampCursor()
 		}
 		m.State = StateListTargets
 		confirmationModeActive = false
@@ -425,6 +689,419 @@ func (m Model) updateConfirmDeleteState(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// handleMoveTarget initializes the move target state
+func (m Model) handleMoveTarget() (tea.Model, tea.Cmd) {
+	m.EditIndex = m.selectedTargetIndex()
+	m.MoveInput.SetValue(m.Targets[m.EditIndex].Group)
+	m.MoveInput.CursorEnd()
+	m.State = StateMoveTarget
+	moveModeActive = true
+	return m, m.MoveInput.Focus()
+}
+
+// updateMoveTargetState handles keypresses in the move target state
+func (m Model) updateMoveTargetState(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, m.Keys.Escape):
+		m.State = StateListTargets
+		m.EditIndex = -1
+		m.MoveInput.Blur()
+		moveModeActive = false
+		return m, nil
+
+	case key.Matches(msg, m.Keys.Enter):
+		return m, m.finalizeMoveTarget()
+	}
+
+	var cmd tea.Cmd
+	m.MoveInput, cmd = m.MoveInput.Update(msg)
+	return m, cmd
+}
+
+// finalizeMoveTarget assigns the new group, saves config, and returns to list view
+func (m *Model) finalizeMoveTarget() tea.Cmd {
+	index := m.EditIndex
+	m.State = StateListTargets
+	m.EditIndex = -1
+	m.MoveInput.Blur()
+	moveModeActive = false
+
+	if index < 0 || index >= len(m.Targets) {
+		m.StatusMessage = "Move operation failed: Target not found"
+		m.StatusMessageType = StatusError
+		return hideStatusMessageAfterDelay
+	}
+
+	group := config.NormalizeGroup(m.MoveInput.Value())
+	m.Targets[index].Group = group
+	m.SaveError = m.saveConfig()
+
+	if m.SaveError != nil {
+		m.StatusMessage = "Error saving configuration"
+		m.StatusMessageType = StatusError
+		return hideStatusMessageAfterDelay
+	}
+
+	m.selectTarget(index)
+	if group == "" {
+		m.setSavedStatus("Connection moved to top level")
+	} else {
+		m.setSavedStatus("Connection moved to " + group)
+	}
+	return hideStatusMessageAfterDelay
+}
+
+// handleImportTargets parses ~/.ssh/config and enters the import preview state
+func (m Model) handleImportTargets() (tea.Model, tea.Cmd) {
+	sshConfigPath, err := config.DefaultSSHConfigPath()
+	if err == nil {
+		var imported []config.SSHTarget
+		imported, err = config.ImportSSHConfig(sshConfigPath)
+		if err == nil {
+			m.ImportCandidates = config.ClassifyImport(m.Targets, imported)
+		}
+	}
+
+	if err != nil {
+		log.Printf("Failed to import ssh config: %v", err)
+		m.StatusMessage = "Failed to read ~/.ssh/config"
+		m.StatusMessageType = StatusError
+		return m, hideStatusMessageAfterDelay
+	}
+
+	if len(m.ImportCandidates) == 0 {
+		m.StatusMessage = "No hosts found in ~/.ssh/config"
+		m.StatusMessageType = StatusWarning
+		return m, hideStatusMessageAfterDelay
+	}
+
+	m.State = StateImportTargets
+	m.ImportCursor = 0
+	m.ImportOffset = 0
+	importModeActive = true
+	return m, nil
+}
+
+// updateImportTargetsState handles keypresses in the import preview state
+func (m Model) updateImportTargetsState(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, m.Keys.Up):
+		if len(m.ImportCandidates) > 0 {
+			m.ImportCursor = (m.ImportCursor - 1 + len(m.ImportCandidates)) % len(m.ImportCandidates)
+			m.scrollImportToCursor()
+		}
+
+	case key.Matches(msg, m.Keys.Down):
+		if len(m.ImportCandidates) > 0 {
+			m.ImportCursor = (m.ImportCursor + 1) % len(m.ImportCandidates)
+			m.scrollImportToCursor()
+		}
+
+	case key.Matches(msg, m.Keys.Confirm), key.Matches(msg, m.Keys.Enter):
+		return m, m.finalizeImportTargets()
+
+	case key.Matches(msg, m.Keys.Deny):
+		m.State = StateListTargets
+		m.ImportCandidates = nil
+		importModeActive = false
+	}
+
+	return m, nil
+}
+
+// finalizeImportTargets merges new import candidates into the targets and saves config
+func (m *Model) finalizeImportTargets() tea.Cmd {
+	added := 0
+	for _, candidate := range m.ImportCandidates {
+		if candidate.Duplicate {
+			continue
+		}
+		m.Targets = append(m.Targets, candidate.Target)
+		added++
+	}
+
+	m.State = StateListTargets
+	m.ImportCandidates = nil
+	importModeActive = false
+
+	if added == 0 {
+		m.StatusMessage = "Nothing to import: all hosts already exist"
+		m.StatusMessageType = StatusInfo
+		return hideStatusMessageAfterDelay
+	}
+
+	m.SaveError = m.saveConfig()
+	if m.SaveError != nil {
+		m.StatusMessage = "Error saving configuration"
+		m.StatusMessageType = StatusError
+		return hideStatusMessageAfterDelay
+	}
+
+	m.setSavedStatus(fmt.Sprintf("Imported %d connection(s)", added))
+	return hideStatusMessageAfterDelay
+}
+
+// handleExportTargets writes all targets into the managed OpenSSH include file
+func (m Model) handleExportTargets() (tea.Model, tea.Cmd) {
+	exportPath, err := config.DefaultSSHExportPath()
+	if err == nil {
+		err = config.WriteManagedSSHConfig(exportPath, m.Config.Defaults.ApplyAll(m.Targets))
+	}
+
+	if err != nil {
+		log.Printf("Failed to export ssh config: %v", err)
+		m.StatusMessage = "Failed to export connections"
+		m.StatusMessageType = StatusError
+		return m, hideStatusMessageAfterDelay
+	}
+
+	m.StatusMessage = fmt.Sprintf("Exported %d connection(s) to %s", len(m.Targets), exportPath)
+	m.StatusMessageType = StatusSuccess
+	return m, hideStatusMessageAfterDelay
+}
+
+// handleBackups lists configuration backups and enters the restore state
+func (m Model) handleBackups() (tea.Model, tea.Cmd) {
+	backups, err := config.ListBackups()
+	if err != nil {
+		log.Printf("Failed to list backups: %v", err)
+		m.StatusMessage = "Failed to list backups"
+		m.StatusMessageType = StatusError
+		return m, hideStatusMessageAfterDelay
+	}
+	if len(backups) == 0 {
+		m.StatusMessage = "No backups found"
+		m.StatusMessageType = StatusInfo
+		return m, hideStatusMessageAfterDelay
+	}
+
+	m.Backups = backups
+	m.BackupCursor = 0
+	m.State = StateBackups
+	backupModeActive = true
+	return m, nil
+}
+
+// updateBackupsState handles keypresses in the backup restore state
+func (m Model) updateBackupsState(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, m.Keys.Up):
+		m.BackupCursor = (m.BackupCursor - 1 + len(m.Backups)) % len(m.Backups)
+
+	case key.Matches(msg, m.Keys.Down):
+		m.BackupCursor = (m.BackupCursor + 1) % len(m.Backups)
+
+	case key.Matches(msg, m.Keys.Confirm), key.Matches(msg, m.Keys.Enter):
+		return m, m.finalizeRestoreBackup()
+
+	case key.Matches(msg, m.Keys.Deny):
+		m.State = StateListTargets
+		m.Backups = nil
+		backupModeActive = false
+	}
+
+	return m, nil
+}
+
+// finalizeRestoreBackup restores the selected backup and reloads the configuration
+func (m *Model) finalizeRestoreBackup() tea.Cmd {
+	backup := m.Backups[m.BackupCursor]
+	m.State = StateListTargets
+	m.Backups = nil
+	backupModeActive = false
+
+	if err := config.RestoreBackup(backup.Path); err != nil {
+		log.Printf("Failed to restore backup %s: %v", backup.Path, err)
+		m.StatusMessage = "Failed to restore backup"
+		m.StatusMessageType = StatusError
+		return hideStatusMessageAfterDelay
+	}
+
+	if err := m.reloadConfig(); err != nil {
+		m.Err = err
+		return nil
+	}
+
+	m.StatusMessage = "Restored backup from " + backup.Time.Format(time.DateTime)
+	m.StatusMessageType = StatusSuccess
+	return hideStatusMessageAfterDelay
+}
+
+// handleProfiles lists the available profiles and enters the profile switcher
+func (m Model) handleProfiles() (tea.Model, tea.Cmd) {
+	profiles, err := config.ListProfiles()
+	if err != nil {
+		log.Printf("Failed to list profiles: %v", err)
+		m.StatusMessage = "Failed to list profiles"
+		m.StatusMessageType = StatusError
+		return m, hideStatusMessageAfterDelay
+	}
+
+	m.Profiles = profiles
+	m.ProfileCursor = max(slices.Index(profiles, config.ActiveProfile()), 0)
+	m.State = StateProfiles
+	profileModeActive = true
+	return m, nil
+}
+
+// updateProfilesState handles keypresses in the profile switcher
+func (m Model) updateProfilesState(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, m.Keys.Up):
+		m.ProfileCursor = (m.ProfileCursor - 1 + len(m.Profiles)) % len(m.Profiles)
+
+	case key.Matches(msg, m.Keys.Down):
+		m.ProfileCursor = (m.ProfileCursor + 1) % len(m.Profiles)
+
+	case key.Matches(msg, m.Keys.Confirm), key.Matches(msg, m.Keys.Enter):
+		return m, m.finalizeSwitchProfile()
+
+	case key.Matches(msg, m.Keys.Deny):
+		m.State = StateListTargets
+		profileModeActive = false
+	}
+
+	return m, nil
+}
+
+// finalizeSwitchProfile loads the selected profile, staying on the current
+// one if its configuration cannot be loaded
+func (m *Model) finalizeSwitchProfile() tea.Cmd {
+	profile := m.Profiles[m.ProfileCursor]
+	m.State = StateListTargets
+	profileModeActive = false
+	if profile == config.ActiveProfile() {
+		return nil
+	}
+
+	restore, err := config.SetProfile(profile)
+	if err == nil {
+		if err = m.reloadConfig(); err != nil {
+			restore()
+		}
+	}
+	if err != nil {
+		log.Printf("Failed to switch to profile %s: %v", profile, err)
+		m.StatusMessage = fmt.Sprintf("Failed to load profile %s", profile)
+		m.StatusMessageType = StatusError
+		return hideStatusMessageAfterDelay
+	}
+
+	m.clearFilter()
+	m.StatusMessage = fmt.Sprintf("Switched to profile %s", profile)
+	m.StatusMessageType = StatusSuccess
+	return hideStatusMessageAfterDelay
+}
+
+// saveConfig writes the targets to disk together with the rest of the loaded
+// configuration, so the theme and keys akumi does not know about are kept.
+// If another process changed the file, the change is kept in memory, the user
+// is asked how to resolve the conflict and nil is returned; callers report
+// success with setSavedStatus.
+func (m *Model) saveConfig() error {
+	m.Config.Targets = m.Targets
+	err := config.SaveConfig(m.Config)
+	if errors.Is(err, config.ErrConfigChanged) {
+		m.ConfigConflict = true
+		conflictModeActive = true
+		return nil
+	}
+	return err
+}
+
+// setSavedStatus reports a successful save. While the save waits for a
+// conflict to be resolved nothing was written yet, so the status is left to
+// the resolution.
+func (m *Model) setSavedStatus(message string) {
+	if m.ConfigConflict {
+		return
+	}
+	m.StatusMessage = message
+	m.StatusMessageType = StatusSuccess
+}
+
+// updateConfigConflictState handles keypresses while a save conflict is pending
+func (m Model) updateConfigConflictState(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	var err error
+	switch {
+	case key.Matches(msg, m.Keys.Reload):
+		if err = m.reloadConfig(); err == nil {
+			m.StatusMessage = "Reloaded configuration, your change was discarded"
+		}
+
+	case key.Matches(msg, m.Keys.Overwrite):
+		if err = config.OverwriteConfig(m.Config); err == nil {
+			m.StatusMessage = "Configuration overwritten"
+		}
+
+	case key.Matches(msg, m.Keys.Merge):
+		var cfg config.Config
+		if cfg, err = config.MergeConfig(m.Config); err == nil {
+			m.applyConfig(cfg)
+			m.StatusMessage = "Merged your change with the file on disk"
+		}
+
+	default:
+		return m, nil
+	}
+
+	m.ConfigConflict = false
+	conflictModeActive = false
+	if err != nil {
+		m.SaveError = err
+		return m, nil
+	}
+	m.StatusMessageType = StatusSuccess
+	return m, hideStatusMessageAfterDelay
+}
+
+// reloadConfig re-reads the configuration file and applies it
+func (m *Model) reloadConfig() error {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+	m.applyConfig(cfg)
+	return nil
+}
+
+// applyConfig replaces the loaded configuration, keeping the cursor on the
+// same target or group and re-initializing the styles if the theme changed
+func (m *Model) applyConfig(cfg config.Config) {
+	row, ok := m.selectedRow()
+	var selected config.SSHTarget
+	if ok && !row.IsGroup() {
+		selected = m.Targets[row.TargetIndex]
+	}
+
+	themeChanged := cfg.Theme != m.Config.Theme
+	m.Config = cfg
+	m.Targets = cfg.Targets
+	applyDefaultPlaceholders(m.CreateInputs, cfg.Defaults)
+
+	if themeChanged {
+		styles.Initialize(cfg.Theme)
+		for i := range m.CreateInputs {
+			styleTextInput(&m.CreateInputs[i])
+		}
+		styleTextInput(&m.FilterInput)
+		styleTextInput(&m.MoveInput)
+	}
+
+	switch {
+	case !ok:
+		m.clampCursor()
+	case row.IsGroup():
+		m.selectGroup(row.Group)
+	default:
+		if index := config.IndexOfTarget(m.Targets, selected); index >= 0 {
+			m.selectTarget(index)
+		} else {
+			m.clampCursor()
+		}
+	}
+}
+
 // updateCurrentInput updates the current input field
 func (m Model) updateCurrentInput(msg tea.Msg) tea.Cmd {
 	if m.CreateFocus >= 0 && m.CreateFocus < len(m.CreateInputs) {
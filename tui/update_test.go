@@ -0,0 +1,277 @@
+package tui
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/omegaatt36/akumi/config"
+	"github.com/omegaatt36/akumi/connector"
+)
+
+func TestSavePreservesTheme(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	restoreConfigPath := config.SetConfigPathProvider(func() (string, error) {
+		return configPath, nil
+	})
+	defer restoreConfigPath()
+
+	original := "# my servers\ntargets:\n    - user: root\n      host: a.example.com\ntheme:\n    primary_color: '#FF0000'\nextra: kept\n"
+	if err := os.WriteFile(configPath, []byte(original), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	m := InitialModel()
+	if m.Err != nil {
+		t.Fatalf("Failed to load config: %v", m.Err)
+	}
+
+	m.CreateInputs[InputUser].SetValue("admin")
+	m.CreateInputs[InputHost].SetValue("b.example.com")
+	m.finalizeCreateTarget()
+	if m.SaveError != nil {
+		t.Fatalf("Failed to save config: %v", m.SaveError)
+	}
+
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("Failed to read config: %v", err)
+	}
+	content := string(data)
+	for _, want := range []string{"# my servers", "primary_color: '#FF0000'", "extra: kept", "host: b.example.com"} {
+		if !strings.Contains(content, want) {
+			t.Errorf("Expected saved config to contain %q:\n%s", want, content)
+		}
+	}
+}
+
+func TestSaveConflictMerge(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	restoreConfigPath := config.SetConfigPathProvider(func() (string, error) {
+		return configPath, nil
+	})
+	defer restoreConfigPath()
+
+	if err := config.SaveConfig(config.Config{Targets: []config.SSHTarget{{User: "root", Host: "a.example.com", Port: 22}}}); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	m := InitialModel()
+	if m.Err != nil {
+		t.Fatalf("Failed to load config: %v", m.Err)
+	}
+	defer func() { conflictModeActive = false }()
+
+	// Another process adds a target behind the TUI's back
+	other, err := config.LoadConfig()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	other.Targets = append(other.Targets, config.SSHTarget{User: "root", Host: "other.example.com", Port: 22})
+	if err := config.SaveConfig(other); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+
+	m.CreateInputs[InputUser].SetValue("admin")
+	m.CreateInputs[InputHost].SetValue("mine.example.com")
+	m.finalizeCreateTarget()
+	if m.SaveError != nil || !m.ConfigConflict {
+		t.Fatalf("Expected a pending conflict, got conflict=%v err=%v", m.ConfigConflict, m.SaveError)
+	}
+	if m.StatusMessageType == StatusSuccess {
+		t.Errorf("Expected no success status before the conflict is resolved, got %q", m.StatusMessage)
+	}
+
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("m")})
+	m = updated.(Model)
+	if m.ConfigConflict || m.SaveError != nil {
+		t.Fatalf("Expected the conflict to be resolved, got conflict=%v err=%v", m.ConfigConflict, m.SaveError)
+	}
+	if !strings.Contains(m.StatusMessage, "Merged") {
+		t.Errorf("Expected the merge to be reported, got %q", m.StatusMessage)
+	}
+
+	reloaded, err := config.LoadConfig()
+	if err != nil {
+		t.Fatalf("Failed to reload config: %v", err)
+	}
+	if len(reloaded.Targets) != 3 || len(m.Targets) != 3 {
+		t.Errorf("Expected both changes to be merged, got %+v", reloaded.Targets)
+	}
+}
+
+func TestSharedTargetIsReadOnly(t *testing.T) {
+	systemDir := t.TempDir()
+	restoreSystemDir := config.SetSystemConfigDir(systemDir)
+	defer restoreSystemDir()
+
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	restoreConfigPath := config.SetConfigPathProvider(func() (string, error) {
+		return configPath, nil
+	})
+	defer restoreConfigPath()
+
+	systemPath := filepath.Join(systemDir, "config.yaml")
+	shared := "targets:\n  - nickname: shared\n    user: ops\n    host: shared.example.com\n"
+	if err := os.WriteFile(systemPath, []byte(shared), 0600); err != nil {
+		t.Fatalf("Failed to write shared config: %v", err)
+	}
+
+	m := InitialModel()
+	if m.Err != nil {
+		t.Fatalf("Failed to load config: %v", m.Err)
+	}
+	m.selectTarget(0)
+
+	for _, key := range []string{"e", "d", "m"} {
+		updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)})
+		got := updated.(Model)
+		if got.State != StateListTargets {
+			t.Errorf("Key %q: expected to stay in the list, got state %v", key, got.State)
+		}
+		if !strings.Contains(got.StatusMessage, systemPath) {
+			t.Errorf("Key %q: expected read-only status, got %q", key, got.StatusMessage)
+		}
+	}
+}
+
+func TestSwitchProfile(t *testing.T) {
+	restoreSystemDir := config.SetSystemConfigDir(t.TempDir())
+	defer restoreSystemDir()
+
+	configDir := t.TempDir()
+	restoreConfigPath := config.SetConfigPathProvider(func() (string, error) {
+		return filepath.Join(configDir, "config.yaml"), nil
+	})
+	defer restoreConfigPath()
+
+	acme := "defaults:\n  user: ops\ntargets:\n  - host: acme.example.com\n"
+	acmePath := filepath.Join(configDir, "profiles", "acme", "config.yaml")
+	if err := os.MkdirAll(filepath.Dir(acmePath), 0750); err != nil {
+		t.Fatalf("Failed to create profile: %v", err)
+	}
+	if err := os.WriteFile(acmePath, []byte(acme), 0600); err != nil {
+		t.Fatalf("Failed to write profile: %v", err)
+	}
+	defer func() {
+		_, _ = config.SetProfile(config.DefaultProfile)
+	}()
+
+	m := InitialModel()
+	if m.Err != nil {
+		t.Fatalf("Failed to load config: %v", m.Err)
+	}
+	if !strings.Contains(m.View(), "profile: default") {
+		t.Errorf("Expected the header to show the active profile:\n%s", m.View())
+	}
+
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("p")})
+	m = updated.(Model)
+	if m.State != StateProfiles {
+		t.Fatalf("Expected the profile switcher, got state %v", m.State)
+	}
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyDown})
+	updated, _ = updated.(Model).Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = updated.(Model)
+
+	if config.ActiveProfile() != "acme" {
+		t.Fatalf("Expected profile acme to be active, got %s", config.ActiveProfile())
+	}
+	if len(m.Targets) != 1 || m.Targets[0].Host != "acme.example.com" {
+		t.Errorf("Expected the acme targets, got %+v", m.Targets)
+	}
+	if !strings.Contains(m.CreateInputs[InputUser].Placeholder, "default ops") {
+		t.Errorf("Expected the default user in the placeholder, got %q", m.CreateInputs[InputUser].Placeholder)
+	}
+}
+
+func TestConnectUsesConnector(t *testing.T) {
+	fake := &connector.Fake{}
+	m := Model{
+		State:  StateListTargets,
+		Keys:   DefaultKeyMap(),
+		Config: config.Config{Defaults: config.TargetDefaults{User: "ops"}},
+		Targets: []config.SSHTarget{
+			{Nickname: "web", Host: "web.example.com", Port: 22},
+			{Nickname: "old", Host: "old.example.com", Port: 22, Connector: "telnet"},
+		},
+		Connector: fake,
+	}
+
+	updated, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if cmd == nil {
+		t.Fatal("Expected a command that runs the session")
+	}
+	if targets := fake.Targets(); len(targets) != 1 || targets[0].User != "ops" || targets[0].Host != "web.example.com" {
+		t.Errorf("Expected the resolved target to be connected, got %+v", targets)
+	}
+
+	// Without an override the connector comes from the config
+	m = updated.(Model)
+	m.Connector = nil
+	m.selectTarget(1)
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if got := updated.(Model); got.StatusMessageType != StatusError || !strings.Contains(got.StatusMessage, `unknown connector "telnet"`) {
+		t.Errorf("Expected an unknown connector to be reported, got %q", got.StatusMessage)
+	}
+}
+
+func TestSessionFailureIsReported(t *testing.T) {
+	m := Model{State: StateListTargets, Keys: DefaultKeyMap()}
+
+	updated, _ := m.Update(SSHCommandFinishedMsg{Err: errors.New("the host key of db:22 has changed")})
+	if got := updated.(Model); got.StatusMessageType != StatusError || !strings.Contains(got.StatusMessage, "has changed") {
+		t.Errorf("Expected the failure to be reported, got %q", got.StatusMessage)
+	}
+
+	updated, _ = m.Update(SSHCommandFinishedMsg{})
+	if got := updated.(Model); got.StatusMessageType != StatusInfo {
+		t.Errorf("Expected a closed session to be reported as info, got %q", got.StatusMessage)
+	}
+}
+
+func TestDuplicateNicknameIsRejected(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	restoreConfigPath := config.SetConfigPathProvider(func() (string, error) {
+		return configPath, nil
+	})
+	defer restoreConfigPath()
+
+	original := "targets:\n  - nickname: web\n    user: root\n    host: a.example.com\n  - nickname: db\n    user: root\n    host: b.example.com\n"
+	if err := os.WriteFile(configPath, []byte(original), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	m := InitialModel()
+	if m.Err != nil {
+		t.Fatalf("Failed to load config: %v", m.Err)
+	}
+
+	m.CreateInputs[InputUser].SetValue("admin")
+	m.CreateInputs[InputHost].SetValue("c.example.com")
+	m.CreateInputs[InputNickname].SetValue("web")
+	m.finalizeCreateTarget()
+	if len(m.Targets) != 2 || !strings.Contains(m.StatusMessage, `"web" already exists`) {
+		t.Errorf("Expected the new target to be rejected, got %d targets and %q", len(m.Targets), m.StatusMessage)
+	}
+
+	// Keeping its own nickname is fine, taking another one is not
+	m.EditIndex = 1
+	m.populateEditInputs()
+	m.finalizeEditTarget()
+	if m.StatusMessageType == StatusError {
+		t.Errorf("Expected the unchanged target to save, got %q", m.StatusMessage)
+	}
+	m.EditIndex = 1
+	m.populateEditInputs()
+	m.CreateInputs[InputNickname].SetValue("web")
+	m.finalizeEditTarget()
+	if m.StatusMessageType != StatusError || m.Targets[1].Nickname != "db" {
+		t.Errorf("Expected the rename to be rejected, got %q", m.StatusMessage)
+	}
+	if _, err := config.LoadConfig(); err != nil {
+		t.Errorf("Expected the config to still load, got %v", err)
+	}
+}
@@ -1,13 +1,19 @@
 package tui
 
 import (
+	"errors"
 	"fmt"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/lipgloss"
+	"golang.org/x/crypto/ssh"
 
 	"github.com/omegaatt36/akumi/config"
+	"github.com/omegaatt36/akumi/hostkey"
+	"github.com/omegaatt36/akumi/probe"
 	"github.com/omegaatt36/akumi/tui/styles"
 )
 
@@ -19,6 +25,13 @@ func (m Model) View() string {
 
 	var b strings.Builder
 
+	if m.ConfigConflict {
+		conflictModeActive = true
+		b.WriteString(m.renderConfigConflictView())
+		b.WriteString("\n" + styles.HelpText.Render(m.Help.View(m.Keys)))
+		return b.String()
+	}
+
 	// Render main content based on current state
 	content := ""
 	switch m.State {
@@ -31,29 +44,56 @@ func (m Model) View() string {
 	case StateConfirmDelete:
 		content = m.renderConfirmDeleteView()
 		confirmationModeActive = true
+	case StateImportTargets:
+		content = m.renderImportTargetsView()
+		importModeActive = true
+	case StateMoveTarget:
+		content = m.renderMoveTargetView()
+		moveModeActive = true
+	case StateBackups:
+		content = m.renderBackupsView()
+		backupModeActive = true
+	case StateProfiles:
+		content = m.renderProfilesView()
+		profileModeActive = true
+	case StateHostKey:
+		content = m.renderHostKeyView()
+		hostKeyModeActive = true
 	case StateListTargets:
 		content = m.renderListTargetsView()
 		inputModeActive = false
 		confirmationModeActive = false
+		importModeActive = false
+		moveModeActive = false
+		backupModeActive = false
+		profileModeActive = false
+		hostKeyModeActive = false
+		filterModeActive = m.Filtering
 	}
 
 	b.WriteString(content)
+	b.WriteString(m.renderFooter())
+
+	return b.String()
+}
 
-	// Render status message if any
+// renderFooter renders the status message, if any, and the help below the
+// content
+func (m Model) renderFooter() string {
+	var b strings.Builder
 	if m.StatusMessage != "" {
-		b.WriteString("\n")
-		b.WriteString(m.renderStatusMessage())
+		b.WriteString("\n" + m.renderStatusMessage())
 	}
-
-	// Render help
-	helpView := m.Help.View(m.Keys)
-	b.WriteString("\n")
-	b.WriteString(styles.HelpText.Render(helpView))
-
+	b.WriteString("\n" + styles.HelpText.Render(m.Help.View(m.Keys)))
 	return b.String()
 }
 
 func (m Model) checkErrors() string {
+	var validationErr *config.ValidationError
+	if errors.As(m.Err, &validationErr) {
+		return m.renderValidationErrors(validationErr)
+	}
+
 	if m.Err != nil {
 		return styles.ErrorText.Render(fmt.Sprintf("\nError: Failed to load configuration - %v\n\nPress Q or Ctrl+C to exit.\n", m.Err))
 	}
@@ -65,6 +105,20 @@ func (m Model) checkErrors() string {
 	return ""
 }
 
+func (m Model) renderValidationErrors(err *config.ValidationError) string {
+	var b strings.Builder
+
+	b.WriteString(styles.ErrorText.Render(fmt.Sprintf("\nError: %s has %d problem(s):", err.Path, len(err.Problems))) + "\n\n")
+	for _, problem := range err.Problems {
+		location := fmt.Sprintf("%4d:%-3d", problem.Line, problem.Column)
+		b.WriteString("  " + styles.BaseStyle.Faint(true).Render(location) + " " +
+			styles.SubTitle.Render(problem.Field) + " " + styles.BaseStyle.Render(problem.Message) + "\n")
+	}
+	b.WriteString("\n" + styles.HelpText.Render("Fix the file and restart akumi, or run 'akumi config validate'. Press Q or Ctrl+C to exit.") + "\n")
+
+	return b.String()
+}
+
 func (m Model) renderStatusMessage() string {
 	var style lipgloss.Style
 
@@ -87,10 +141,7 @@ func (m Model) renderCreateTargetView() string {
 	b.WriteString(styles.Title.Render("Add SSH Connection") + "\n\n")
 
 	// Render input fields with labels
-	b.WriteString(m.renderInputField("Username:", m.CreateInputs[InputUser], m.CreateFocus == InputUser))
-	b.WriteString(m.renderInputField("Host:", m.CreateInputs[InputHost], m.CreateFocus == InputHost))
-	b.WriteString(m.renderInputField("Port:", m.CreateInputs[InputPort], m.CreateFocus == InputPort))
-	b.WriteString(m.renderInputField("Nickname:", m.CreateInputs[InputNickname], m.CreateFocus == InputNickname))
+	b.WriteString(m.renderTargetInputs())
 
 	return b.String()
 }
@@ -106,10 +157,44 @@ func (m Model) renderEditTargetView() string {
 	b.WriteString(styles.SubTitle.Render(targetStr) + "\n\n")
 
 	// Render input fields with labels
-	b.WriteString(m.renderInputField("Username:", m.CreateInputs[InputUser], m.CreateFocus == InputUser))
-	b.WriteString(m.renderInputField("Host:", m.CreateInputs[InputHost], m.CreateFocus == InputHost))
-	b.WriteString(m.renderInputField("Port:", m.CreateInputs[InputPort], m.CreateFocus == InputPort))
-	b.WriteString(m.renderInputField("Nickname:", m.CreateInputs[InputNickname], m.CreateFocus == InputNickname))
+	b.WriteString(m.renderTargetInputs())
+
+	return b.String()
+}
+
+// inputLabels holds the form label for each target input field
+var inputLabels = [NumInputs]string{
+	InputUser:         "Username:",
+	InputHost:         "Host:",
+	InputPort:         "Port:",
+	InputNickname:     "Nickname:",
+	InputGroup:        "Group:",
+	InputTags:         "Tags:",
+	InputIdentityFile: "Identity:",
+	InputProxyJump:    "Jump host:",
+	InputForwardAgent: "Fwd agent:",
+	InputOptions:      "Options:",
+	InputConnector:    "Connector:",
+}
+
+func (m Model) renderTargetInputs() string {
+	var b strings.Builder
+	for i, label := range inputLabels {
+		b.WriteString(m.renderInputField(label, m.CreateInputs[i], m.CreateFocus == i))
+	}
+	return b.String()
+}
+
+func (m Model) renderMoveTargetView() string {
+	var b strings.Builder
+	targetStr := ""
+	if m.EditIndex >= 0 && m.EditIndex < len(m.Targets) {
+		targetStr = m.Targets[m.EditIndex].String()
+	}
+
+	b.WriteString(styles.Title.Render("Move SSH Connection") + "\n")
+	b.WriteString(styles.SubTitle.Render(targetStr) + "\n\n")
+	b.WriteString(m.renderInputField("Group:", m.MoveInput, true))
 
 	return b.String()
 }
@@ -150,8 +235,8 @@ func (m Model) renderInputField(label string, input textinput.Model, isFocused b
 func (m Model) renderConfirmDeleteView() string {
 	var b strings.Builder
 	targetStr := ""
-	if m.Cursor >= 0 && m.Cursor < len(m.Targets) {
-		targetStr = m.Targets[m.Cursor].String()
+	if idx := m.selectedTargetIndex(); idx >= 0 {
+		targetStr = m.Targets[idx].String()
 	}
 
 	// Create warning style dialog box
@@ -164,6 +249,161 @@ func (m Model) renderConfirmDeleteView() string {
 	return b.String()
 }
 
+func (m Model) renderImportTargetsView() string {
+	var b strings.Builder
+
+	b.WriteString(m.renderImportTop())
+
+	start, end := windowRange(m.ImportOffset, m.ImportCursor, len(m.ImportCandidates), m.importListHeight())
+	for i := start; i < end; i++ {
+		candidate := m.ImportCandidates[i]
+		marker := styles.BaseStyle.Foreground(styles.SuccessColor).Render("[new]")
+		if candidate.Duplicate {
+			marker = styles.BaseStyle.Foreground(styles.WarningColor).Render("[dup]")
+		}
+
+		if m.ImportCursor == i {
+			cursor := styles.CursorStyle.Render("→")
+			b.WriteString(fmt.Sprintf("%s %s %s\n", cursor, marker, styles.SelectedListItem.Render(candidate.Target.String())))
+		} else {
+			b.WriteString(fmt.Sprintf("  %s %s\n", marker, styles.BaseStyle.Render(candidate.Target.String())))
+		}
+	}
+
+	b.WriteString(m.renderImportBottom())
+
+	return b.String()
+}
+
+// renderImportTop renders the title and counts above the import candidates
+func (m Model) renderImportTop() string {
+	newCount := 0
+	for _, candidate := range m.ImportCandidates {
+		if !candidate.Duplicate {
+			newCount++
+		}
+	}
+
+	title := styles.Title.Render("Import from ~/.ssh/config") + "\n"
+	counts := fmt.Sprintf("%d new, %d duplicate · %d/%d", newCount, len(m.ImportCandidates)-newCount, m.ImportCursor+1, len(m.ImportCandidates))
+	return title + styles.SubTitle.Render(counts) + "\n\n"
+}
+
+// renderImportBottom renders the prompt below the import candidates
+func (m Model) renderImportBottom() string {
+	return "\n" + styles.HelpText.Render("Press 'y' to import new connections, or 'n' / Esc to cancel.")
+}
+
+func (m Model) renderBackupsView() string {
+	var b strings.Builder
+
+	b.WriteString(styles.Title.Render("Restore Configuration Backup") + "\n")
+	b.WriteString(styles.SubTitle.Render(fmt.Sprintf("%d backup(s), newest first", len(m.Backups))) + "\n\n")
+
+	for i, backup := range m.Backups {
+		label := fmt.Sprintf("%s  %s", backup.Time.Format(time.DateTime), filepath.Base(backup.Path))
+		if m.BackupCursor == i {
+			b.WriteString(styles.CursorStyle.Render("→") + " " + styles.SelectedListItem.Render(label) + "\n")
+		} else {
+			b.WriteString("  " + styles.BaseStyle.Render(label) + "\n")
+		}
+	}
+
+	b.WriteString("\n" + styles.HelpText.Render("Press 'y' to restore the selected backup, or 'n' / Esc to cancel."))
+
+	return b.String()
+}
+
+func (m Model) renderProfilesView() string {
+	var b strings.Builder
+
+	b.WriteString(styles.Title.Render("Switch Profile") + "\n")
+	b.WriteString(styles.SubTitle.Render(fmt.Sprintf("%d profile(s)", len(m.Profiles))) + "\n\n")
+
+	for i, profile := range m.Profiles {
+		label := profile
+		if profile == config.ActiveProfile() {
+			label += " (active)"
+		}
+		if m.ProfileCursor == i {
+			b.WriteString(styles.CursorStyle.Render("→") + " " + styles.SelectedListItem.Render(label) + "\n")
+		} else {
+			b.WriteString("  " + styles.BaseStyle.Render(label) + "\n")
+		}
+	}
+
+	b.WriteString("\n" + styles.HelpText.Render("Press 'y' to switch to the selected profile, or 'n' / Esc to cancel."))
+
+	return b.String()
+}
+
+func (m Model) renderHostKeyView() string {
+	var b strings.Builder
+	inspection := m.HostKey
+
+	b.WriteString(styles.Title.Render("Host Key") + "\n")
+	b.WriteString(styles.SubTitle.Render(inspection.Target.String()) + "\n\n")
+	b.WriteString(styles.InputLabel.Render("Address") + " " + inspection.Address + "\n")
+
+	switch {
+	case inspection.Err != nil:
+		b.WriteString(styles.InputLabel.Render("Server") + " " + styles.ErrorText.Render(inspection.Err.Error()) + "\n")
+	case inspection.Key == nil:
+		b.WriteString(styles.InputLabel.Render("Server") + " " + styles.BaseStyle.Faint(true).Render("Fetching host key...") + "\n")
+	default:
+		b.WriteString(styles.InputLabel.Render("Server") + " " + inspection.Key.Type() + " " + ssh.FingerprintSHA256(inspection.Key) + "\n")
+	}
+
+	var hint string
+	if inspection.Key != nil {
+		status := inspection.Status()
+		label := styles.InputLabel.Render("Status") + " "
+		switch status {
+		case hostkey.Known:
+			b.WriteString(label + styles.BaseStyle.Foreground(styles.SuccessColor).Render("matches known_hosts") + "\n")
+		case hostkey.Changed:
+			b.WriteString(label + styles.ErrorText.Render("CHANGED: the key differs from known_hosts") + "\n")
+			hint = "Press 'r' to replace the old entries with this key, only if you know the host was rebuilt."
+		case hostkey.Revoked:
+			b.WriteString(label + styles.ErrorText.Render("REVOKED: this key is marked as revoked") + "\n")
+		default:
+			b.WriteString(label + styles.BaseStyle.Foreground(styles.WarningColor).Render("not in known_hosts") + "\n")
+			hint = "Press 'a' to accept this key and add it to known_hosts."
+		}
+	}
+
+	entries := inspection.KnownHosts.Lookup(inspection.Address)
+	b.WriteString("\n" + styles.SubTitle.Render(fmt.Sprintf("%d entry(s) in %s", len(entries), inspection.KnownHosts.Path)) + "\n")
+	for _, entry := range entries {
+		label := fmt.Sprintf("line %d  %s %s", entry.Line, entry.Key.Type(), entry.Fingerprint())
+		if entry.Marker != "" {
+			label += "  @" + entry.Marker
+		}
+		if entry.Hashed() {
+			label += "  (hashed)"
+		}
+		b.WriteString("  " + styles.BaseStyle.Render(label) + "\n")
+	}
+
+	if hint != "" {
+		b.WriteString("\n" + styles.HelpText.Render(hint))
+	}
+	if len(entries) > 0 {
+		b.WriteString("\n" + styles.HelpText.Render("Press 'd' to remove the entries for this host, or 'n' / Esc to go back."))
+	} else {
+		b.WriteString("\n" + styles.HelpText.Render("Press 'n' / Esc to go back."))
+	}
+
+	return b.String()
+}
+
+func (m Model) renderConfigConflictView() string {
+	configPath, _ := config.GetConfigPath()
+	message := fmt.Sprintf("The configuration file was changed by another program\nwhile you were editing it.\n\n%s\n\n"+
+		"Reload it and discard your change, overwrite it with your\nversion, or merge both changes?", styles.SubTitle.Render(configPath))
+	return styles.DialogBox.Render(message)
+}
+
 func (m Model) renderListTargetsView() string {
 	if len(m.Targets) == 0 {
 		return m.renderEmptyTargetsView()
@@ -175,7 +415,7 @@ func (m Model) renderEmptyTargetsView() string {
 	var b strings.Builder
 	configPath, _ := config.GetConfigPath()
 
-	b.WriteString(styles.Title.Render("SSH Connection Manager") + "\n\n")
+	b.WriteString(m.renderHeader() + "\n\n")
 	b.WriteString("No SSH connections configured yet.\n")
 	b.WriteString(fmt.Sprintf("Config file location: %s\n\n", configPath))
 
@@ -184,31 +424,188 @@ func (m Model) renderEmptyTargetsView() string {
 	return b.String()
 }
 
+// renderHeader renders the title, followed by the active profile once more
+// than one profile exists
+func (m Model) renderHeader() string {
+	title := styles.Title.Render("SSH Connection Manager")
+	profile := config.ActiveProfile()
+	if len(m.Profiles) <= 1 && profile == config.DefaultProfile {
+		return title
+	}
+	badge := styles.TagBadge.Render("profile: " + profile)
+	return lipgloss.JoinHorizontal(lipgloss.Top, title, " ", badge)
+}
+
+func renderTagBadges(tags []string) string {
+	var b strings.Builder
+	for _, tag := range tags {
+		b.WriteString(" " + styles.TagBadge.Render(tag))
+	}
+	return b.String()
+}
+
+// renderOrigin marks targets from a shared config layer with the file name
+func renderOrigin(target config.SSHTarget) string {
+	if !target.Shared() {
+		return ""
+	}
+	return " " + styles.BaseStyle.Faint(true).Render("("+filepath.Base(target.Origin)+", read-only)")
+}
+
+func (m Model) renderGroupRow(row listRow, selected bool) string {
+	icon := "▾"
+	if m.Collapsed[row.Group] && m.filterQuery() == "" {
+		icon = "▸"
+	}
+	label := fmt.Sprintf("%s %s (%d)", icon, row.Name(), row.Count)
+
+	if selected {
+		return styles.CursorStyle.Render("→") + " " + styles.SelectedListItem.Render(label)
+	}
+	return "  " + styles.GroupHeader.Render(label)
+}
+
+// renderListTop renders the header and filter line above the target rows
+func (m Model) renderListTop() string {
+	var b strings.Builder
+	b.WriteString(m.renderHeader() + "\n")
+	if m.Filtering || m.filterQuery() != "" {
+		b.WriteString(m.FilterInput.View() + "\n")
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// renderListBottom renders the position indicator and detail pane below the
+// target rows
+func (m Model) renderListBottom(visible []listRow) string {
+	targets, position := 0, 0
+	for i, row := range visible {
+		if row.IsGroup() {
+			continue
+		}
+		targets++
+		if i == m.Cursor {
+			position = targets
+		}
+	}
+
+	// Group headers are not counted, so the indicator reads in targets
+	indicator := fmt.Sprintf("%d target(s)", targets)
+	if position > 0 {
+		indicator = fmt.Sprintf("%d/%d", position, targets)
+	}
+	if m.SortByVersion {
+		indicator += " · sorted by server version"
+	}
+	bottom := styles.BaseStyle.Faint(true).Render(indicator) + "\n"
+	if m.ShowDetails {
+		bottom += m.renderDetails()
+	}
+	return bottom
+}
+
 func (m Model) renderTargetsList() string {
 	var b strings.Builder
 
-	// Title
-	b.WriteString(styles.Title.Render("SSH Connection Manager") + "\n\n")
+	b.WriteString(m.renderListTop())
+	_, _, text := parseFilterQuery(m.filterQuery())
+
+	visible := m.visibleRows()
+	if len(visible) == 0 {
+		b.WriteString(styles.HelpText.Render("No connections match the filter.") + "\n")
+		return b.String()
+	}
+
+	// Render the window of rows that fits on screen
+	start, end := m.visibleRange(len(visible))
+	for i := start; i < end; i++ {
+		row := visible[i]
+		indent := strings.Repeat("  ", row.Depth)
+		if row.IsGroup() {
+			b.WriteString(indent + m.renderGroupRow(row, m.Cursor == i) + "\n")
+			continue
+		}
 
-	// Render targets in a styled list
-	for i, target := range m.Targets {
 		var line string
+		target := m.Targets[row.TargetIndex]
 		targetDisplay := target.String()
+		positions, _ := matchTarget(text, target)
 
 		if m.Cursor == i {
 			// Selected item style
 			cursor := styles.CursorStyle.Render("→")
-			item := styles.SelectedListItem.Render(targetDisplay)
+			item := highlightMatches(targetDisplay, positions, styles.SelectedListItem, styles.MatchHighlight)
 			line = fmt.Sprintf("%s %s", cursor, item)
 		} else {
 			// Normal item style
 			cursor := "  "
-			item := styles.ListItem.Render(targetDisplay)
+			item := styles.ListItem.Render(highlightMatches(targetDisplay, positions, lipgloss.NewStyle(), styles.MatchHighlight))
 			line = fmt.Sprintf("%s%s", cursor, item)
 		}
 
-		b.WriteString(line + "\n")
+		b.WriteString(indent + line + m.renderProbeStatus(target) + renderTagBadges(target.Tags) + renderOrigin(target) + "\n")
 	}
 
+	b.WriteString(m.renderListBottom(visible))
+
+	return b.String()
+}
+
+// renderDetails renders the probe details of the selected target, taking
+// detailPaneLines lines
+func (m Model) renderDetails() string {
+	idx := m.selectedTargetIndex()
+	if idx < 0 {
+		return "\n" + styles.HelpText.Render("Select a connection to see its details.") + "\n" + strings.Repeat("\n", detailPaneLines-2)
+	}
+	target := m.Targets[idx]
+	address := m.probeAddress(target)
+
+	var status string
+	result, probed := m.Probes[address]
+	switch {
+	case address == "":
+		status = "not probed (behind a jump host)"
+	case m.Config.Probe.Disabled:
+		status = "probing disabled"
+	case !probed:
+		status = "pending"
+	case !result.Reachable:
+		status = "unreachable"
+		if result.Err != nil {
+			status += ": " + result.Err.Error()
+		}
+	default:
+		status = "reachable in " + formatLatency(result.Latency)
+	}
+
+	server, software, seen := "unknown", "unknown", "never"
+	if banner, ok := m.serverBanner(target); ok {
+		server = banner.Banner
+		if version, ok := probe.ParseBanner(banner.Banner); ok {
+			software = version.Software
+		}
+		seen = "unknown"
+		if !banner.Seen.IsZero() {
+			seen = banner.Seen.Local().Format("2006-01-02 15:04:05")
+		}
+	}
+	if address == "" {
+		address = "-"
+	}
+
+	fields := []struct{ label, value string }{
+		{"Address", address},
+		{"Status", status},
+		{"Server", server},
+		{"Software", software},
+		{"Last seen", seen},
+	}
+	var b strings.Builder
+	b.WriteString("\n" + styles.SubTitle.Render(target.String()) + "\n")
+	for _, field := range fields {
+		b.WriteString(styles.InputLabel.Render(field.label) + " " + field.value + "\n")
+	}
 	return b.String()
 }
@@ -0,0 +1,25 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/omegaatt36/akumi/config"
+)
+
+func TestCheckErrorsListsProblems(t *testing.T) {
+	m := Model{Err: &config.ValidationError{
+		Path: "/tmp/config.yaml",
+		Problems: []config.Problem{
+			{Line: 3, Column: 11, Field: "targets[0].port", Message: "must be a number"},
+			{Line: 9, Column: 5, Field: "targets[1]", Message: "host is required"},
+		},
+	}}
+
+	view := m.checkErrors()
+	for _, want := range []string{"2 problem(s)", "3:11", "targets[0].port", "must be a number", "9:5", "host is required"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("Expected error screen to contain %q:\n%s", want, view)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package tui
+
+import "github.com/charmbracelet/lipgloss"
+
+// detailPaneLines is the number of terminal rows taken by the detail pane.
+const detailPaneLines = 7
+
+// listHeight returns how many targets fit on screen, or 0 when the terminal
+// size is not yet known and every target should be rendered.
+func (m Model) listHeight() int {
+	if m.TerminalHeight <= 0 {
+		return 0
+	}
+	return max(m.TerminalHeight-m.listReservedLines(), 1)
+}
+
+// listReservedLines returns the number of terminal rows rendered around the
+// target rows: the header, filter line, position indicator, detail pane,
+// status message and help. Every target row ends in exactly one newline, so
+// the rest of the screen takes the lines of everything else put together.
+func (m Model) listReservedLines() int {
+	return lipgloss.Height(m.renderListTop() + m.renderListBottom(m.visibleRows()) + m.renderFooter())
+}
+
+// windowRange returns the half-open range of total rows shown in height
+// rows, scrolled as little as possible from offset. The range always contains
+// the cursor, even if the space around the list shrank since the offset was
+// last adjusted. A height of 0 shows every row.
+func windowRange(offset, cursor, total, height int) (int, int) {
+	if height == 0 || total <= height {
+		return 0, total
+	}
+	start := max(offset, 0)
+	if cursor >= start+height {
+		start = cursor - height + 1
+	}
+	start = min(max(min(start, cursor), 0), total-height)
+	return start, start + height
+}
+
+// visibleRange returns the half-open range of filtered rows to render.
+func (m Model) visibleRange(total int) (int, int) {
+	return windowRange(m.ListOffset, m.Cursor, total, m.listHeight())
+}
+
+// scrollToCursor adjusts the list offset so the cursor stays on screen.
+func (m *Model) scrollToCursor() {
+	m.ListOffset, _ = windowRange(m.ListOffset, m.Cursor, len(m.visibleRows()), m.listHeight())
+}
+
+// importListHeight returns how many import candidates fit on screen, or 0
+// when the terminal size is not yet known.
+func (m Model) importListHeight() int {
+	if m.TerminalHeight <= 0 {
+		return 0
+	}
+	reserved := lipgloss.Height(m.renderImportTop() + m.renderImportBottom() + m.renderFooter())
+	return max(m.TerminalHeight-reserved, 1)
+}
+
+// scrollImportToCursor adjusts the import offset so the cursor stays on screen.
+func (m *Model) scrollImportToCursor() {
+	m.ImportOffset, _ = windowRange(m.ImportOffset, m.ImportCursor, len(m.ImportCandidates), m.importListHeight())
+}
+
+// handlePageUp moves the cursor up by one screen of targets
+func (m Model) handlePageUp() Model {
+	m.Cursor -= max(m.listHeight(), 1)
+	m.clampCursor()
+	return m
+}
+
+// handlePageDown moves the cursor down by one screen of targets
+func (m Model) handlePageDown() Model {
+	m.Cursor += max(m.listHeight(), 1)
+	m.clampCursor()
+	return m
+}
+
+// handleCursorHome moves the cursor to the first target
+func (m Model) handleCursorHome() Model {
+	m.Cursor = 0
+	m.clampCursor()
+	return m
+}
+
+// handleCursorEnd moves the cursor to the last target
+func (m Model) handleCursorEnd() Model {
+	m.Cursor = len(m.visibleRows()) - 1
+	m.clampCursor()
+	return m
+}
@@ -0,0 +1,171 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/bubbles/help"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/omegaatt36/akumi/config"
+)
+
+// newScrollModel returns a list of n ungrouped targets in a terminal of the
+// given height
+func newScrollModel(n, height int) Model {
+	m := Model{
+		State:          StateListTargets,
+		Keys:           DefaultKeyMap(),
+		Help:           help.New(),
+		FilterInput:    newTextInput(),
+		Collapsed:      make(map[string]bool),
+		TerminalWidth:  200,
+		TerminalHeight: height,
+	}
+	m.Help.Width = m.TerminalWidth
+	for i := range n {
+		m.Targets = append(m.Targets, config.SSHTarget{Nickname: fmt.Sprintf("host%02d", i), Host: "10.0.0.1", Port: 22})
+	}
+	return m
+}
+
+// press sends a key to the model
+func press(m Model, msg tea.KeyMsg) Model {
+	updated, _ := m.Update(msg)
+	return updated.(Model)
+}
+
+// checkFits fails if the view is taller than the terminal or does not show
+// the selected target
+func checkFits(t *testing.T, m Model, step string) {
+	t.Helper()
+	view := m.View()
+	if height := lipgloss.Height(view); height > m.TerminalHeight {
+		t.Errorf("%s: view is %d lines, terminal is %d:\n%s", step, height, m.TerminalHeight, view)
+	}
+	selected := m.Targets[m.selectedTargetIndex()].Nickname
+	if !strings.Contains(view, selected) {
+		t.Errorf("%s: selected target %s is not on screen:\n%s", step, selected, view)
+	}
+}
+
+func TestViewportKeys(t *testing.T) {
+	m := newScrollModel(50, 20)
+	height := m.listHeight()
+	if height < 1 || height >= 20 {
+		t.Fatalf("Expected a list height below the terminal height, got %d", height)
+	}
+	checkFits(t, m, "initial")
+
+	m = press(m, tea.KeyMsg{Type: tea.KeyPgDown})
+	if m.Cursor != height {
+		t.Errorf("Page down: expected cursor %d, got %d", height, m.Cursor)
+	}
+	checkFits(t, m, "page down")
+
+	m = press(m, tea.KeyMsg{Type: tea.KeyEnd})
+	if m.Cursor != 49 || m.ListOffset != 50-height {
+		t.Errorf("End: expected cursor 49 at offset %d, got %d at %d", 50-height, m.Cursor, m.ListOffset)
+	}
+	checkFits(t, m, "end")
+
+	m = press(m, tea.KeyMsg{Type: tea.KeyPgUp})
+	if m.Cursor != 49-height {
+		t.Errorf("Page up: expected cursor %d, got %d", 49-height, m.Cursor)
+	}
+	checkFits(t, m, "page up")
+
+	m = press(m, tea.KeyMsg{Type: tea.KeyHome})
+	if m.Cursor != 0 || m.ListOffset != 0 {
+		t.Errorf("Home: expected cursor and offset 0, got %d and %d", m.Cursor, m.ListOffset)
+	}
+	checkFits(t, m, "home")
+}
+
+func TestViewportReservesRenderedChrome(t *testing.T) {
+	m := newScrollModel(50, 30)
+	base := m.listHeight()
+
+	m.StatusMessage = "Connecting..."
+	if got := m.listHeight(); got != base-1 {
+		t.Errorf("Expected the status line to take one row, got list height %d from %d", got, base)
+	}
+
+	m.Help.ShowAll = true
+	full := m.listHeight()
+	if full >= base-1 {
+		t.Errorf("Expected the full help to take more rows, got list height %d", full)
+	}
+
+	// The cursor stays on screen when the space shrinks after scrolling
+	m.Help.ShowAll = false
+	m.StatusMessage = ""
+	m = press(m, tea.KeyMsg{Type: tea.KeyEnd})
+	m.Help.ShowAll = true
+	m.StatusMessage = "Connecting..."
+	checkFits(t, m, "full help")
+
+	m.ShowDetails = true
+	checkFits(t, m, "details")
+}
+
+func TestPositionCountsTargets(t *testing.T) {
+	m := newScrollModel(0, 0)
+	m.Targets = []config.SSHTarget{
+		{Nickname: "db1", Host: "10.0.0.5", Port: 22, Group: "prod"},
+		{Nickname: "web1", Host: "10.0.0.6", Port: 22, Group: "prod"},
+		{Nickname: "laptop", Host: "localhost", Port: 22},
+	}
+
+	// Rows: prod, db1, web1, laptop
+	m.Cursor = 2
+	if view := m.View(); !strings.Contains(view, "2/3") {
+		t.Errorf("Expected web1 to be target 2/3:\n%s", view)
+	}
+	m.Cursor = 0
+	if view := m.View(); !strings.Contains(view, "3 target(s)") {
+		t.Errorf("Expected the target count on a group header:\n%s", view)
+	}
+}
+
+func TestImportPreviewScrolls(t *testing.T) {
+	m := newScrollModel(0, 15)
+	for i := range 40 {
+		target := config.SSHTarget{Nickname: fmt.Sprintf("imported%02d", i), Host: "10.0.0.2", Port: 22}
+		m.ImportCandidates = append(m.ImportCandidates, config.ImportCandidate{Target: target})
+	}
+	m.State = StateImportTargets
+	importModeActive = true
+	defer func() { importModeActive = false }()
+
+	check := func(step string) {
+		t.Helper()
+		view := m.View()
+		if height := lipgloss.Height(view); height > m.TerminalHeight {
+			t.Errorf("%s: view is %d lines, terminal is %d:\n%s", step, height, m.TerminalHeight, view)
+		}
+		selected := m.ImportCandidates[m.ImportCursor].Target.Nickname
+		if !strings.Contains(view, selected) || !strings.Contains(view, "Press 'y'") {
+			t.Errorf("%s: expected %s and the prompt on screen:\n%s", step, selected, view)
+		}
+	}
+	check("initial")
+
+	for range 20 {
+		m = press(m, tea.KeyMsg{Type: tea.KeyDown})
+	}
+	if m.ImportCursor != 20 || m.ImportOffset == 0 {
+		t.Errorf("Expected the list to scroll with the cursor, got cursor %d at offset %d", m.ImportCursor, m.ImportOffset)
+	}
+	check("down")
+
+	// Moving up from the first candidate wraps to the last one
+	m.ImportCursor, m.ImportOffset = 0, 0
+	m = press(m, tea.KeyMsg{Type: tea.KeyUp})
+	if m.ImportCursor != 39 {
+		t.Errorf("Expected the cursor to wrap to the last candidate, got %d", m.ImportCursor)
+	}
+	check("wrap")
+}
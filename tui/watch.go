@@ -0,0 +1,47 @@
+package tui
+
+import (
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// configWatchInterval is how often the config file is checked for changes.
+const configWatchInterval = time.Second
+
+// ConfigFileChangedMsg is sent when the config file was modified by another program.
+type ConfigFileChangedMsg struct{}
+
+// configWatchTickMsg re-arms the watcher when the config file is unchanged
+type configWatchTickMsg struct{}
+
+// watchConfig polls the config file once and reports whether it changed
+func (m Model) watchConfig() tea.Cmd {
+	cfg := m.Config
+	return tea.Tick(configWatchInterval, func(time.Time) tea.Msg {
+		if cfg.ChangedOnDisk() {
+			return ConfigFileChangedMsg{}
+		}
+		return configWatchTickMsg{}
+	})
+}
+
+// handleConfigFileChanged reloads the config file after an external change.
+// While a form or dialog is open the reload waits until the user is back on
+// the list; saving in the meantime raises the usual conflict prompt.
+func (m Model) handleConfigFileChanged() (tea.Model, tea.Cmd) {
+	if m.State != StateListTargets || m.ConfigConflict || !m.Config.ChangedOnDisk() {
+		return m, m.watchConfig()
+	}
+
+	if err := m.reloadConfig(); err != nil {
+		// The file may be half written; try again on the next tick
+		m.StatusMessage = "Config file changed but could not be loaded: " + err.Error()
+		m.StatusMessageType = StatusWarning
+		return m, m.watchConfig()
+	}
+
+	m.StatusMessage = "Configuration reloaded from disk"
+	m.StatusMessageType = StatusInfo
+	return m, tea.Batch(m.watchConfig(), hideStatusMessageAfterDelay)
+}
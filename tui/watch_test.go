@@ -0,0 +1,64 @@
+package tui
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/omegaatt36/akumi/config"
+)
+
+func TestConfigFileChangedKeepsSelection(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	restoreConfigPath := config.SetConfigPathProvider(func() (string, error) {
+		return configPath, nil
+	})
+	defer restoreConfigPath()
+
+	initial := config.Config{Targets: []config.SSHTarget{
+		{Nickname: "a", User: "root", Host: "a.example.com", Port: 22},
+		{Nickname: "b", User: "root", Host: "b.example.com", Port: 22},
+	}}
+	if err := config.SaveConfig(initial); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+
+	m := InitialModel()
+	if m.Err != nil {
+		t.Fatalf("Failed to load config: %v", m.Err)
+	}
+	m.selectTarget(1)
+
+	// An editor inserts a target before b, edits b and changes the theme
+	other, err := config.LoadConfig()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	other.Targets = []config.SSHTarget{
+		other.Targets[0],
+		{Nickname: "new", User: "root", Host: "new.example.com", Port: 22},
+		{Nickname: "b", User: "admin", Host: "b.example.com", Port: 2222},
+	}
+	other.Theme.PrimaryColor = "#123456"
+	if err := config.SaveConfig(other); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+
+	if !m.Config.ChangedOnDisk() {
+		t.Fatal("Expected the external change to be detected")
+	}
+	updated, _ := m.Update(ConfigFileChangedMsg{})
+	m = updated.(Model)
+
+	if len(m.Targets) != 3 {
+		t.Fatalf("Expected 3 targets after reload, got %d", len(m.Targets))
+	}
+	if index := m.selectedTargetIndex(); index != 2 || m.Targets[index].Nickname != "b" {
+		t.Errorf("Expected the cursor to stay on b, got index %d", index)
+	}
+	if m.Config.Theme.PrimaryColor != "#123456" {
+		t.Errorf("Expected the new theme to be applied, got %s", m.Config.Theme.PrimaryColor)
+	}
+	if m.Config.ChangedOnDisk() {
+		t.Error("Expected the reloaded config to be current")
+	}
+}